@@ -0,0 +1,112 @@
+// Package cdc delivers the changes captured during synchronizations to other
+// systems (change data capture).
+//
+// Changes are recorded in the database in the same transactions as the rows
+// they modify. They are then sent to the sinks by batches, oldest first, and
+// the sequence number of the last change delivered is stored once all the
+// sinks have acknowledged a batch. Delivery is therefore at least once: a
+// batch which could not be delivered to every sink is sent again, to all of
+// them, by the next delivery. Consumers can rely on the sequence numbers to
+// ignore changes they have already processed.
+package cdc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+)
+
+// PublishTimeout bounds the delivery of the changes at the end of a
+// synchronization.
+const PublishTimeout = 2 * time.Minute
+
+// Publisher delivers the captured changes of a database to sinks.
+type Publisher struct {
+	db        *sql.DB
+	sinks     []Sink
+	batchSize int
+}
+
+// NewPublisher returns a publisher delivering the changes to the sinks of
+// the configuration, which must be closed once done.
+func NewPublisher(config *Config, db *sql.DB) (*Publisher, error) {
+	p := &Publisher{db: db, batchSize: config.BatchSize}
+	for _, sinkConfig := range config.Sinks {
+		sink, err := NewSink(sinkConfig)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.sinks = append(p.sinks, sink)
+	}
+
+	return p, nil
+}
+
+// NewPublisherWithSinks returns a publisher delivering the changes to the
+// given sinks by batches of the given size.
+func NewPublisherWithSinks(db *sql.DB, batchSize int, sinks ...Sink) *Publisher {
+	return &Publisher{db: db, sinks: sinks, batchSize: batchSize}
+}
+
+// Publish delivers all the pending changes and returns how many have been.
+// It stops at the first batch not acknowledged by every sink.
+func (p *Publisher) Publish(ctx context.Context) (int, error) {
+	delivered := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+
+		changes, err := database.GetCapturedChanges(p.db, p.batchSize)
+		if err != nil {
+			return delivered, fmt.Errorf("failed to read the captured changes: %w", err)
+		}
+		if len(changes) == 0 {
+			return delivered, nil
+		}
+
+		// All sinks are tried, the batch is sent again to all of them if
+		// one fails
+		var errs []error
+		for _, sink := range p.sinks {
+			if err = sink.Send(ctx, changes); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err = errors.Join(errs...); err != nil {
+			return delivered, err
+		}
+
+		last := changes[len(changes)-1].Sequence
+		if err = database.AcknowledgeChanges(p.db, last); err != nil {
+			return delivered, fmt.Errorf("failed to record the delivery of changes up to %d: %w", last, err)
+		}
+		delivered += len(changes)
+		slog.Debug("changes delivered", "count", len(changes), "sequence", last)
+	}
+}
+
+// PublishDetached delivers all the pending changes like Publish, but keeps
+// going once ctx is cancelled, until the timeout elapses. Changes committed by
+// an interrupted synchronization are therefore delivered too.
+func (p *Publisher) PublishDetached(ctx context.Context, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	return p.Publish(ctx)
+}
+
+// Close closes the sinks.
+func (p *Publisher) Close() error {
+	var errs []error
+	for _, sink := range p.sinks {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
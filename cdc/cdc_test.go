@@ -0,0 +1,272 @@
+package cdc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/gmazoyer/peeringdb-sync/internal/testutil"
+	"github.com/gmazoyer/peeringdb-sync/peeringdbtest"
+	"github.com/gmazoyer/peeringdb-sync/progress"
+	"gopkg.in/yaml.v3"
+)
+
+// recordingSink keeps the changes sent to it, failing while fail is set.
+type recordingSink struct {
+	mu      sync.Mutex
+	fail    bool
+	changes []database.CapturedChange
+}
+
+func (s *recordingSink) Send(ctx context.Context, changes []database.CapturedChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail {
+		return errors.New("unavailable")
+	}
+	s.changes = append(s.changes, changes...)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	return nil
+}
+
+// synchronizeNetworks synchronizes the networks of the server, capturing the
+// changes.
+func synchronizeNetworks(t *testing.T, server *peeringdbtest.Server, db *sql.DB) {
+	t.Helper()
+
+	testutil.SynchronizeNetworks(t, &database.Synchronization{API: server.API(), DB: db, CaptureChanges: true})
+}
+
+func TestPublish(t *testing.T) {
+	server, db := testutil.NewDatabase(t)
+	synchronizeNetworks(t, server, db)
+
+	sink := &recordingSink{}
+	publisher := NewPublisherWithSinks(db, 2, sink)
+	delivered, err := publisher.Publish(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 4 || len(sink.changes) != 4 {
+		t.Fatalf("delivered %d changes, sink got %d, want 4", delivered, len(sink.changes))
+	}
+
+	network := sink.changes[2]
+	if network.Sequence != 3 || network.Object != "net" || network.Table != "peeringdb_network" || network.ID != 10 ||
+		network.Operation != database.OperationInsert {
+		t.Errorf("unexpected change: %+v", network)
+	}
+	var record map[string]interface{}
+	if err = json.Unmarshal(network.Record, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["asn"] != float64(64500) {
+		t.Errorf("unexpected record: %v", record)
+	}
+
+	// Delivered changes are forgotten
+	if sequence, err := database.GetDeliveredSequence(db); err != nil || sequence != 4 {
+		t.Errorf("got delivered sequence %d (%v), want 4", sequence, err)
+	}
+	if delivered, err = publisher.Publish(context.Background()); err != nil || delivered != 0 {
+		t.Errorf("delivered %d changes (%v) again, want none", delivered, err)
+	}
+}
+
+func TestPublishInterrupted(t *testing.T) {
+	server, db := testutil.NewDatabase(t)
+
+	// The synchronization is interrupted after the organizations
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &database.Synchronization{API: server.API(), DB: db, CaptureChanges: true}
+	if err := s.SynchronizeOrganizations(ctx, progress.Discard.Task("org")); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := s.SynchronizeNetworks(ctx, progress.Discard.Task("net")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want the synchronization to be interrupted", err)
+	}
+
+	sink := &recordingSink{}
+	publisher := NewPublisherWithSinks(db, 1, sink)
+	if delivered, err := publisher.Publish(ctx); !errors.Is(err, context.Canceled) || delivered != 0 {
+		t.Errorf("delivered %d changes (%v) with a cancelled context", delivered, err)
+	}
+
+	// The changes committed before the interruption are still delivered
+	delivered, err := publisher.PublishDetached(ctx, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 2 || len(sink.changes) != 2 || sink.changes[1].Table != "peeringdb_organization" {
+		t.Errorf("delivered %d changes, sink got %+v, want the 2 organizations", delivered, sink.changes)
+	}
+}
+
+func TestPublishAtLeastOnce(t *testing.T) {
+	server, db := testutil.NewDatabase(t)
+	synchronizeNetworks(t, server, db)
+
+	at := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	if err := server.Update("net", 10, at, peeringdbtest.Object{"name": "Renamed Network"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Delete("net", 11, at); err != nil {
+		t.Fatal(err)
+	}
+
+	healthy, failing := &recordingSink{}, &recordingSink{fail: true}
+	publisher := NewPublisherWithSinks(db, 4, healthy, failing)
+	if _, err := publisher.Publish(context.Background()); err == nil {
+		t.Fatal("changes delivered although a sink failed")
+	}
+
+	// Changes captured meanwhile follow the ones not delivered yet
+	synchronizeNetworks(t, server, db)
+	failing.fail = false
+	delivered, err := publisher.Publish(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 6 || len(failing.changes) != 6 {
+		t.Fatalf("delivered %d changes, sink got %d, want 6", delivered, len(failing.changes))
+	}
+	// The first batch was sent twice to the healthy sink
+	if len(healthy.changes) != 10 || healthy.changes[0].Sequence != healthy.changes[4].Sequence {
+		t.Errorf("unexpected changes: %+v", healthy.changes)
+	}
+
+	update, deletion := failing.changes[4], failing.changes[5]
+	if update.Operation != database.OperationUpdate || update.ID != 10 ||
+		strings.Join(update.ChangedFields, ",") != "name,updated" {
+		t.Errorf("unexpected update: %+v", update)
+	}
+	if deletion.Operation != database.OperationDelete || deletion.ID != 11 || len(deletion.Record) == 0 {
+		t.Errorf("unexpected deletion: %+v", deletion)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changes.jsonl")
+	changes := []database.CapturedChange{{Sequence: 1, Record: json.RawMessage(`{"id":1}`)}}
+	lines, _ := encodeLines(changes)
+
+	// Room for two batches per file, two rotated files kept
+	sink, err := NewFileSink(path, int64(2*len(lines)), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < 7; i++ {
+		if err = sink.Send(context.Background(), changes); err != nil {
+			t.Fatal(err)
+		}
+		// Rotated files are named after the time
+		time.Sleep(time.Millisecond)
+	}
+
+	rotated, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Errorf("got rotated files %v, want 2", rotated)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != string(lines) {
+		t.Errorf("got %q in the current file, want %q", content, lines)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	var received []database.CapturedChange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// The first attempt fails
+		if requests++; requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var body struct {
+			Changes []database.CapturedChange `json:"changes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, body.Changes...)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPSink(server.URL, map[string]string{"X-Token": "secret"}, 1, time.Millisecond, time.Second)
+	changes := []database.CapturedChange{{Sequence: 7, Object: "net", Record: json.RawMessage(`{}`)}}
+	if err := sink.Send(context.Background(), changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0].Sequence != 7 {
+		t.Errorf("unexpected changes received: %+v", received)
+	}
+
+	// Client errors are not retried
+	sink = NewHTTPSink(server.URL, nil, 3, time.Millisecond, time.Second)
+	requests = 1
+	if err := sink.Send(context.Background(), changes); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("got error %v, want an unauthorized one", err)
+	}
+	if requests != 2 {
+		t.Errorf("got %d attempts, want 1", requests-1)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	var config Config
+	err := yaml.Unmarshal([]byte(`
+sinks:
+  - type: file
+    path: /tmp/changes.jsonl
+    max_size: 1048576
+  - type: http
+    url: https://example.com/changes
+    retries: 1
+`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BatchSize != defaultBatchSize || config.Sinks[0].Retries != 0 || config.Sinks[1].Retries != 1 ||
+		config.Sinks[1].Timeout != defaultTimeout {
+		t.Errorf("unexpected configuration: %+v", config)
+	}
+
+	for _, document := range []string{"sinks: [{type: kafka}]", "sinks: [{type: file}]", "sinks: [{type: http}]", "batch_size: 0"} {
+		if err := yaml.Unmarshal([]byte(document), &Config{}); err == nil {
+			t.Errorf("invalid configuration accepted: %s", document)
+		}
+	}
+}
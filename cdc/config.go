@@ -0,0 +1,143 @@
+package cdc
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultBatchSize  = 500
+	defaultRetries    = 3
+	defaultRetryDelay = time.Second
+	defaultTimeout    = 10 * time.Second
+)
+
+// Types of sinks.
+const (
+	SinkFile   = "file"
+	SinkStdout = "stdout"
+	SinkHTTP   = "http"
+)
+
+// SinkConfig describes where to send changes. Only the settings relevant to
+// its type are used.
+type SinkConfig struct {
+	Type string `yaml:"type"`
+
+	// Path is the file changes are appended to. Once it reaches MaxSize
+	// bytes, it is renamed with a timestamp suffix and a new one is started.
+	// Only the MaxFiles most recent renamed files are kept, all of them if 0.
+	Path     string `yaml:"path,omitempty"`
+	MaxSize  int64  `yaml:"max_size,omitempty"`
+	MaxFiles int    `yaml:"max_files,omitempty"`
+
+	// URL is the endpoint changes are posted to, with the given headers.
+	URL        string            `yaml:"url,omitempty"`
+	Headers    map[string]string `yaml:"headers,omitempty"`
+	Retries    int               `yaml:"retries,omitempty"`
+	RetryDelay time.Duration     `yaml:"retry_delay,omitempty"`
+	Timeout    time.Duration     `yaml:"timeout,omitempty"`
+}
+
+// UnmarshalYAML decodes the settings of a sink, the retry policy of HTTP
+// sinks keeping its defaults when not given.
+func (s *SinkConfig) UnmarshalYAML(value *yaml.Node) error {
+	type plain SinkConfig
+	sink := plain{Retries: defaultRetries, RetryDelay: defaultRetryDelay, Timeout: defaultTimeout}
+	if err := value.Decode(&sink); err != nil {
+		return err
+	}
+
+	*s = SinkConfig(sink)
+	if s.Type != SinkHTTP {
+		s.Retries, s.RetryDelay, s.Timeout = 0, 0, 0
+	}
+
+	return nil
+}
+
+// Config holds the sinks changes are delivered to.
+type Config struct {
+	// BatchSize is the maximum number of changes sent at once.
+	BatchSize int          `yaml:"batch_size"`
+	Sinks     []SinkConfig `yaml:"sinks"`
+}
+
+// DefaultConfig returns a configuration without any sink.
+func DefaultConfig() *Config {
+	return &Config{BatchSize: defaultBatchSize}
+}
+
+// LoadConfig reads the change data capture configuration from the given
+// YAML file.
+func LoadConfig(filename string) (*Config, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultConfig()
+	if err = yaml.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// UnmarshalYAML decodes and validates the configuration. Values missing from
+// the YAML document keep their defaults.
+func (c *Config) UnmarshalYAML(value *yaml.Node) error {
+	type plain Config
+	config := plain(*DefaultConfig())
+	if err := value.Decode(&config); err != nil {
+		return err
+	}
+
+	*c = Config(config)
+	return c.Validate()
+}
+
+// WritesToStdout returns true if one of the sinks writes the changes to the
+// standard output.
+func (c *Config) WritesToStdout() bool {
+	for _, sink := range c.Sinks {
+		if sink.Type == SinkStdout {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the configuration is usable.
+func (c *Config) Validate() error {
+	if c.BatchSize < 1 {
+		return fmt.Errorf("the batch size must be positive")
+	}
+
+	for _, sink := range c.Sinks {
+		switch sink.Type {
+		case SinkFile:
+			if sink.Path == "" {
+				return fmt.Errorf("file sink without path")
+			}
+			if sink.MaxSize < 0 || sink.MaxFiles < 0 {
+				return fmt.Errorf("negative rotation settings for %s", sink.Path)
+			}
+		case SinkStdout:
+		case SinkHTTP:
+			if sink.URL == "" {
+				return fmt.Errorf("HTTP sink without URL")
+			}
+			if sink.Retries < 0 {
+				return fmt.Errorf("negative number of retries for %s", sink.URL)
+			}
+		default:
+			return fmt.Errorf("unknown sink type %q, use file, stdout or http", sink.Type)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/gmazoyer/peeringdb-sync/internal/httppost"
+)
+
+// Sink receives the captured changes. A batch must only be acknowledged, by
+// returning nil, once it is safely stored or handed over: it is otherwise
+// sent again.
+type Sink interface {
+	Send(ctx context.Context, changes []database.CapturedChange) error
+	Close() error
+}
+
+// NewSink returns the sink described by the configuration.
+func NewSink(config SinkConfig) (Sink, error) {
+	switch config.Type {
+	case SinkFile:
+		return NewFileSink(config.Path, config.MaxSize, config.MaxFiles)
+	case SinkStdout:
+		return NewWriterSink(os.Stdout), nil
+	case SinkHTTP:
+		return NewHTTPSink(config.URL, config.Headers, config.Retries, config.RetryDelay, config.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", config.Type)
+	}
+}
+
+// encodeLines encodes the changes as JSON objects, one per line.
+func encodeLines(changes []database.CapturedChange) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	for _, change := range changes {
+		if err := encoder.Encode(change); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// WriterSink writes changes as JSON lines to a writer, the standard output
+// for instance.
+type WriterSink struct {
+	w io.Writer
+}
+
+// NewWriterSink returns a sink writing to the given writer.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Send(ctx context.Context, changes []database.CapturedChange) error {
+	lines, err := encodeLines(changes)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(lines)
+	return err
+}
+
+// Close does nothing, the writer belongs to the caller.
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink appends changes as JSON lines to a file, rotating it once it
+// reaches a given size.
+type FileSink struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	file *os.File
+	size int64
+}
+
+// NewFileSink opens the file to append changes to. A maximum size of 0
+// disables rotation, a maximum number of files of 0 keeps all the rotated
+// files.
+func NewFileSink(path string, maxSize int64, maxFiles int) (*FileSink, error) {
+	s := &FileSink{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// rotate renames the current file with a timestamp suffix, removes the
+// oldest rotated files and starts a new file.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	rotated := s.path + "." + time.Now().UTC().Format("20060102T150405.000000000Z")
+	if err := os.Rename(s.path, rotated); err != nil {
+		return err
+	}
+
+	if s.maxFiles > 0 {
+		// Timestamps sort in chronological order
+		files, err := filepath.Glob(s.path + ".*")
+		if err != nil {
+			return err
+		}
+		files = slices.DeleteFunc(files, func(f string) bool {
+			return !strings.HasSuffix(f, "Z")
+		})
+		slices.Sort(files)
+		for len(files) > s.maxFiles {
+			if err = os.Remove(files[0]); err != nil {
+				return err
+			}
+			files = files[1:]
+		}
+	}
+
+	return s.open()
+}
+
+// Send appends the changes to the file and flushes them to the disk before
+// acknowledging them.
+func (s *FileSink) Send(ctx context.Context, changes []database.CapturedChange) error {
+	lines, err := encodeLines(changes)
+	if err != nil {
+		return err
+	}
+
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(lines)) > s.maxSize {
+		if err = s.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate %s: %w", s.path, err)
+		}
+	}
+
+	n, err := s.file.Write(lines)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	return s.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// HTTPSink posts changes as a JSON document with a changes list, retrying on
+// transient failures.
+type HTTPSink struct {
+	poster  *httppost.Poster
+	url     string
+	headers map[string]string
+}
+
+// NewHTTPSink returns a sink posting to the given URL.
+func NewHTTPSink(url string, headers map[string]string, retries int, retryDelay, timeout time.Duration) *HTTPSink {
+	return &HTTPSink{
+		poster:  httppost.NewPoster(retries, retryDelay, timeout),
+		url:     url,
+		headers: headers,
+	}
+}
+
+// Send posts the changes, retrying with an exponential backoff.
+func (s *HTTPSink) Send(ctx context.Context, changes []database.CapturedChange) error {
+	body, err := json.Marshal(map[string]interface{}{"changes": changes})
+	if err != nil {
+		return err
+	}
+
+	if err = s.poster.Post(ctx, s.url, s.headers, body); err != nil {
+		return fmt.Errorf("%s: %w", s.url, err)
+	}
+	return nil
+}
+
+// Close releases the idle connections.
+func (s *HTTPSink) Close() error {
+	s.poster.Close()
+	return nil
+}
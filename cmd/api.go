@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/gmazoyer/peeringdb"
+	"github.com/gmazoyer/peeringdb-sync/credentials"
+)
+
+// newAPI returns a client for the PeeringDB API. If an API key is configured,
+// it is retrieved from its source and validated before being used.
+func newAPI() (*peeringdb.API, error) {
+	source, err := credentials.NewSource(Configuration.APIKey, Configuration.APIKeyFile, Configuration.APIKeyCommand)
+	if err != nil {
+		return nil, err
+	}
+
+	if source == nil {
+		slog.Warn("no API key configured, the API will be queried anonymously")
+		return peeringdb.NewAPI(), nil
+	}
+
+	key, err := source.APIKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the API key from %s: %w", source, err)
+	}
+
+	api := peeringdb.NewAPIWithAPIKey(key)
+	if err = credentials.Validate(api); err != nil {
+		return nil, err
+	}
+	slog.Debug("API key validated", "source", source.String())
+
+	return api, nil
+}
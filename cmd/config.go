@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gmazoyer/peeringdb-sync/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Configuration is the effective configuration, the configuration file merged
+// with the environment and the command line flags.
+var Configuration *config.Config
+var configFile, loadedConfigFile string
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// loadConfiguration reads the configuration file and overrides its values
+// with the ones given through the environment or the command line flags.
+func loadConfiguration(cmd *cobra.Command) error {
+	c, path, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the configuration: %w", err)
+	}
+
+	flags := cmd.Flags()
+	overrideCredentials(flags, c)
+	override(flags, "file", "PEERINGDB_DATABASE_FILE", &c.DatabaseFile)
+	override(flags, "log-level", "", &c.Log.Level)
+	override(flags, "log-format", "", &c.Log.Format)
+	override(flags, "metrics-listen", "", &c.Metrics.Listen)
+	override(flags, "metrics-file", "", &c.Metrics.File)
+	override(flags, "snapshot-dir", "PEERINGDB_SNAPSHOT_DIR", &c.Snapshots.Dir)
+	overrideInt(flags, "keep-daily", &c.Snapshots.KeepDaily)
+	overrideInt(flags, "keep-weekly", &c.Snapshots.KeepWeekly)
+
+	PeeringdbApiKey, PeeringdbDbFile = c.APIKey, c.DatabaseFile
+	Configuration, loadedConfigFile = c, path
+
+	return nil
+}
+
+// override replaces the value with the one of the flag if the flag exists and
+// has been set on the command line, or with the one of the given environment
+// variable if it is set.
+func override(flags *pflag.FlagSet, name, env string, value *string) {
+	flag := flags.Lookup(name)
+	if flag == nil {
+		return
+	}
+
+	if flag.Changed {
+		*value = flag.Value.String()
+		return
+	}
+
+	if env != "" {
+		if v, ok := os.LookupEnv(env); ok {
+			*value = v
+		}
+	}
+}
+
+// overrideInt replaces the value with the one of the integer flag if the
+// flag exists and has been set on the command line.
+func overrideInt(flags *pflag.FlagSet, name string, value *int) {
+	if flags.Lookup(name) != nil && flags.Changed(name) {
+		*value, _ = flags.GetInt(name)
+	}
+}
+
+// overrideCredentials replaces all the API key settings if at least one of
+// them is given on the command line or, failing that, through the
+// environment. This way a key source given at a higher precedence level is
+// never mixed with another one coming from a lower level.
+func overrideCredentials(flags *pflag.FlagSet, c *config.Config) {
+	names := []string{"api-key", "api-key-file", "api-key-command"}
+	envs := []string{"PEERINGDB_API_KEY", "PEERINGDB_API_KEY_FILE", ""}
+	values := []*string{&c.APIKey, &c.APIKeyFile, &c.APIKeyCommand}
+
+	for _, name := range names {
+		if flags.Changed(name) {
+			for i, name := range names {
+				*values[i] = flags.Lookup(name).Value.String()
+			}
+			return
+		}
+	}
+
+	for _, env := range envs {
+		if _, ok := os.LookupEnv(env); ok && env != "" {
+			for i, env := range envs {
+				*values[i] = ""
+				if env != "" {
+					*values[i] = os.Getenv(env)
+				}
+			}
+			return
+		}
+	}
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+	Long: `Inspect the configuration resulting from the configuration file, the environment and the command line flags.
+
+The configuration file is written in YAML, other formats such as TOML are not
+supported. Command line flags take precedence over the environment, which
+takes precedence over the file.`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration",
+	Long:  `Show the effective configuration as YAML, with secrets redacted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if loadedConfigFile == "" {
+			fmt.Println("# No configuration file found")
+		} else {
+			fmt.Printf("# Configuration file: %s\n", loadedConfigFile)
+		}
+		fmt.Print(Configuration.Redacted())
+
+		return nil
+	},
+}
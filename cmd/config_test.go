@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestLoadConfiguration(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "peeringdb-sync.yml")
+	content := `api_key: file-key
+database_file: file.db
+snapshots:
+  dir: file-snapshots
+  keep_daily: 7
+`
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "file only",
+			want: []string{"file-key", "", "", "file.db", "file-snapshots", "7"},
+		},
+		{
+			name: "environment over file",
+			env:  map[string]string{"PEERINGDB_API_KEY": "env-key", "PEERINGDB_DATABASE_FILE": "env.db", "PEERINGDB_SNAPSHOT_DIR": "env-snapshots"},
+			want: []string{"env-key", "", "", "env.db", "env-snapshots", "7"},
+		},
+		{
+			name: "flags over environment",
+			args: []string{"--api-key", "flag-key", "--file", "flag.db", "--snapshot-dir", "flag-snapshots", "--keep-daily", "3"},
+			env:  map[string]string{"PEERINGDB_API_KEY": "env-key", "PEERINGDB_DATABASE_FILE": "env.db", "PEERINGDB_SNAPSHOT_DIR": "env-snapshots"},
+			want: []string{"flag-key", "", "", "flag.db", "flag-snapshots", "3"},
+		},
+		{
+			name: "key file flag replaces all the credentials",
+			args: []string{"--api-key-file", "/flag/key"},
+			env:  map[string]string{"PEERINGDB_API_KEY": "env-key"},
+			want: []string{"", "/flag/key", "", "file.db", "file-snapshots", "7"},
+		},
+		{
+			name: "key command flag replaces all the credentials",
+			args: []string{"--api-key-command", "pass peeringdb"},
+			want: []string{"", "", "pass peeringdb", "file.db", "file-snapshots", "7"},
+		},
+		{
+			name: "key file environment replaces all the credentials",
+			env:  map[string]string{"PEERINGDB_API_KEY_FILE": "/env/key"},
+			want: []string{"", "/env/key", "", "file.db", "file-snapshots", "7"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, env := range []string{"PEERINGDB_API_KEY", "PEERINGDB_API_KEY_FILE", "PEERINGDB_DATABASE_FILE", "PEERINGDB_SNAPSHOT_DIR"} {
+				t.Setenv(env, "")
+				os.Unsetenv(env)
+			}
+			for env, value := range tc.env {
+				t.Setenv(env, value)
+			}
+
+			cmd := newConfigTestCommand()
+			if err := cmd.ParseFlags(tc.args); err != nil {
+				t.Fatal(err)
+			}
+			setConfigFile(t, file)
+			if err := loadConfiguration(cmd); err != nil {
+				t.Fatal(err)
+			}
+
+			c := Configuration
+			got := []string{c.APIKey, c.APIKeyFile, c.APIKeyCommand, c.DatabaseFile, c.Snapshots.Dir, strconv.Itoa(c.Snapshots.KeepDaily)}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if loadedConfigFile != file {
+				t.Errorf("loaded %q, want %q", loadedConfigFile, file)
+			}
+			if PeeringdbApiKey != c.APIKey || PeeringdbDbFile != c.DatabaseFile {
+				t.Errorf("globals not updated: %q, %q", PeeringdbApiKey, PeeringdbDbFile)
+			}
+		})
+	}
+}
+
+func TestLoadConfigurationTOML(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "peeringdb-sync.toml")
+	if err := os.WriteFile(file, []byte("api_key = \"key\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	setConfigFile(t, file)
+	if err := loadConfiguration(newConfigTestCommand()); err == nil || !strings.Contains(err.Error(), "TOML") {
+		t.Errorf("got error %v, want TOML to be rejected", err)
+	}
+}
+
+// newConfigTestCommand returns a command with the flags overriding the
+// configuration file.
+func newConfigTestCommand() *cobra.Command {
+	cmd := &cobra.Command{Use: "test"}
+	flags := cmd.Flags()
+	for _, name := range []string{"api-key", "api-key-file", "api-key-command", "file", "snapshot-dir"} {
+		flags.String(name, "", "")
+	}
+	flags.Int("keep-daily", 0, "")
+	flags.Int("keep-weekly", 0, "")
+	return cmd
+}
+
+// setConfigFile points the configuration file to the given one for the
+// duration of the test.
+func setConfigFile(t *testing.T, file string) {
+	previous := configFile
+	configFile = file
+	t.Cleanup(func() { configFile = previous })
+}
@@ -13,34 +13,39 @@ func init() {
 	databaseCmd.AddCommand(databaseInitCmd)
 	databaseCmd.AddCommand(databaseDeleteCmd)
 	databaseCmd.AddCommand(databaseClearCmd)
+	databaseCmd.AddCommand(databaseMigrateCmd)
 	rootCmd.AddCommand(databaseCmd)
 }
 
 var databaseCmd = &cobra.Command{
 	Use:   "database",
 	Short: "Perform database operations",
-	Long:  `Create, delete or clear the database.`,
+	Long:  `Create, delete, clear or migrate the database.`,
 }
 
 var databaseInitCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize the database",
 	Long:  `Initialize the database with the schema, creating required tables to store records.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		removeExisting, _ := cmd.Flags().GetBool("clean")
 		db, err := database.CreateDatabase(PeeringdbDbFile, removeExisting)
 		if err != nil {
-			fmt.Printf("Failed to initialize the database: %s\n", err.Error())
-			return
+			return fmt.Errorf("failed to initialize the database: %w", err)
 		}
 
 		defer db.Close()
 
 		_, err = database.CreateDatabaseSchema(db, database.GetSchema())
 		if err != nil {
-			fmt.Printf("Failed to create the database schema: %s\n", err.Error())
-			return
+			return fmt.Errorf("failed to create the database schema: %w", err)
 		}
+
+		if err = database.CreateSearchIndex(db); err != nil {
+			return fmt.Errorf("failed to create the search index: %w", err)
+		}
+
+		return nil
 	},
 }
 
@@ -48,10 +53,12 @@ var databaseDeleteCmd = &cobra.Command{
 	Use:   "delete",
 	Short: "Delete the database",
 	Long:  `Delete the database and all its content.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if err := database.DeleteDatabase(PeeringdbDbFile); err != nil {
-			fmt.Printf("Failed to delete the database: %s\n", err.Error())
+			return fmt.Errorf("failed to delete the database: %w", err)
 		}
+
+		return nil
 	},
 }
 
@@ -59,17 +66,38 @@ var databaseClearCmd = &cobra.Command{
 	Use:   "clear",
 	Short: "Clear the database",
 	Long:  `Clear the database content, keeping the schema.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		db, err := database.GetDatabaseConnection(PeeringdbDbFile)
 		if err != nil {
-			fmt.Printf("Failed to connect to the database: %s\n", err.Error())
-			return
+			return fmt.Errorf("failed to connect to the database: %w", err)
 		}
 
 		defer db.Close()
 
 		if err = database.ClearDatabase(db, database.GetSchema()); err != nil {
-			fmt.Printf("Failed to clear the database: %s\n", err.Error())
+			return fmt.Errorf("failed to clear the database: %w", err)
+		}
+
+		return nil
+	},
+}
+
+var databaseMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the database",
+	Long:  `Upgrade the database to the current schema, adding missing tables, columns and indexes and filling derived columns.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.GetDatabaseConnection(PeeringdbDbFile)
+		if err != nil {
+			return fmt.Errorf("failed to connect to the database: %w", err)
+		}
+
+		defer db.Close()
+
+		if err = database.Migrate(db, database.GetSchema()); err != nil {
+			return fmt.Errorf("failed to migrate the database: %w", err)
 		}
+
+		return nil
 	},
 }
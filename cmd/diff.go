@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/gmazoyer/peeringdb-sync/snapshot"
+	"github.com/spf13/cobra"
+)
+
+// diffFormats lists the formats accepted by the diff --output flag.
+var diffFormats = []string{"text", "json", "csv"}
+
+func init() {
+	databaseDiffCmd.Flags().StringSliceP("table", "t", nil, "Only compare these tables (default all)")
+	databaseDiffCmd.Flags().StringArray("filter", nil, "Only compare the rows of a table matching an SQL condition, given as table:condition")
+	databaseDiffCmd.Flags().StringP("output", "o", "text", "Output format, one of "+strings.Join(diffFormats, ", "))
+
+	databaseCmd.AddCommand(databaseDiffCmd)
+}
+
+// openDiffDatabase opens a database file or a compressed snapshot. The
+// returned function closes it.
+func openDiffDatabase(path string) (*sql.DB, func(), error) {
+	// Opening a missing file would create an empty database
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, err
+	}
+
+	if strings.HasSuffix(path, ".gz") {
+		return snapshot.Open(&snapshot.Snapshot{Path: path})
+	}
+
+	db, err := database.GetDatabaseConnection(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, func() { db.Close() }, nil
+}
+
+// parseDiffFilters parses table:condition filters.
+func parseDiffFilters(values []string) (map[string]string, error) {
+	filters := make(map[string]string, len(values))
+	for _, value := range values {
+		table, condition, ok := strings.Cut(value, ":")
+		if !ok || table == "" || strings.TrimSpace(condition) == "" {
+			return nil, fmt.Errorf("invalid filter %q, expected table:condition", value)
+		}
+		if _, ok := filters[table]; ok {
+			filters[table] = fmt.Sprintf("(%s) AND (%s)", filters[table], condition)
+		} else {
+			filters[table] = condition
+		}
+	}
+	return filters, nil
+}
+
+// diffValue formats a value of a row, quoting text when displayed to humans.
+func diffValue(value interface{}, quote bool) string {
+	switch v := value.(type) {
+	case nil:
+		if quote {
+			return "NULL"
+		}
+		return ""
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case string:
+		if quote {
+			return strconv.Quote(v)
+		}
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// diffWriter writes differences in one of the diff formats.
+type diffWriter struct {
+	format string
+	w      *bufio.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+func newDiffWriter(format string, w io.Writer) (*diffWriter, error) {
+	d := &diffWriter{format: format, w: bufio.NewWriter(w)}
+	switch format {
+	case "text":
+	case "json":
+		d.json = json.NewEncoder(d.w)
+		d.json.SetEscapeHTML(false)
+	case "csv":
+		d.csv = csv.NewWriter(d.w)
+		if err := d.csv.Write([]string{"table", "id", "operation", "field", "old", "new"}); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(diffFormats, ", "))
+	}
+	return d, nil
+}
+
+// write writes a difference: a line per row followed by the changed fields
+// as text, an object per line as JSON and a line per field as CSV.
+func (d *diffWriter) write(difference database.Difference) error {
+	switch d.format {
+	case "json":
+		return d.json.Encode(difference)
+	case "csv":
+		for _, field := range difference.Fields {
+			err := d.csv.Write([]string{
+				difference.Table, strconv.Itoa(difference.ID), string(difference.Operation),
+				field.Name, diffValue(field.Old, false), diffValue(field.New, false),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return d.csv.Error()
+	default:
+		sign := map[database.Operation]string{
+			database.OperationInsert: "+",
+			database.OperationDelete: "-",
+			database.OperationUpdate: "~",
+		}[difference.Operation]
+		if _, err := fmt.Fprintf(d.w, "%s %s %d\n", sign, difference.Table, difference.ID); err != nil {
+			return err
+		}
+		if difference.Operation != database.OperationUpdate {
+			return nil
+		}
+		for _, field := range difference.Fields {
+			_, err := fmt.Fprintf(d.w, "    %s: %s -> %s\n", field.Name, diffValue(field.Old, true), diffValue(field.New, true))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// finish writes the summary of the differences, only as text, and flushes
+// the output.
+func (d *diffWriter) finish(summaries []database.DiffSummary) error {
+	if d.format == "csv" {
+		d.csv.Flush()
+		if err := d.csv.Error(); err != nil {
+			return err
+		}
+	}
+
+	if d.format == "text" {
+		different := false
+		for _, s := range summaries {
+			if s.Added+s.Removed+s.Changed == 0 {
+				continue
+			}
+			if !different {
+				fmt.Fprintln(d.w)
+				different = true
+			}
+			fmt.Fprintf(d.w, "%s: %d added, %d removed, %d changed\n", s.Table, s.Added, s.Removed, s.Changed)
+		}
+		if !different {
+			fmt.Fprintln(d.w, "No differences")
+		}
+	}
+
+	return d.w.Flush()
+}
+
+var databaseDiffCmd = &cobra.Command{
+	Use:   "diff <a.db> <b.db>",
+	Short: "Compare two databases",
+	Long: `Compare the tables of two databases or compressed snapshots row by row, matching
+rows by ID, and report the rows added to, removed from and changed in the second
+one with their differing fields.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var options database.DiffOptions
+		var err error
+		options.Tables, _ = cmd.Flags().GetStringSlice("table")
+		filters, _ := cmd.Flags().GetStringArray("filter")
+		if options.Filters, err = parseDiffFilters(filters); err != nil {
+			return err
+		}
+		format, _ := cmd.Flags().GetString("output")
+		w, err := newDiffWriter(format, os.Stdout)
+		if err != nil {
+			return err
+		}
+
+		a, closeA, err := openDiffDatabase(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open the first database: %w", err)
+		}
+		defer closeA()
+		b, closeB, err := openDiffDatabase(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to open the second database: %w", err)
+		}
+		defer closeB()
+
+		summaries, err := database.Diff(a, b, database.GetSchema(), options, w.write)
+		if err != nil {
+			return err
+		}
+		for _, s := range summaries {
+			slog.Debug("table compared", "table", s.Table, "added", s.Added, "removed", s.Removed, "changed", s.Changed)
+		}
+
+		return w.finish(summaries)
+	},
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/gmazoyer/peeringdb-sync/export"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	exportCmd.Flags().String("format", export.FormatCSV, "File format, one of "+strings.Join(export.Formats, ", "))
+	exportCmd.Flags().StringSliceP("table", "t", nil, "Tables or views to export (default all)")
+	exportCmd.Flags().StringP("output", "o", ".", "Directory to write the files to, - to write a single table to the standard output")
+	exportCmd.Flags().Bool("gzip", false, "Compress CSV files with gzip")
+	addSnapshotFlag(exportCmd)
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export tables and views as CSV or Parquet",
+	Long: `Export tables and views to files named after them, as CSV (optionally gzip
+compressed) or Parquet, with columns typed after their SQL type. Rows are
+written as they are read so that large tables can be exported without holding
+them in memory.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options := export.Options{}
+		options.Format, _ = cmd.Flags().GetString("format")
+		if !slices.Contains(export.Formats, options.Format) {
+			return fmt.Errorf("unknown format %q, expected one of %s", options.Format, strings.Join(export.Formats, ", "))
+		}
+		options.Gzip, _ = cmd.Flags().GetBool("gzip")
+		if options.Gzip && options.Format != export.FormatCSV {
+			return errors.New("only CSV files can be compressed with --gzip")
+		}
+		tables, _ := cmd.Flags().GetStringSlice("table")
+		output, _ := cmd.Flags().GetString("output")
+		if output == "-" && len(tables) != 1 {
+			return errors.New("exactly one table must be given to write to the standard output")
+		}
+
+		db, closeDatabase, err := openDatabase(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to connect to the database: %w", err)
+		}
+		defer closeDatabase()
+
+		sources, err := export.Sources(db, database.GetSchema(), tables)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if output == "-" {
+			w := bufio.NewWriter(os.Stdout)
+			if _, err = export.Write(ctx, db, sources[0], options, w); err != nil {
+				return fmt.Errorf("failed to export %s: %w", sources[0].Name, err)
+			}
+			return w.Flush()
+		}
+
+		if err = os.MkdirAll(output, 0o755); err != nil {
+			return err
+		}
+		for _, source := range sources {
+			path, count, err := export.WriteFile(ctx, db, source, options, output)
+			if err != nil {
+				return fmt.Errorf("failed to export %s: %w", source.Name, err)
+			}
+			slog.Info("table exported", "table", source.Name, "file", path, "rows", count)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/gmazoyer/peeringdb-sync/generate"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	generateBGPCmd.Flags().String("local-asn", "", "Local AS number to configure sessions for")
+	generateBGPCmd.Flags().String("ix", "", "ID or name of the Internet exchange to configure sessions on")
+	generateBGPCmd.Flags().Int("ixlan", 0, "ID of the IX LAN to configure sessions on")
+	generateBGPCmd.Flags().StringSlice("peer", nil, "AS numbers of the peers to configure, all IX members if not set")
+	generateBGPCmd.Flags().StringSlice("policy", nil, "Only configure peers with one of these general peering policies (Open, Selective, Restrictive, No)")
+	generateBGPCmd.Flags().StringP("template", "t", "bird2", "Built-in template ("+strings.Join(generate.BGPTemplates, ", ")+") or path to a template file")
+	generateBGPCmd.MarkFlagRequired("local-asn")
+	generateBGPCmd.MarkFlagsOneRequired("ix", "ixlan")
+	generateBGPCmd.MarkFlagsMutuallyExclusive("ix", "ixlan")
+
+	generateRSClientsCmd.Flags().String("ix", "", "ID or name of the Internet exchange")
+	generateRSClientsCmd.Flags().String("format", "arouteserver", "Output format, one of "+strings.Join(generate.RSClientFormats, ", "))
+	generateRSClientsCmd.MarkFlagRequired("ix")
+
+	addSnapshotFlag(generateCmd)
+	generateCmd.AddCommand(generateBGPCmd)
+	generateCmd.AddCommand(generateRSClientsCmd)
+	rootCmd.AddCommand(generateCmd)
+}
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate configurations from the database",
+	Long:  `Generate router configurations from the records stored in the database.`,
+}
+
+var generateBGPCmd = &cobra.Command{
+	Use:   "bgp",
+	Short: "Generate BGP neighbor configuration for IX peers",
+	Long: `Generate BGP neighbor configuration for the members of an Internet exchange.
+
+One session is generated per IP address of each peer, with the number of
+prefixes announced by the peer according to PeeringDB used as max-prefix.
+Custom templates use the Go text/template syntax and receive the local ASN as
+.LocalASN and the sessions as .Sessions.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, _ := cmd.Flags().GetString("local-asn")
+		localASN, err := parseASN(value)
+		if err != nil {
+			return err
+		}
+
+		filter := database.BGPPeerFilter{ExcludeASN: localASN}
+		filter.IXLANID, _ = cmd.Flags().GetInt("ixlan")
+		filter.Policies, _ = cmd.Flags().GetStringSlice("policy")
+		peers, _ := cmd.Flags().GetStringSlice("peer")
+		for _, peer := range peers {
+			asn, err := parseASN(peer)
+			if err != nil {
+				return err
+			}
+			filter.ASNs = append(filter.ASNs, asn)
+		}
+
+		name, _ := cmd.Flags().GetString("template")
+		t, err := generate.LoadBGPTemplate(name)
+		if err != nil {
+			return fmt.Errorf("failed to load the template: %w", err)
+		}
+
+		db, closeDatabase, err := openDatabase(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to connect to the database: %w", err)
+		}
+		defer closeDatabase()
+
+		if ix, _ := cmd.Flags().GetString("ix"); ix != "" {
+			filter.IXID, err = database.ResolveExchangeID(db, ix)
+			if err != nil {
+				return fmt.Errorf("Internet exchange %q: %w", ix, err)
+			}
+		}
+
+		bgpPeers, err := database.GetBGPPeers(db, filter)
+		if err != nil {
+			return fmt.Errorf("failed to query the database: %w", err)
+		}
+		if len(bgpPeers) == 0 {
+			return errors.New("no peer matches the given filters")
+		}
+		slog.Debug("peers found", "count", len(bgpPeers))
+
+		return generate.BGP(os.Stdout, t, generate.NewBGPData(localASN, bgpPeers))
+	},
+}
+
+var generateRSClientsCmd = &cobra.Command{
+	Use:   "rs-clients",
+	Short: "Generate the route server client list of an IX",
+	Long: `Generate the route server client list of an Internet exchange from the operational
+connections to its IX LANs, grouped by ASN with their addresses, IRR AS-SETs
+and prefix limits. IX LANs having a route server ASN are flagged.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		if !slices.Contains(generate.RSClientFormats, format) {
+			return fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(generate.RSClientFormats, ", "))
+		}
+
+		db, closeDatabase, err := openDatabase(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to connect to the database: %w", err)
+		}
+		defer closeDatabase()
+
+		ix, _ := cmd.Flags().GetString("ix")
+		var name string
+		id, err := database.ResolveExchangeID(db, ix)
+		if err == nil {
+			name, err = database.GetExchangeName(db, id)
+		}
+		if err != nil {
+			return fmt.Errorf("Internet exchange %q: %w", ix, err)
+		}
+
+		lans, err := database.GetExchangeLANs(db, id)
+		if err != nil {
+			return fmt.Errorf("failed to query the database: %w", err)
+		}
+		peers, err := database.GetBGPPeers(db, database.BGPPeerFilter{IXID: id, Operational: true})
+		if err != nil {
+			return fmt.Errorf("failed to query the database: %w", err)
+		}
+
+		return generate.NewRSClientList(id, name, lans, peers).Write(os.Stdout, format)
+	},
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/spf13/cobra"
+)
+
+// addNearFlags adds the flags of geospatial queries to the command.
+func addNearFlags(cmd *cobra.Command) {
+	cmd.Flags().String("near", "", "List the objects closest to this point, given as latitude,longitude")
+	cmd.Flags().String("radius", "", "Only list objects within this distance of the point, e.g. 50km, 500m or 20mi")
+	cmd.Flags().String("asn", "", "Only list objects where this network is present")
+	cmd.Flags().Int("limit", 0, "Maximum number of objects to list, 0 for no limit")
+}
+
+// nearArgs requires no argument for geospatial queries and one otherwise.
+func nearArgs(cmd *cobra.Command, args []string) error {
+	if near, _ := cmd.Flags().GetString("near"); near != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("--near cannot be used along with %q", args[0])
+		}
+		return nil
+	}
+	return cobra.ExactArgs(1)(cmd, args)
+}
+
+// parseDistance parses a distance with an optional unit (km, m or mi) and
+// returns it in kilometers. Kilometers are assumed if there is no unit.
+func parseDistance(value string) (float64, error) {
+	units := []struct {
+		suffix string
+		factor float64
+	}{{"km", 1}, {"mi", 1.609344}, {"m", 0.001}, {"", 1}}
+
+	for _, unit := range units {
+		if number, found := strings.CutSuffix(strings.ToLower(strings.TrimSpace(value)), unit.suffix); found {
+			distance, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			if err != nil || distance <= 0 {
+				break
+			}
+			return distance * unit.factor, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid distance %q", value)
+}
+
+// parseGeoFilter builds the geospatial filter from the flags of the command.
+func parseGeoFilter(cmd *cobra.Command) (database.GeoFilter, error) {
+	var filter database.GeoFilter
+
+	near, _ := cmd.Flags().GetString("near")
+	latitude, longitude, found := strings.Cut(near, ",")
+	var err error
+	if found {
+		filter.Latitude, err = strconv.ParseFloat(strings.TrimSpace(latitude), 64)
+		if err == nil {
+			filter.Longitude, err = strconv.ParseFloat(strings.TrimSpace(longitude), 64)
+		}
+	}
+	if !found || err != nil || filter.Latitude < -90 || filter.Latitude > 90 || filter.Longitude < -180 || filter.Longitude > 180 {
+		return filter, fmt.Errorf("invalid point %q, expected latitude,longitude", near)
+	}
+
+	if radius, _ := cmd.Flags().GetString("radius"); radius != "" {
+		if filter.Radius, err = parseDistance(radius); err != nil {
+			return filter, err
+		}
+	}
+	if asn, _ := cmd.Flags().GetString("asn"); asn != "" {
+		if filter.ASN, err = parseASN(asn); err != nil {
+			return filter, err
+		}
+	}
+	filter.Limit, _ = cmd.Flags().GetInt("limit")
+
+	return filter, nil
+}
+
+// runNear runs a geospatial query and renders its results.
+func runNear(cmd *cobra.Command, description string, lookup func(*sql.DB, database.GeoFilter) ([]database.GeoResult, error)) error {
+	filter, err := parseGeoFilter(cmd)
+	if err != nil {
+		return err
+	}
+
+	return runQuery(cmd, description, func(db *sql.DB) ([]database.GeoResult, error) {
+		return lookup(db, filter)
+	}, func(w io.Writer, results []database.GeoResult) {
+		fmt.Fprintln(w, "ID\tNAME\tCITY\tCOUNTRY\tDISTANCE")
+		for _, r := range results {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%.1f km\n", r.ID, r.Name, r.City, r.Country, r.Distance)
+		}
+	})
+}
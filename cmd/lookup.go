@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	addSnapshotFlag(lookupCmd)
+	lookupCmd.PersistentFlags().StringP("output", "o", "table", "Output format, one of "+strings.Join(outputFormats, ", "))
+
+	lookupCmd.AddCommand(lookupIPCmd)
+	rootCmd.AddCommand(lookupCmd)
+}
+
+var lookupCmd = &cobra.Command{
+	Use:   "lookup",
+	Short: "Find the records matching a value",
+	Long:  `Find the records of the database matching a value.`,
+}
+
+var lookupIPCmd = &cobra.Command{
+	Use:   "ip <address>",
+	Short: "Find the IX and the network using an IP address",
+	Long:  `Find the IX LAN whose prefixes contain an IP address and the networks using this address on it.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, err := netip.ParseAddr(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid IP address %q", args[0])
+		}
+
+		return runQuery(cmd, fmt.Sprintf("address %s", addr), func(db *sql.DB) (*database.IPLookup, error) {
+			return database.LookupIP(db, addr)
+		}, func(w io.Writer, l *database.IPLookup) {
+			writeFields(w, "Address", l.Address)
+			if l.Prefix != "" {
+				writeFields(w,
+					"Prefix", l.Prefix,
+					"Internet exchange", fmt.Sprintf("%s (%d)", l.IXName, l.IXID),
+					"IX LAN", l.IXLANID,
+				)
+			}
+			writeSection(w, "Networks", len(l.Owners), "ASN\tNAME\tIX LAN\tSPEED\tRS PEER\tOPERATIONAL", func(i int) string {
+				o := l.Owners[i]
+				return fmt.Sprintf("%d\t%s\t%d\t%d\t%s\t%s", o.ASN, o.Name, o.IXLANID, o.Speed, yesNo(o.IsRSPeer), yesNo(o.Operational))
+			})
+		})
+	},
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormats lists the formats accepted by the --output flags.
+var outputFormats = []string{"table", "json", "yaml"}
+
+// render writes the value to the standard output in the given format. The
+// table function is used to write it in a human readable way.
+func render(format string, value interface{}, table func(w io.Writer)) error {
+	switch format {
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case "yaml":
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(outputFormats, ", "))
+	}
+}
+
+// writeFields writes name and value pairs, skipping empty values.
+func writeFields(w io.Writer, fields ...interface{}) {
+	for i := 0; i+1 < len(fields); i += 2 {
+		value := fmt.Sprint(fields[i+1])
+		if value == "" {
+			continue
+		}
+		fmt.Fprintf(w, "%s:\t%s\n", fields[i], value)
+	}
+}
+
+// writeSection writes a titled list of rows with a header line.
+func writeSection(w io.Writer, title string, count int, header string, row func(i int) string) {
+	fmt.Fprintf(w, "\n%s (%d)\n", title, count)
+	if count == 0 {
+		return
+	}
+	fmt.Fprintln(w, header)
+	for i := 0; i < count; i++ {
+		fmt.Fprintln(w, row(i))
+	}
+}
+
+// yesNo formats a boolean for tables.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
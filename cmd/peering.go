@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	peeringCommonCmd.Flags().Bool("ix-only", false, "Only list the shared Internet exchanges")
+	peeringCommonCmd.Flags().Bool("fac-only", false, "Only list the shared facilities")
+	peeringCommonCmd.Flags().StringP("output", "o", "table", "Output format, one of "+strings.Join(outputFormats, ", "))
+	addSnapshotFlag(peeringCmd)
+	peeringCommonCmd.MarkFlagsMutuallyExclusive("ix-only", "fac-only")
+
+	peeringCmd.AddCommand(peeringCommonCmd)
+	rootCmd.AddCommand(peeringCmd)
+}
+
+var peeringCmd = &cobra.Command{
+	Use:   "peering",
+	Short: "Find peering opportunities",
+	Long:  `Find where networks can peer with each other.`,
+}
+
+var peeringCommonCmd = &cobra.Command{
+	Use:   "common <asn> <asn> [asn...]",
+	Short: "List the locations shared by networks",
+	Long:  `List the Internet exchanges and the facilities where all the given networks are present.`,
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var asns []int
+		for _, arg := range args {
+			asn, err := parseASN(arg)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(asns, asn) {
+				asns = append(asns, asn)
+			}
+		}
+		if len(asns) < 2 {
+			return errors.New("at least two different AS numbers are required")
+		}
+
+		ixOnly, _ := cmd.Flags().GetBool("ix-only")
+		facOnly, _ := cmd.Flags().GetBool("fac-only")
+
+		db, closeDatabase, err := openDatabase(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to connect to the database: %w", err)
+		}
+		defer closeDatabase()
+
+		locations, err := database.GetCommonLocations(db, asns, !facOnly, !ixOnly)
+		if err != nil {
+			return fmt.Errorf("failed to query the database: %w", err)
+		}
+
+		format, _ := cmd.Flags().GetString("output")
+		return render(format, locations, func(w io.Writer) {
+			if !facOnly {
+				fmt.Fprintf(w, "Internet exchanges (%d)\n", len(locations.Exchanges))
+				for _, ix := range locations.Exchanges {
+					fmt.Fprintf(w, "\n%s (%d), %s, %s\n", ix.Name, ix.ID, ix.City, ix.Country)
+					fmt.Fprintln(w, "ASN\tSPEED\tIPV4\tIPV6\tRS PEER")
+					for _, c := range ix.Connections {
+						fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\n", c.ASN, c.Speed, c.IPAddr4, c.IPAddr6, yesNo(c.IsRSPeer))
+					}
+				}
+			}
+			if !ixOnly {
+				writeSection(w, "Facilities", len(locations.Facilities), "FAC ID\tNAME\tCITY\tCOUNTRY", func(i int) string {
+					f := locations.Facilities[i]
+					return fmt.Sprintf("%d\t%s\t%s\t%s", f.ID, f.Name, f.City, f.Country)
+				})
+			}
+		})
+	},
+}
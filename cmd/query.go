@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	queryCmd.PersistentFlags().StringP("output", "o", "table", "Output format, one of "+strings.Join(outputFormats, ", "))
+	addSnapshotFlag(queryCmd)
+	addNearFlags(queryFacCmd)
+	addNearFlags(queryOrgCmd)
+
+	queryCmd.AddCommand(queryNetCmd)
+	queryCmd.AddCommand(queryIXCmd)
+	queryCmd.AddCommand(queryFacCmd)
+	queryCmd.AddCommand(queryOrgCmd)
+	rootCmd.AddCommand(queryCmd)
+}
+
+// parseASN parses an AS number given with or without the AS prefix.
+func parseASN(value string) (int, error) {
+	asn, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(value), "AS"))
+	if err != nil || asn <= 0 {
+		return 0, fmt.Errorf("invalid AS number %q", value)
+	}
+	return asn, nil
+}
+
+// runQuery opens the database, runs the lookup of the described record and
+// renders its result in the format requested on the command line.
+func runQuery[T any](cmd *cobra.Command, description string, lookup func(*sql.DB) (T, error), table func(io.Writer, T)) error {
+	db, closeDatabase, err := openDatabase(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	defer closeDatabase()
+
+	result, err := lookup(db)
+	if err != nil {
+		var ambiguous *database.AmbiguousError
+		if errors.As(err, &ambiguous) || errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("%s: %w", description, err)
+		}
+		return fmt.Errorf("failed to query the database: %w", err)
+	}
+
+	format, _ := cmd.Flags().GetString("output")
+	return render(format, result, func(w io.Writer) { table(w, result) })
+}
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Look up records in the database",
+	Long:  `Look up networks, Internet exchanges, facilities and organizations in the local database along with their related records.`,
+}
+
+var queryNetCmd = &cobra.Command{
+	Use:   "net <asn>",
+	Short: "Look up a network by AS number",
+	Long:  `Show a network with the Internet exchanges and facilities where it is present.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asn, err := parseASN(args[0])
+		if err != nil {
+			return err
+		}
+
+		return runQuery(cmd, fmt.Sprintf("network AS%d", asn), func(db *sql.DB) (*database.NetworkDetails, error) {
+			return database.NewStore(db).DescribeNetwork(asn)
+		}, func(w io.Writer, n *database.NetworkDetails) {
+			writeFields(w,
+				"Name", n.Name, "ASN", n.ASN, "AKA", n.AKA, "ID", n.ID, "Status", n.Status,
+				"Organization", fmt.Sprintf("%s (%d)", n.Organization, n.OrgID), "Website", n.Website,
+				"IRR AS-SET", n.IRRASSet, "Type", n.InfoType, "Policy", n.PolicyGeneral, "Policy URL", n.PolicyURL,
+			)
+			writeSection(w, "Internet exchanges", len(n.Exchanges), "IX ID\tNAME\tSPEED\tIPV4\tIPV6\tRS PEER", func(i int) string {
+				e := n.Exchanges[i]
+				return fmt.Sprintf("%d\t%s\t%d\t%s\t%s\t%s", e.IXID, e.IXName, e.Speed, e.IPAddr4, e.IPAddr6, yesNo(e.IsRSPeer))
+			})
+			writeSection(w, "Facilities", len(n.Facilities), "FAC ID\tNAME\tCITY\tCOUNTRY", func(i int) string {
+				f := n.Facilities[i]
+				return fmt.Sprintf("%d\t%s\t%s\t%s", f.FacID, f.Name, f.City, f.Country)
+			})
+		})
+	},
+}
+
+var queryIXCmd = &cobra.Command{
+	Use:   "ix <id|name>",
+	Short: "Look up an Internet exchange by ID or name",
+	Long:  `Show an Internet exchange with its prefixes, facilities and members.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(cmd, fmt.Sprintf("Internet exchange %q", args[0]), func(db *sql.DB) (*database.ExchangeDetails, error) {
+			return database.NewStore(db).DescribeExchange(args[0])
+		}, func(w io.Writer, ix *database.ExchangeDetails) {
+			writeFields(w,
+				"Name", ix.Name, "Long name", ix.NameLong, "ID", ix.ID, "Status", ix.Status,
+				"Location", fmt.Sprintf("%s, %s", ix.City, ix.Country),
+				"Organization", fmt.Sprintf("%s (%d)", ix.Organization, ix.OrgID), "Website", ix.Website,
+				"Prefixes", strings.Join(ix.Prefixes, ", "),
+			)
+			writeSection(w, "Facilities", len(ix.Facilities), "FAC ID\tNAME\tCITY\tCOUNTRY", func(i int) string {
+				f := ix.Facilities[i]
+				return fmt.Sprintf("%d\t%s\t%s\t%s", f.ID, f.Name, f.City, f.Country)
+			})
+			writeSection(w, "Members", len(ix.Members), "ASN\tNAME\tSPEED\tIPV4\tIPV6\tRS PEER", func(i int) string {
+				m := ix.Members[i]
+				return fmt.Sprintf("%d\t%s\t%d\t%s\t%s\t%s", m.ASN, m.Name, m.Speed, m.IPAddr4, m.IPAddr6, yesNo(m.IsRSPeer))
+			})
+		})
+	},
+}
+
+var queryFacCmd = &cobra.Command{
+	Use:   "fac <id|name> | --near <lat,lon>",
+	Short: "Look up a facility by ID, name or location",
+	Long: `Show a facility with the Internet exchanges and networks present in it.
+
+With --near, list the facilities closest to a point instead, optionally
+within a radius and where a network is present.`,
+	Args: nearArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return runNear(cmd, "facilities", database.FacilitiesNear)
+		}
+
+		return runQuery(cmd, fmt.Sprintf("facility %q", args[0]), func(db *sql.DB) (*database.FacilityDetails, error) {
+			return database.NewStore(db).DescribeFacility(args[0])
+		}, func(w io.Writer, f *database.FacilityDetails) {
+			address := strings.Join(strings.Fields(strings.Join([]string{f.Address1, f.Address2, f.Zipcode, f.City, f.State, f.Country}, " ")), " ")
+			writeFields(w,
+				"Name", f.Name, "ID", f.ID, "Status", f.Status, "Address", address,
+				"Organization", fmt.Sprintf("%s (%d)", f.Organization, f.OrgID), "Website", f.Website,
+			)
+			if f.Latitude != nil && f.Longitude != nil {
+				writeFields(w, "Coordinates", fmt.Sprintf("%f, %f", *f.Latitude, *f.Longitude))
+			}
+			writeSection(w, "Internet exchanges", len(f.Exchanges), "IX ID\tNAME\tCITY\tCOUNTRY", func(i int) string {
+				e := f.Exchanges[i]
+				return fmt.Sprintf("%d\t%s\t%s\t%s", e.ID, e.Name, e.City, e.Country)
+			})
+			writeSection(w, "Networks", len(f.Networks), "ASN\tNAME", func(i int) string {
+				n := f.Networks[i]
+				return fmt.Sprintf("%d\t%s", n.ASN, n.Name)
+			})
+		})
+	},
+}
+
+var queryOrgCmd = &cobra.Command{
+	Use:   "org <id> | --near <lat,lon>",
+	Short: "Look up an organization by ID or location",
+	Long: `Show an organization with the networks, Internet exchanges and facilities it owns.
+
+With --near, list the organizations closest to a point instead, optionally
+within a radius and operating a network.`,
+	Args: nearArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return runNear(cmd, "organizations", database.OrganizationsNear)
+		}
+
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid organization ID %q", args[0])
+		}
+
+		return runQuery(cmd, fmt.Sprintf("organization %d", id), func(db *sql.DB) (*database.OrganizationDetails, error) {
+			return database.NewStore(db).DescribeOrganization(id)
+		}, func(w io.Writer, o *database.OrganizationDetails) {
+			writeFields(w,
+				"Name", o.Name, "AKA", o.AKA, "ID", o.ID, "Status", o.Status, "Website", o.Website,
+				"Location", strings.Trim(fmt.Sprintf("%s, %s", o.City, o.Country), ", "),
+			)
+			writeSection(w, "Networks", len(o.Networks), "ASN\tNAME", func(i int) string {
+				n := o.Networks[i]
+				return fmt.Sprintf("%d\t%s", n.ASN, n.Name)
+			})
+			writeSection(w, "Internet exchanges", len(o.Exchanges), "IX ID\tNAME\tCITY\tCOUNTRY", func(i int) string {
+				e := o.Exchanges[i]
+				return fmt.Sprintf("%d\t%s\t%s\t%s", e.ID, e.Name, e.City, e.Country)
+			})
+			writeSection(w, "Facilities", len(o.Facilities), "FAC ID\tNAME\tCITY\tCOUNTRY", func(i int) string {
+				f := o.Facilities[i]
+				return fmt.Sprintf("%d\t%s\t%s\t%s", f.ID, f.Name, f.City, f.Country)
+			})
+		})
+	},
+}
@@ -1,34 +1,44 @@
 package cmd
 
 import (
-	"fmt"
+	"log/slog"
 	"os"
+	"strings"
 
+	"github.com/gmazoyer/peeringdb-sync/config"
 	"github.com/spf13/cobra"
 )
 
 var PeeringdbApiKey, PeeringdbDbFile string
+var logLevel, logFormat string
 var rootCmd = &cobra.Command{
 	Use:   "peeringdb-sync",
 	Short: "Synchronize PeeringDB records locally",
 	Long:  `Synchronize PeeringDB data to a local database. The use of an API key is highly recommended.`,
-}
-
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
-	}
-	return fallback
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfiguration(cmd); err != nil {
+			return err
+		}
+		return setupLogging(Configuration.Log.Level, Configuration.Log.Format)
+	},
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&PeeringdbApiKey, "api-key", "k", getEnv("PEERINGDB_API_KEY", ""), "PeeringDB API key to use for authentication")
-	rootCmd.PersistentFlags().StringVarP(&PeeringdbDbFile, "file", "f", getEnv("PEERINGDB_DATABASE_FILE", "peeringdb.db"), "Path to the file to use as SQLite database")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Path to the YAML configuration file, TOML is not supported (default: first of "+strings.Join(config.SearchPaths(), ", ")+")")
+	rootCmd.PersistentFlags().StringVarP(&PeeringdbApiKey, "api-key", "k", "", "PeeringDB API key to use for authentication (env PEERINGDB_API_KEY)")
+	rootCmd.PersistentFlags().String("api-key-file", "", "Path to a file containing the PeeringDB API key (env PEERINGDB_API_KEY_FILE)")
+	rootCmd.PersistentFlags().String("api-key-command", "", "Shell command printing the PeeringDB API key, e.g. \"pass show peeringdb\"")
+	rootCmd.PersistentFlags().StringVarP(&PeeringdbDbFile, "file", "f", "peeringdb.db", "Path to the file to use as SQLite database (env PEERINGDB_DATABASE_FILE)")
+	rootCmd.PersistentFlags().String("snapshot-dir", "", "Directory where database snapshots are stored (env PEERINGDB_SNAPSHOT_DIR)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Minimum level of the logs to output (debug, info, warn or error)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Format of the logs (text or json)")
 }
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		slog.Error(err.Error())
 		os.Exit(1)
 	}
 }
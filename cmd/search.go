@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	searchCmd.Flags().StringSliceP("type", "t", nil, "Only search objects of these types ("+strings.Join(database.SearchTypes(), ", ")+")")
+	searchCmd.Flags().IntP("limit", "l", 20, "Maximum number of results")
+	searchCmd.Flags().StringP("output", "o", "table", "Output format, one of "+strings.Join(outputFormats, ", "))
+	addSnapshotFlag(searchCmd)
+
+	rootCmd.AddCommand(searchCmd)
+}
+
+var searchCmd = &cobra.Command{
+	Use:   "search <terms>...",
+	Short: "Search organizations, networks, facilities and IXs",
+	Long: `Search organizations, networks, facilities, IXs, campuses and carriers by name, alias,
+long name, notes, city and website. Objects matching all the terms are listed,
+the best matches first. Terms also match words starting with them.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		types, _ := cmd.Flags().GetStringSlice("type")
+		for _, t := range types {
+			if !slices.Contains(database.SearchTypes(), t) {
+				return fmt.Errorf("unknown type %q, expected one of %s", t, strings.Join(database.SearchTypes(), ", "))
+			}
+		}
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		db, closeDatabase, err := openDatabase(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to connect to the database: %w", err)
+		}
+		defer closeDatabase()
+
+		results, err := database.Search(db, args, types, limit)
+		if err != nil {
+			if errors.Is(err, database.ErrSearchUnavailable) {
+				return err
+			}
+			return fmt.Errorf("failed to search the database: %w", err)
+		}
+
+		format, _ := cmd.Flags().GetString("output")
+		return render(format, results, func(w io.Writer) {
+			fmt.Fprintln(w, "TYPE\tID\tNAME\tASN\tCITY\tMATCH")
+			for _, r := range results {
+				asn := ""
+				if r.ASN != 0 {
+					asn = strconv.Itoa(r.ASN)
+				}
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", r.Type, r.ID, r.Name, asn, r.City, strings.Join(strings.Fields(r.Snippet), " "))
+			}
+		})
+	},
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/gmazoyer/peeringdb-sync/snapshot"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	addRetentionFlags(snapshotTakeCmd)
+	addRetentionFlags(snapshotPruneCmd)
+	snapshotListCmd.Flags().StringP("output", "o", "table", "Output format, one of "+strings.Join(outputFormats, ", "))
+
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotTakeCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+	snapshotCmd.AddCommand(snapshotPruneCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+// addRetentionFlags adds the flags overriding the snapshot retention policy.
+func addRetentionFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("keep-daily", 0, "Number of days to keep the last snapshot of (default from the configuration, all if both are 0)")
+	cmd.Flags().Int("keep-weekly", 0, "Number of weeks to keep the last snapshot of (default from the configuration, all if both are 0)")
+}
+
+// addSnapshotFlag adds the flag selecting the snapshot to read instead of
+// the database.
+func addSnapshotFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("snapshot", "", "Read a snapshot instead of the database: latest, a snapshot name or path, a date or a time")
+}
+
+// snapshotDir returns the directory where snapshots are stored.
+func snapshotDir() (string, error) {
+	if Configuration.Snapshots.Dir == "" {
+		return "", errors.New("no snapshot directory, set it with --snapshot-dir or in the configuration")
+	}
+	return Configuration.Snapshots.Dir, nil
+}
+
+// findSnapshot returns the snapshot designated by the reference, only paths
+// being usable without snapshot directory.
+func findSnapshot(ref string) (*snapshot.Snapshot, error) {
+	dir := Configuration.Snapshots.Dir
+	if dir == "" {
+		if _, err := os.Stat(ref); err != nil {
+			_, err = snapshotDir()
+			return nil, err
+		}
+	}
+	return snapshot.Find(dir, ref)
+}
+
+// openDatabase opens the database or, if one is selected on the command
+// line, the snapshot to read. The returned function closes it.
+func openDatabase(cmd *cobra.Command) (*sql.DB, func(), error) {
+	ref, _ := cmd.Flags().GetString("snapshot")
+	if ref == "" {
+		db, err := database.GetDatabaseConnection(PeeringdbDbFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, func() { db.Close() }, nil
+	}
+
+	s, err := findSnapshot(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.Debug("reading snapshot", "snapshot", s.Path, "time", s.Time)
+
+	return snapshot.Open(s)
+}
+
+// takeSnapshot takes a snapshot of the database and removes the ones not
+// retained anymore.
+func takeSnapshot(ctx context.Context, db *sql.DB, dir string) error {
+	s, err := snapshot.Take(ctx, db, dir, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to take a snapshot: %w", err)
+	}
+	slog.Info("snapshot taken", "snapshot", s.Path, "size", s.Size)
+
+	removed, err := snapshot.Prune(dir, Configuration.Snapshots.KeepDaily, Configuration.Snapshots.KeepWeekly)
+	for _, r := range removed {
+		slog.Debug("snapshot removed", "snapshot", r.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove old snapshots: %w", err)
+	}
+
+	return nil
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Manage database snapshots",
+	Long: `List, take, restore and prune compressed point-in-time copies of the database,
+stored in the snapshot directory.`,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the snapshots",
+	Long:  `List the snapshots of the snapshot directory, oldest first.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := snapshotDir()
+		if err != nil {
+			return err
+		}
+
+		snapshots, err := snapshot.List(dir)
+		if err != nil {
+			return fmt.Errorf("failed to list the snapshots: %w", err)
+		}
+
+		format, _ := cmd.Flags().GetString("output")
+		return render(format, snapshots, func(w io.Writer) {
+			fmt.Fprintln(w, "NAME\tTIME\tSIZE")
+			for _, s := range snapshots {
+				fmt.Fprintf(w, "%s\t%s\t%d\n", s.Name(), s.Time.Format(time.RFC3339), s.Size)
+			}
+		})
+	},
+}
+
+var snapshotTakeCmd = &cobra.Command{
+	Use:   "take",
+	Short: "Take a snapshot of the database",
+	Long: `Take a compressed snapshot of the database with the SQLite online backup API,
+then remove the snapshots not retained anymore.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := snapshotDir()
+		if err != nil {
+			return err
+		}
+
+		db, err := database.GetDatabaseConnection(PeeringdbDbFile)
+		if err != nil {
+			return fmt.Errorf("failed to connect to the database: %w", err)
+		}
+		defer db.Close()
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return takeSnapshot(ctx, db, dir)
+	},
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <snapshot>",
+	Short: "Restore the database from a snapshot",
+	Long: `Replace the content of the database with the one of a snapshot, given as latest,
+a snapshot name or path, a date (the last snapshot of that day) or a time (the
+last snapshot taken at or before it).`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := findSnapshot(args[0])
+		if err != nil {
+			return err
+		}
+
+		db, err := database.GetDatabaseConnection(PeeringdbDbFile)
+		if err != nil {
+			return fmt.Errorf("failed to connect to the database: %w", err)
+		}
+		defer db.Close()
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err = snapshot.Restore(ctx, s, db); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", s.Path, err)
+		}
+		slog.Info("snapshot restored", "snapshot", s.Path, "time", s.Time)
+
+		return nil
+	},
+}
+
+var snapshotPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove the snapshots not retained anymore",
+	Long:  `Remove the snapshots not retained by the retention policy.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := snapshotDir()
+		if err != nil {
+			return err
+		}
+
+		removed, err := snapshot.Prune(dir, Configuration.Snapshots.KeepDaily, Configuration.Snapshots.KeepWeekly)
+		for _, r := range removed {
+			slog.Info("snapshot removed", "snapshot", r.Path)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to remove old snapshots: %w", err)
+		}
+
+		return nil
+	},
+}
@@ -1,103 +1,340 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"sync"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/gmazoyer/peeringdb"
+	"github.com/gmazoyer/peeringdb-sync/cdc"
 	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/gmazoyer/peeringdb-sync/metrics"
+	"github.com/gmazoyer/peeringdb-sync/notify"
+	"github.com/gmazoyer/peeringdb-sync/progress"
+	"github.com/gmazoyer/peeringdb-sync/scheduler"
 	"github.com/spf13/cobra"
-	"github.com/vbauerster/mpb/v8"
-	"github.com/vbauerster/mpb/v8/decor"
+	"golang.org/x/term"
 )
 
 func init() {
+	syncCmd.Flags().String("notify-config", "", "Path to a YAML file describing objects to watch and webhooks to notify")
+	syncCmd.Flags().String("cdc-config", "", "Path to a YAML file describing the sinks to deliver changes to")
+	syncCmd.Flags().String("metrics-listen", "", "Address to expose Prometheus metrics on, at /metrics, only until the synchronization ends (see --metrics-file for periodic runs)")
+	syncCmd.Flags().String("metrics-file", "", "Path to a file to write Prometheus metrics to for the textfile collector")
+	syncCmd.Flags().Int("page-size", database.DefaultPageSize, "Number of objects requested at once to the API")
+	syncCmd.Flags().Int("parallelism", 0, "Maximum number of tables synchronized at the same time, no limit if 0")
+	syncCmd.Flags().String("progress", "bars", "Progress reporting, bars (logs if the output is not a terminal) or json")
+	syncCmd.Flags().Int("progress-fd", 1, "File descriptor to write JSON progress events to, 2 by default if changes are written to the standard output")
+	syncCmd.Flags().Duration("timeout", 0, "Maximum duration of the synchronization, no limit if 0")
+	addRetentionFlags(syncCmd)
+
 	rootCmd.AddCommand(syncCmd)
 }
 
-type task struct {
+// syncTask synchronizes a table once the tables it depends on have been
+// synchronized.
+type syncTask struct {
 	name         string
-	dependencies []task
-	function     func(bar *mpb.Bar)
+	table        string
+	dependencies []string
+	function     func(*database.Synchronization, context.Context, progress.Task) error
+}
+
+// syncTasks lists the tables to synchronize and the tasks they depend on.
+var syncTasks = []syncTask{
+	{"Organizations", "peeringdb_organization", nil, (*database.Synchronization).SynchronizeOrganizations},
+	{"Campuses", "peeringdb_campus", []string{"Organizations"}, (*database.Synchronization).SynchronizeCampuses},
+	{"Facilities", "peeringdb_facility", []string{"Organizations", "Campuses"}, (*database.Synchronization).SynchronizeFacilities},
+	{"Carriers", "peeringdb_carrier", []string{"Organizations"}, (*database.Synchronization).SynchronizeCarriers},
+	{"Networks", "peeringdb_network", []string{"Organizations"}, (*database.Synchronization).SynchronizeNetworks},
+	{"Internet Exchanges", "peeringdb_ix", []string{"Organizations"}, (*database.Synchronization).SynchronizeInternetExchanges},
+	{"Internet Exchange Facilities", "peeringdb_ix_facility", []string{"Facilities", "Internet Exchanges"}, (*database.Synchronization).SynchronizeInternetExchangeFacilities},
+	{"Internet Exchange LANs", "peeringdb_ixlan", []string{"Internet Exchanges"}, (*database.Synchronization).SynchronizeInternetExchangeLANs},
+	{"Internet Exchange Prefixes", "peeringdb_ix_prefix", []string{"Internet Exchange LANs"}, (*database.Synchronization).SynchronizeInternetExchangePrefixes},
+	{"Network Contacts", "peeringdb_network_contact", []string{"Networks"}, (*database.Synchronization).SynchronizeNetworkContacts},
+	{"Network Facilities", "peeringdb_network_facility", []string{"Networks", "Facilities"}, (*database.Synchronization).SynchronizeNetworkFacilities},
+	{"Network Internet Exchange LANs", "peeringdb_network_ixlan", []string{"Networks", "Internet Exchanges", "Internet Exchange LANs"}, (*database.Synchronization).SynchronizeNetworkInternetExchangeLANs},
 }
 
-func (t *task) execute(wg *sync.WaitGroup, dependencyChannels []<-chan struct{}, bar *mpb.Bar) {
-	defer wg.Done()
+// newSyncScheduler returns a scheduler running the given synchronization
+// tasks, each one reporting its progress to the reporter.
+func newSyncScheduler(s *database.Synchronization, tasks []syncTask, reporter progress.Reporter) (*scheduler.Scheduler, error) {
+	var scheduled []scheduler.Task
+	reported := make(map[string]progress.Task)
+	for _, t := range tasks {
+		task := reporter.Task(t.name)
+		reported[t.name] = task
+		scheduled = append(scheduled, scheduler.Task{
+			Name:         t.name,
+			Dependencies: t.dependencies,
+			Run: func(ctx context.Context) error {
+				return t.function(s, ctx, task)
+			},
+		})
+	}
+
+	sched, err := scheduler.New(scheduled...)
+	if err != nil {
+		return nil, err
+	}
+
+	sched.OnStart = func(t scheduler.Task) {
+		slog.Debug("task started", "task", t.Name)
+		reported[t.Name].Start()
+	}
+	sched.OnFinish = func(r scheduler.Result) {
+		task := reported[r.Name]
+		task.Finish(r)
 
-	// Wait for dependencies to complete
-	for _, dep := range dependencyChannels {
-		<-dep
+		switch r.Status {
+		case scheduler.StatusSucceeded:
+			metrics.TaskDuration.WithLabelValues(r.Name).Set(r.Duration.Seconds())
+			slog.Info("task completed", "task", r.Name, "records", task.Current(), "duration", r.Duration)
+		case scheduler.StatusFailed:
+			metrics.TaskDuration.WithLabelValues(r.Name).Set(r.Duration.Seconds())
+			if errors.Is(r.Err, context.Canceled) || errors.Is(r.Err, context.DeadlineExceeded) {
+				slog.Warn("task interrupted", "task", r.Name, "records", task.Current(), "duration", r.Duration)
+			} else {
+				slog.Error("task failed", "task", r.Name, "error", r.Err, "duration", r.Duration)
+			}
+		default:
+			slog.Warn("task "+r.Status.String(), "task", r.Name, "reason", r.Err)
+		}
 	}
 
-	t.function(bar)
+	return sched, nil
+}
+
+// newReporter returns the progress reporter selected with the given mode.
+// Progress bars are displayed on the standard error instead of the standard
+// output if the latter is used for something else.
+func newReporter(mode string, fd int, stdoutTaken bool) (progress.Reporter, error) {
+	switch mode {
+	case "bars":
+		output := os.Stdout
+		if stdoutTaken {
+			output = os.Stderr
+		}
+		// Progress bars are only useful when someone is watching
+		return progress.NewBars(output, term.IsTerminal(int(output.Fd()))), nil
+	case "json":
+		if fd == 1 && stdoutTaken {
+			return nil, errors.New("JSON progress events cannot be written to the standard output along with the changes, use --progress-fd")
+		}
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+		if f == nil {
+			return nil, fmt.Errorf("invalid file descriptor %d", fd)
+		}
+		return progress.NewJSON(f), nil
+	default:
+		return nil, fmt.Errorf("unknown progress reporting %q, use bars or json", mode)
+	}
 }
 
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Synchronize the database with PeeringDB",
 	Long:  `Synchronize PeeringDB records within the local database, updating existing records, adding new ones and deleting outdated ones.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		start := time.Now()
+
+		pageSize, _ := cmd.Flags().GetInt("page-size")
+		if pageSize < 1 {
+			return errors.New("the page size must be positive")
+		}
+		parallelism, _ := cmd.Flags().GetInt("parallelism")
+		if parallelism < 0 {
+			return errors.New("the parallelism must not be negative")
+		}
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		if timeout < 0 {
+			return errors.New("the timeout must not be negative")
+		}
+
+		// Capture changes for other systems if requested, a dedicated file
+		// takes precedence over the configuration file
+		changes := Configuration.CDC
+		if cdcConfig, _ := cmd.Flags().GetString("cdc-config"); cdcConfig != "" {
+			var err error
+			if changes, err = cdc.LoadConfig(cdcConfig); err != nil {
+				return fmt.Errorf("failed to load the change data capture configuration: %w", err)
+			}
+		}
+		captureChanges := changes != nil && len(changes.Sinks) > 0
+
+		// Changes written to the standard output must not be mixed with the
+		// progress
+		stdoutTaken := captureChanges && changes.WritesToStdout()
+		mode, _ := cmd.Flags().GetString("progress")
+		fd, _ := cmd.Flags().GetInt("progress-fd")
+		if stdoutTaken && !cmd.Flags().Changed("progress-fd") {
+			fd = 2
+		}
+		reporter, err := newReporter(mode, fd, stdoutTaken)
+		if err != nil {
+			return err
+		}
+
+		// Stop cleanly when interrupted or out of time, committed pages are
+		// kept and resumed by the next run
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
 		db, err := database.GetDatabaseConnection(PeeringdbDbFile)
 		if err != nil {
-			fmt.Printf("Failed to connect to the database: %s\n", err.Error())
-			return
+			return fmt.Errorf("failed to connect to the database: %w", err)
+		}
+
+		// Databases created by older versions lack some columns
+		if err = database.Migrate(db, database.GetSchema()); err != nil {
+			return fmt.Errorf("failed to migrate the database: %w", err)
+		}
+
+		// Tables left behind by an interrupted run are resumed
+		checkpoints, err := database.GetCheckpoints(db)
+		if err != nil {
+			return fmt.Errorf("failed to read the synchronization checkpoints: %w", err)
+		}
+		for _, c := range checkpoints {
+			slog.Warn("last synchronization did not complete", "table", c.Table, "started", c.Started, "written", c.Skip)
+		}
+
+		if address := Configuration.Metrics.Listen; address != "" {
+			go func() {
+				if err := metrics.Serve(address); err != nil {
+					slog.Error("failed to expose metrics", "address", address, "error", err)
+				}
+			}()
 		}
 
 		// Prepare to query the API and the synchronization
-		var api *peeringdb.API
-		if PeeringdbApiKey == "" {
-			api = peeringdb.NewAPI()
-		} else {
-			api = peeringdb.NewAPIWithAPIKey(PeeringdbApiKey)
-		}
-
-		var wg sync.WaitGroup
-		doneChannels := make(map[string]chan struct{})
-		progress := mpb.New(mpb.WithWaitGroup(&wg), mpb.WithAutoRefresh())
-
-		s := database.Synchronization{API: api, DB: db}
-
-		orgTask := task{name: "Organizations", function: s.SynchronizeOrganizations}
-		campusTask := task{name: "Campuses", function: s.SynchronizeCampuses, dependencies: []task{orgTask}}
-		facTask := task{name: "Facilities", function: s.SynchronizeFacilities, dependencies: []task{orgTask, campusTask}}
-		carrierTask := task{name: "Carriers", function: s.SynchronizeCarriers, dependencies: []task{orgTask}}
-		netTask := task{name: "Networks", function: s.SynchronizeNetworks, dependencies: []task{orgTask}}
-		ixTask := task{name: "Internet Exchanges", function: s.SynchronizeInternetExchanges, dependencies: []task{orgTask}}
-		ixfacTask := task{name: "Internet Exchange Facilities", function: s.SynchronizeInternetExchangeFacilities, dependencies: []task{facTask, ixTask}}
-		ixlanTask := task{name: "Internet Exchange LANs", function: s.SynchronizeInternetExchangeLANs, dependencies: []task{ixTask}}
-		ixpfxTask := task{name: "Internet Exchange Prefixes", function: s.SynchronizeInternetExchangePrefixes, dependencies: []task{ixlanTask}}
-		pocTask := task{name: "Network Contacts", function: s.SynchronizeNetworkContacts, dependencies: []task{netTask}}
-		netfacTask := task{name: "Network Facilities", function: s.SynchronizeNetworkFacilities, dependencies: []task{netTask, facTask}}
-		netixlanTask := task{name: "Network Internet Exchange LANs", function: s.SynchronizeNetworkInternetExchangeLANs, dependencies: []task{netTask, ixTask, ixlanTask}}
-
-		for _, t := range []task{orgTask, campusTask, facTask, carrierTask, netTask, ixTask, ixfacTask, ixlanTask, ixpfxTask, pocTask, netfacTask, netixlanTask} {
-			wg.Add(1)
-
-			doneChannels[t.name] = make(chan struct{})
-
-			var dependencyChannels []<-chan struct{}
-			for _, dep := range t.dependencies {
-				dependencyChannels = append(dependencyChannels, doneChannels[dep.name])
+		api, err := newAPI()
+		if err != nil {
+			return err
+		}
+
+		s := database.Synchronization{API: api, DB: db, PageSize: pageSize}
+
+		// Watch for changes to notify if requested, a dedicated file takes
+		// precedence over the configuration file
+		notifications := Configuration.Notifications
+		if notifyConfig, _ := cmd.Flags().GetString("notify-config"); notifyConfig != "" {
+			notifications, err = notify.LoadConfig(notifyConfig)
+			if err != nil {
+				return fmt.Errorf("failed to load the notifications configuration: %w", err)
 			}
+		}
 
-			bar := progress.AddBar(0, // Will be set with task function, but requires manual complete trigger
-				mpb.PrependDecorators(
-					decor.Name(fmt.Sprintf("%-31s", t.name), decor.WC{C: decor.DindentRight | decor.DextraSpace}),
-					decor.Name("fetching", decor.WCSyncSpaceR),
-					decor.CountersNoUnit("%d / %d", decor.WCSyncWidth),
-				),
-				mpb.AppendDecorators(
-					decor.OnComplete(decor.Percentage(decor.WC{W: 5}), "done"),
-				),
-			)
+		var notifier *notify.Notifier
+		if notifications != nil {
+			notifier, err = notify.NewNotifier(notifications, db)
+			if err != nil {
+				return fmt.Errorf("failed to prepare notifications: %w", err)
+			}
+			s.OnChange = notifier.Observe
+			s.OnChangeTables = notifier.Tables()
+		}
 
-			go func(t task, d []<-chan struct{}, b *mpb.Bar) {
-				t.execute(&wg, d, b)
-				close(doneChannels[t.name]) // Signal task is done
-			}(t, dependencyChannels, bar)
+		var publisher *cdc.Publisher
+		if captureChanges {
+			publisher, err = cdc.NewPublisher(changes, db)
+			if err != nil {
+				return fmt.Errorf("failed to prepare change data capture: %w", err)
+			}
+			defer publisher.Close()
+			s.CaptureChanges = true
 		}
 
+		sched, err := newSyncScheduler(&s, syncTasks, reporter)
+		if err != nil {
+			return err
+		}
+		sched.Parallelism = parallelism
+
 		// Wait for all tasks to complete
-		progress.Wait()
+		results, runErr := sched.Run(ctx)
+
+		var completed []string
+		for i, r := range results {
+			if r.Status == scheduler.StatusSucceeded {
+				completed = append(completed, syncTasks[i].table)
+			}
+		}
+
+		metrics.SyncDuration.Set(time.Since(start).Seconds())
+		if runErr == nil {
+			metrics.LastSuccess.SetToCurrentTime()
+		}
+		for _, table := range database.GetSchema().GetTableNames() {
+			count, err := database.CountRows(db, table)
+			if err != nil {
+				slog.Error("failed to count rows", "table", table, "error", err)
+				reporter.Error(fmt.Errorf("failed to count rows of %s: %w", table, err))
+				continue
+			}
+			metrics.TableRows.WithLabelValues(table).Set(float64(count))
+		}
+
+		if metricsFile := Configuration.Metrics.File; metricsFile != "" {
+			if err = metrics.WriteTextfile(metricsFile); err != nil {
+				slog.Error("failed to write metrics", "file", metricsFile, "error", err)
+				reporter.Error(fmt.Errorf("failed to write metrics: %w", err))
+			}
+		}
+
+		// Changes committed by an interrupted or failed run are delivered too,
+		// the ones left are delivered by the next run. Interrupting again
+		// stops the delivery.
+		if publisher != nil {
+			if ctx.Err() != nil {
+				stop()
+			}
+			delivered, err := publisher.PublishDetached(ctx, cdc.PublishTimeout)
+			if err != nil {
+				slog.Error("failed to deliver changes", "delivered", delivered, "error", err)
+				reporter.Error(fmt.Errorf("failed to deliver changes: %w", err))
+			} else {
+				slog.Info("changes delivered", "count", delivered)
+			}
+		}
+
+		// Only complete synchronizations are worth keeping
+		if dir := Configuration.Snapshots.Dir; dir != "" && runErr == nil {
+			if err = takeSnapshot(ctx, db, dir); err != nil {
+				slog.Error("failed to snapshot the database", "error", err)
+				reporter.Error(err)
+			}
+		}
+
+		if notifier != nil {
+			if err = notifier.Flush(); err != nil {
+				slog.Error("failed to send notifications", "error", err)
+				reporter.Error(fmt.Errorf("failed to send notifications: %w", err))
+			}
+		}
+
+		reporter.Finish(runErr)
+		if ctx.Err() != nil {
+			slog.Warn("synchronization interrupted", "completed", completed, "duration", time.Since(start))
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("synchronization timed out after %s", timeout)
+			}
+			return errors.New("synchronization interrupted")
+		}
+		if runErr != nil {
+			return errors.New("synchronization failed")
+		}
+		slog.Info("synchronization completed", "duration", time.Since(start))
+
+		return nil
 	},
 }
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestNewReporter(t *testing.T) {
+	for _, tc := range []struct {
+		mode        string
+		fd          int
+		stdoutTaken bool
+		ok          bool
+	}{
+		{"bars", 1, false, true},
+		{"bars", 1, true, true},
+		{"json", 1, false, true},
+		{"json", 2, true, true},
+		// Progress events would be mixed with the changes
+		{"json", 1, true, false},
+		{"text", 1, false, false},
+	} {
+		_, err := newReporter(tc.mode, tc.fd, tc.stdoutTaken)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s on fd %d with the standard output taken %t: got error %v", tc.mode, tc.fd, tc.stdoutTaken, err)
+		}
+	}
+}
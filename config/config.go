@@ -0,0 +1,159 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/gmazoyer/peeringdb-sync/cdc"
+	"github.com/gmazoyer/peeringdb-sync/notify"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets when displaying the configuration.
+const redacted = "<redacted>"
+
+// LogConfig holds the logging settings.
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// MetricsConfig holds the Prometheus metrics settings.
+type MetricsConfig struct {
+	Listen string `yaml:"listen,omitempty"`
+	File   string `yaml:"file,omitempty"`
+}
+
+// SnapshotsConfig holds the settings of the database snapshots. Snapshots
+// are taken after each successful synchronization when a directory is set.
+// The most recent snapshot of each of the last KeepDaily days and KeepWeekly
+// weeks is kept, all of them if both are 0.
+type SnapshotsConfig struct {
+	Dir        string `yaml:"dir,omitempty"`
+	KeepDaily  int    `yaml:"keep_daily,omitempty"`
+	KeepWeekly int    `yaml:"keep_weekly,omitempty"`
+}
+
+// Config is the configuration of the tool.
+type Config struct {
+	APIKey        string          `yaml:"api_key,omitempty"`
+	APIKeyFile    string          `yaml:"api_key_file,omitempty"`
+	APIKeyCommand string          `yaml:"api_key_command,omitempty"`
+	DatabaseFile  string          `yaml:"database_file"`
+	Log           LogConfig       `yaml:"log"`
+	Metrics       MetricsConfig   `yaml:"metrics,omitempty"`
+	Snapshots     SnapshotsConfig `yaml:"snapshots,omitempty"`
+	Notifications *notify.Config  `yaml:"notifications,omitempty"`
+	CDC           *cdc.Config     `yaml:"cdc,omitempty"`
+}
+
+// Default returns the configuration used when nothing else is specified.
+func Default() *Config {
+	return &Config{
+		DatabaseFile: "peeringdb.db",
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// SearchPaths returns the paths where a configuration file is looked for, by
+// order of preference.
+func SearchPaths() []string {
+	paths := []string{"peeringdb-sync.yaml"}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "peeringdb-sync", "config.yaml"))
+	}
+	return append(paths, "/etc/peeringdb-sync/config.yaml")
+}
+
+// Load reads the configuration from the given YAML file. If the filename is
+// empty, the first file found in the search paths is used; if there is none
+// the default configuration is returned. The second returned value is the
+// path of the file that has been read, if any.
+func Load(filename string) (*Config, string, error) {
+	if filename == "" {
+		for _, path := range SearchPaths() {
+			if _, err := os.Stat(path); err == nil {
+				filename = path
+				break
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return nil, "", err
+			}
+		}
+	}
+
+	config := Default()
+	if filename == "" {
+		return config, "", nil
+	}
+	if filepath.Ext(filename) == ".toml" {
+		return nil, "", errors.New("TOML configuration files are not supported, only YAML ones are")
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Values missing from the file keep their defaults
+	if err = yaml.Unmarshal(content, config); err != nil {
+		return nil, "", err
+	}
+
+	return config, filename, nil
+}
+
+// Redacted returns a copy of the configuration that can be safely displayed.
+// The API key and the webhook headers, which may carry credentials, are
+// hidden, as well as the headers of the change data capture HTTP sinks.
+func (c *Config) Redacted() *Config {
+	safe := *c
+	if safe.APIKey != "" {
+		safe.APIKey = redacted
+	}
+
+	if c.Notifications != nil {
+		notifications := *c.Notifications
+		notifications.Webhooks = make([]notify.Webhook, len(c.Notifications.Webhooks))
+		for i, webhook := range c.Notifications.Webhooks {
+			notifications.Webhooks[i] = notify.Webhook{URL: webhook.URL}
+			if len(webhook.Headers) > 0 {
+				notifications.Webhooks[i].Headers = make(map[string]string, len(webhook.Headers))
+				for name := range webhook.Headers {
+					notifications.Webhooks[i].Headers[name] = redacted
+				}
+			}
+		}
+		safe.Notifications = &notifications
+	}
+
+	if c.CDC != nil {
+		changes := *c.CDC
+		changes.Sinks = make([]cdc.SinkConfig, len(c.CDC.Sinks))
+		for i, sink := range c.CDC.Sinks {
+			changes.Sinks[i] = sink
+			if len(sink.Headers) > 0 {
+				changes.Sinks[i].Headers = make(map[string]string, len(sink.Headers))
+				for name := range sink.Headers {
+					changes.Sinks[i].Headers[name] = redacted
+				}
+			}
+		}
+		safe.CDC = &changes
+	}
+
+	return &safe
+}
+
+// String returns the configuration as a YAML document.
+func (c *Config) String() string {
+	content, err := yaml.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
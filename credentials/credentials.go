@@ -0,0 +1,141 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/gmazoyer/peeringdb"
+)
+
+// ErrInvalidAPIKey is the error returned when PeeringDB rejects the API key.
+var ErrInvalidAPIKey = errors.New("the PeeringDB API key is invalid or has been revoked")
+
+// Source provides the PeeringDB API key.
+type Source interface {
+	// APIKey returns the API key, or an error if it cannot be retrieved.
+	APIKey() (string, error)
+	// String describes where the key comes from without revealing it.
+	String() string
+}
+
+// Static is a key given as is, on the command line or in the configuration.
+type Static string
+
+// APIKey returns the key itself.
+func (s Static) APIKey() (string, error) {
+	return string(s), nil
+}
+
+func (s Static) String() string {
+	return "static key"
+}
+
+// File reads the key from a file, like Docker or Kubernetes secrets.
+type File string
+
+// APIKey returns the content of the file, without surrounding whitespaces.
+// Files that can be modified by other users are refused, a warning is logged
+// if they can be read by them.
+func (f File) APIKey() (string, error) {
+	info, err := os.Stat(string(f))
+	if err != nil {
+		return "", err
+	}
+
+	mode := info.Mode().Perm()
+	if mode&0o022 != 0 {
+		return "", fmt.Errorf("the file is writable by other users (mode %04o)", mode)
+	}
+	if mode&0o004 != 0 {
+		slog.Warn("API key file is readable by any user", "file", string(f), "mode", fmt.Sprintf("%04o", mode))
+	}
+
+	content, err := os.ReadFile(string(f))
+	if err != nil {
+		return "", err
+	}
+
+	key := strings.TrimSpace(string(content))
+	if key == "" {
+		return "", errors.New("the file is empty")
+	}
+
+	return key, nil
+}
+
+func (f File) String() string {
+	return "file " + string(f)
+}
+
+// Command runs a shell command, such as a password manager helper, and uses
+// the first line of its output as the key.
+type Command string
+
+// APIKey runs the command and returns the first line of its output.
+func (c Command) APIKey() (string, error) {
+	var stdout, stderr bytes.Buffer
+	command := exec.Command("/bin/sh", "-c", string(c))
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+
+	if err := command.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	key, _, _ := strings.Cut(stdout.String(), "\n")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", errors.New("the command did not output anything")
+	}
+
+	return key, nil
+}
+
+func (c Command) String() string {
+	return "command " + string(c)
+}
+
+// NewSource returns the source matching the only non-empty value among the
+// given key, file and command. It returns nil if they are all empty.
+func NewSource(key, file, command string) (Source, error) {
+	var sources []Source
+	if key != "" {
+		sources = append(sources, Static(key))
+	}
+	if file != "" {
+		sources = append(sources, File(file))
+	}
+	if command != "" {
+		sources = append(sources, Command(command))
+	}
+
+	switch len(sources) {
+	case 0:
+		return nil, nil
+	case 1:
+		return sources[0], nil
+	default:
+		return nil, errors.New("only one of API key, API key file and API key command can be used")
+	}
+}
+
+// Validate makes a lightweight authenticated call to check that the API
+// accepts the key it has been built with.
+func Validate(api *peeringdb.API) error {
+	_, err := api.GetOrganization(map[string]interface{}{"limit": 1, "fields": "id"})
+	if err == nil {
+		return nil
+	}
+
+	message := err.Error()
+	if strings.HasPrefix(message, "401") || strings.HasPrefix(message, "403") {
+		return fmt.Errorf("%w (%s)", ErrInvalidAPIKey, message)
+	}
+
+	return fmt.Errorf("failed to validate the API key: %w", err)
+}
@@ -0,0 +1,144 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gmazoyer/peeringdb-sync/peeringdbtest"
+)
+
+// captureLogs sends the logs to the returned buffer for the duration of the
+// test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buffer bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buffer, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buffer
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		mode    os.FileMode
+		want    string
+		err     string
+		warning bool
+	}{
+		{name: "private", content: "secret\n", mode: 0o600, want: "secret"},
+		{name: "spaces", content: "  secret \r\n\n", mode: 0o400, want: "secret"},
+		{name: "world readable", content: "secret", mode: 0o644, want: "secret", warning: true},
+		{name: "group writable", content: "secret", mode: 0o620, err: "writable by other users (mode 0620)"},
+		{name: "world writable", content: "secret", mode: 0o602, err: "writable by other users (mode 0602)"},
+		{name: "empty", content: " \n", mode: 0o600, err: "empty"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			path := filepath.Join(dir, strings.ReplaceAll(tc.name, " ", "-"))
+			if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(path, tc.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			key, err := File(path).APIKey()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("got key %q and error %v, want an error containing %q", key, err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key != tc.want {
+				t.Errorf("got key %q, want %q", key, tc.want)
+			}
+			if warned := strings.Contains(logs.String(), "readable by any user"); warned != tc.warning {
+				t.Errorf("got warning %t, want %t: %s", warned, tc.warning, logs)
+			}
+		})
+	}
+
+	if _, err := File(filepath.Join(dir, "missing")).APIKey(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v for a missing file", err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	for _, tc := range []struct {
+		command string
+		want    string
+		err     string
+	}{
+		{command: "echo secret", want: "secret"},
+		{command: "printf '  secret \\nsecond line\\n'", want: "secret"},
+		{command: "printf secret", want: "secret"},
+		{command: "true", err: "did not output anything"},
+		{command: "printf '\\nsecret\\n'", err: "did not output anything"},
+		{command: "echo denied >&2; exit 3", err: "exit status 3: denied"},
+	} {
+		key, err := Command(tc.command).APIKey()
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: got key %q and error %v, want an error containing %q", tc.command, key, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.command, err)
+			continue
+		}
+		if key != tc.want {
+			t.Errorf("%s: got key %q, want %q", tc.command, key, tc.want)
+		}
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	if source, err := NewSource("", "", ""); source != nil || err != nil {
+		t.Errorf("got %v, %v, want no source", source, err)
+	}
+	if source, err := NewSource("secret", "", ""); source != Static("secret") || err != nil {
+		t.Errorf("got %v, %v, want a static key", source, err)
+	}
+	if source, err := NewSource("", "", "pass peeringdb"); source != Command("pass peeringdb") || err != nil {
+		t.Errorf("got %v, %v, want a command", source, err)
+	}
+	if _, err := NewSource("secret", "/run/secrets/peeringdb", ""); err == nil {
+		t.Error("several sources accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	server := peeringdbtest.NewServer()
+	defer server.Close()
+
+	if err := Validate(server.API()); err != nil {
+		t.Errorf("accepted key: %v", err)
+	}
+
+	// Relies on the errors of the API package starting with the HTTP status
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		server.Fail("org", status, 1)
+		err := Validate(server.API())
+		if !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("%d: got error %v, want ErrInvalidAPIKey", status, err)
+		}
+	}
+
+	server.Fail("org", http.StatusBadGateway, 1)
+	if err := Validate(server.API()); err == nil || errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("got error %v, want a failure unrelated to the key", err)
+	}
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// backupStep is the number of pages copied at once by a backup, the source
+// database being only locked while they are.
+const backupStep = 1024
+
+// Backup copies the content of the source database to the destination one
+// with the SQLite online backup API. The copy is consistent even if the
+// source is written meanwhile, the destination content is replaced.
+func Backup(ctx context.Context, src, dst *sql.DB) error {
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	return dstConn.Raw(func(dstDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			dstSQLite, ok := dstDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("destination is not a SQLite database")
+			}
+			srcSQLite, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("source is not a SQLite database")
+			}
+
+			backup, err := dstSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			defer backup.Close()
+
+			for done := false; !done; {
+				if err = ctx.Err(); err != nil {
+					return err
+				}
+				if done, err = backup.Step(backupStep); err != nil {
+					return err
+				}
+			}
+
+			return backup.Finish()
+		})
+	})
+}
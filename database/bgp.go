@@ -0,0 +1,134 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// BGPPeer is a network connection to an IX LAN along with the network details
+// required to configure BGP sessions with it.
+type BGPPeer struct {
+	ASN         int    `json:"asn" yaml:"asn"`
+	Name        string `json:"name" yaml:"name"`
+	IRRASSet    string `json:"irr_as_set,omitempty" yaml:"irr_as_set,omitempty"`
+	Policy      string `json:"policy_general,omitempty" yaml:"policy_general,omitempty"`
+	MaxPrefix4  int    `json:"info_prefixes4,omitempty" yaml:"info_prefixes4,omitempty"`
+	MaxPrefix6  int    `json:"info_prefixes6,omitempty" yaml:"info_prefixes6,omitempty"`
+	IPAddr4     string `json:"ipaddr4,omitempty" yaml:"ipaddr4,omitempty"`
+	IPAddr6     string `json:"ipaddr6,omitempty" yaml:"ipaddr6,omitempty"`
+	IsRSPeer    bool   `json:"is_rs_peer" yaml:"is_rs_peer"`
+	Operational bool   `json:"operational" yaml:"operational"`
+	IXID        int    `json:"ix_id" yaml:"ix_id"`
+	IXName      string `json:"ix_name" yaml:"ix_name"`
+	IXLANID     int    `json:"ixlan_id" yaml:"ixlan_id"`
+}
+
+// BGPPeerFilter selects the peers returned by GetBGPPeers. Zero values do not
+// filter anything.
+type BGPPeerFilter struct {
+	IXID        int      // Only peers connected to this IX
+	IXLANID     int      // Only peers connected to this IX LAN
+	ASNs        []int    // Only peers using one of these ASNs
+	Policies    []string // Only peers with one of these general policies
+	ExcludeASN  int      // Skip this ASN, usually the local one
+	Operational bool     // Only connections marked as operational
+}
+
+// ExchangeLAN is an IX LAN with its route server ASN, 0 if it has none.
+type ExchangeLAN struct {
+	ID    int    `json:"id" yaml:"id"`
+	Name  string `json:"name" yaml:"name"`
+	RSASN int    `json:"rs_asn,omitempty" yaml:"rs_asn,omitempty"`
+}
+
+// ResolveExchangeID returns the ID of the Internet exchange designated by the
+// given ID, name or alias.
+func ResolveExchangeID(db *sql.DB, idOrName string) (int, error) {
+	return resolveID(db, "peeringdb_ix", idOrName)
+}
+
+// GetExchangeName returns the name of the Internet exchange with the given
+// ID. It returns ErrNotFound if there is none.
+func GetExchangeName(db *sql.DB, id int) (string, error) {
+	var name string
+	if err := db.QueryRow("SELECT name FROM peeringdb_ix WHERE id = ?", id).Scan(&name); err != nil {
+		return "", scanOne(err)
+	}
+	return name, nil
+}
+
+// GetBGPPeers returns the connections to IX LANs matching the filter, ordered
+// by ASN.
+func GetBGPPeers(db *sql.DB, filter BGPPeerFilter) ([]BGPPeer, error) {
+	conditions := []string{"nixl.status = 'ok'"}
+	var args []interface{}
+
+	if filter.IXID != 0 {
+		conditions = append(conditions, "nixl.ix_id = ?")
+		args = append(args, filter.IXID)
+	}
+	if filter.IXLANID != 0 {
+		conditions = append(conditions, "nixl.ixlan_id = ?")
+		args = append(args, filter.IXLANID)
+	}
+	if len(filter.ASNs) > 0 {
+		list, asns := placeholders(filter.ASNs)
+		conditions = append(conditions, "nixl.asn IN ("+list+")")
+		args = append(args, asns...)
+	}
+	if len(filter.Policies) > 0 {
+		conditions = append(conditions, "net.policy_general COLLATE NOCASE IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(filter.Policies)), ", ")+")")
+		for _, policy := range filter.Policies {
+			args = append(args, policy)
+		}
+	}
+	if filter.ExcludeASN != 0 {
+		conditions = append(conditions, "nixl.asn != ?")
+		args = append(args, filter.ExcludeASN)
+	}
+
+	if filter.Operational {
+		conditions = append(conditions, "nixl.operational")
+	}
+
+	peers := []BGPPeer{}
+	err := queryAll(db, func(rows *sql.Rows) error {
+		var p BGPPeer
+		if err := rows.Scan(&p.ASN, &p.Name, &p.IRRASSet, &p.Policy, &p.MaxPrefix4, &p.MaxPrefix6, &p.IPAddr4,
+			&p.IPAddr6, &p.IsRSPeer, &p.Operational, &p.IXID, &p.IXName, &p.IXLANID); err != nil {
+			return err
+		}
+		peers = append(peers, p)
+		return nil
+	}, `SELECT nixl.asn, net.name, net.irr_as_set, net.policy_general, COALESCE(net.info_prefixes4, 0),
+		COALESCE(net.info_prefixes6, 0), COALESCE(nixl.ipaddr4, ''), COALESCE(nixl.ipaddr6, ''), nixl.is_rs_peer,
+		nixl.operational, ix.id, ix.name, nixl.ixlan_id
+		FROM peeringdb_network_ixlan nixl
+		JOIN peeringdb_network net ON net.id = nixl.net_id
+		JOIN peeringdb_ix ix ON ix.id = nixl.ix_id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY nixl.asn, ix.name, nixl.ipaddr4, nixl.ipaddr6`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return peers, nil
+}
+
+// GetExchangeLANs returns the IX LANs of the given Internet exchange.
+func GetExchangeLANs(db *sql.DB, ixID int) ([]ExchangeLAN, error) {
+	lans := []ExchangeLAN{}
+	err := queryAll(db, func(rows *sql.Rows) error {
+		var lan ExchangeLAN
+		if err := rows.Scan(&lan.ID, &lan.Name, &lan.RSASN); err != nil {
+			return err
+		}
+		lans = append(lans, lan)
+		return nil
+	}, `SELECT id, name, COALESCE(rs_asn, 0) FROM peeringdb_ixlan WHERE ix_id = ? AND status = 'ok' ORDER BY id`, ixID)
+	if err != nil {
+		return nil, err
+	}
+
+	return lans, nil
+}
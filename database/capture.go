@@ -0,0 +1,159 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const (
+	// captureTable holds the changes captured during synchronizations until
+	// they have been delivered.
+	captureTable = "peeringdb_sync_change"
+	// captureCursorTable holds the sequence number of the last change
+	// delivered.
+	captureCursorTable = "peeringdb_sync_change_cursor"
+)
+
+// tableObjects maps the synchronized tables to the PeeringDB types of the
+// objects they hold.
+var tableObjects = map[string]string{
+	"peeringdb_organization":     "org",
+	"peeringdb_campus":           "campus",
+	"peeringdb_facility":         "fac",
+	"peeringdb_carrier":          "carrier",
+	"peeringdb_carrier_facility": "carrierfac",
+	"peeringdb_network":          "net",
+	"peeringdb_ix":               "ix",
+	"peeringdb_ix_facility":      "ixfac",
+	"peeringdb_ixlan":            "ixlan",
+	"peeringdb_ix_prefix":        "ixpfx",
+	"peeringdb_network_contact":  "poc",
+	"peeringdb_network_facility": "netfac",
+	"peeringdb_network_ixlan":    "netixlan",
+}
+
+// CapturedChange is a change recorded along with the rows it modified, to be
+// delivered to other systems. Sequence numbers are strictly increasing and
+// never reused, they can be used to detect changes delivered twice.
+type CapturedChange struct {
+	Sequence  int64     `json:"sequence"`
+	Time      time.Time `json:"time"`
+	Object    string    `json:"object"`
+	Table     string    `json:"table"`
+	ID        int       `json:"id"`
+	Operation Operation `json:"operation"`
+	// Record is the new version of the row, the last known one for
+	// deletions.
+	Record        json.RawMessage `json:"record"`
+	ChangedFields []string        `json:"changed_fields"`
+}
+
+// createCaptureTables creates the tables holding the captured changes if
+// needed.
+func createCaptureTables(db execer) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + captureTable + ` (
+		sequence integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		time datetime NOT NULL,
+		table_name varchar(255) NOT NULL,
+		object_id integer NOT NULL,
+		operation varchar(16) NOT NULL,
+		record text NOT NULL,
+		changed_fields text NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS ` + captureCursorTable + ` (
+		id integer NOT NULL PRIMARY KEY CHECK (id = 1),
+		sequence integer NOT NULL
+	);`)
+	return err
+}
+
+// captureChanges records changes in the same transaction as the rows they
+// modified, so that none is lost if the changes cannot be delivered.
+func captureChanges(tx *sql.Tx, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	statement, err := tx.Prepare("INSERT INTO " + captureTable + " (time, table_name, object_id, operation, record, changed_fields) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	now := time.Now().UTC()
+	for _, change := range changes {
+		record := change.New
+		if record == nil {
+			record = change.Old
+		}
+
+		_, err = statement.Exec(now, change.Table, change.ID, change.Operation, marshalJSON(record), marshalJSON(change.ChangedFields()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetDeliveredSequence returns the sequence number of the last change
+// delivered, 0 if none has been.
+func GetDeliveredSequence(db *sql.DB) (int64, error) {
+	var sequence int64
+	err := db.QueryRow("SELECT sequence FROM " + captureCursorTable + " WHERE id = 1").Scan(&sequence)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return sequence, err
+}
+
+// GetCapturedChanges returns at most limit changes not delivered yet, oldest
+// first.
+func GetCapturedChanges(db *sql.DB, limit int) ([]CapturedChange, error) {
+	delivered, err := GetDeliveredSequence(db)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []CapturedChange{}
+	err = queryAll(db, func(rows *sql.Rows) error {
+		var c CapturedChange
+		var record, fields string
+		if err := rows.Scan(&c.Sequence, &c.Time, &c.Table, &c.ID, &c.Operation, &record, &fields); err != nil {
+			return err
+		}
+		c.Object = tableObjects[c.Table]
+		c.Record = json.RawMessage(record)
+		if err := json.Unmarshal([]byte(fields), &c.ChangedFields); err != nil {
+			return err
+		}
+		changes = append(changes, c)
+		return nil
+	}, "SELECT sequence, time, table_name, object_id, operation, record, changed_fields FROM "+captureTable+
+		" WHERE sequence > ? ORDER BY sequence LIMIT ?", delivered, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// AcknowledgeChanges records that the changes up to the given sequence number
+// have been delivered and forgets about them.
+func AcknowledgeChanges(db *sql.DB, sequence int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("INSERT OR REPLACE INTO "+captureCursorTable+" (id, sequence) VALUES (1, MAX(?, COALESCE((SELECT sequence FROM "+captureCursorTable+" WHERE id = 1), 0)))", sequence); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("DELETE FROM "+captureTable+" WHERE sequence <= ?", sequence); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
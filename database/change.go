@@ -0,0 +1,112 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Operation is the kind of modification applied to a row during a
+// synchronization.
+type Operation string
+
+const (
+	OperationInsert Operation = "insert"
+	OperationUpdate Operation = "update"
+	OperationDelete Operation = "delete"
+)
+
+// Change describes a row that has been written or deleted during a
+// synchronization. Old is nil for inserts and New is nil for deletions.
+type Change struct {
+	Table     string                 `json:"table"`
+	ID        int                    `json:"id"`
+	Operation Operation              `json:"operation"`
+	Old       map[string]interface{} `json:"old,omitempty"`
+	New       map[string]interface{} `json:"new,omitempty"`
+}
+
+// ChangedFields returns the sorted names of the columns whose values differ
+// between the old and the new version of the row. For inserts and deletions
+// all columns are considered changed.
+func (c *Change) ChangedFields() []string {
+	fields := []string{}
+
+	switch {
+	case c.Old == nil:
+		for name := range c.New {
+			fields = append(fields, name)
+		}
+	case c.New == nil:
+		for name := range c.Old {
+			fields = append(fields, name)
+		}
+	default:
+		for name, value := range c.New {
+			if !valuesEqual(c.Old[name], value) {
+				fields = append(fields, name)
+			}
+		}
+	}
+
+	sort.Strings(fields)
+	return fields
+}
+
+// valuesEqual compares two values read from the database.
+func valuesEqual(a, b interface{}) bool {
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Equal(tb)
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// readRow returns the row identified by the given ID as a map of column names
+// to values. It returns nil if the row does not exist.
+func readRow(tx *sql.Tx, table string, id int) (map[string]interface{}, error) {
+	rows, err := tx.Query(fmt.Sprintf("SELECT * FROM %s WHERE id = %d", table, id))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err = rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+
+	schema := GetSchema().Tables[table]
+	row := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		// Derived columns do not carry any information of their own
+		if schema.isDerived(column) {
+			continue
+		}
+
+		// Text can be returned as raw bytes, keep it readable
+		if b, ok := values[i].([]byte); ok {
+			row[column] = string(b)
+		} else {
+			row[column] = values[i]
+		}
+	}
+
+	return row, nil
+}
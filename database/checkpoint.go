@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// checkpointTable records the progress of the synchronizations of tables
+// that have not completed yet.
+const checkpointTable = "peeringdb_sync_checkpoint"
+
+// Checkpoint is the progress of the synchronization of a table. Pages are
+// committed one by one, a checkpoint tells how many objects have been
+// written and which changes were being fetched so that an interrupted
+// synchronization can be resumed where it stopped.
+type Checkpoint struct {
+	Table   string    `json:"table" yaml:"table"`
+	Since   int64     `json:"since" yaml:"since"`
+	Skip    int       `json:"skip" yaml:"skip"`
+	Started time.Time `json:"started" yaml:"started"`
+	Updated time.Time `json:"updated" yaml:"updated"`
+}
+
+// execer is implemented by both sql.DB and sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// createCheckpointTable creates the table holding the checkpoints if needed.
+func createCheckpointTable(db execer) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + checkpointTable + ` (
+		table_name varchar(255) NOT NULL PRIMARY KEY,
+		since integer NOT NULL,
+		skip integer NOT NULL,
+		started datetime NOT NULL,
+		updated datetime NOT NULL
+	);`)
+	return err
+}
+
+// createStateTables creates the tables recording the state of the
+// synchronizations, which are not part of the schema, if needed.
+func createStateTables(db execer) error {
+	if err := createCheckpointTable(db); err != nil {
+		return fmt.Errorf("failed to create %s: %w", checkpointTable, err)
+	}
+	if err := createCaptureTables(db); err != nil {
+		return fmt.Errorf("failed to create %s: %w", captureTable, err)
+	}
+	return nil
+}
+
+// GetCheckpoint returns the checkpoint of the given table, nil if its last
+// synchronization has completed.
+func GetCheckpoint(db *sql.DB, table string) (*Checkpoint, error) {
+	c := &Checkpoint{Table: table}
+	err := db.QueryRow("SELECT since, skip, started, updated FROM "+checkpointTable+" WHERE table_name = ?", table).
+		Scan(&c.Since, &c.Skip, &c.Started, &c.Updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// GetCheckpoints returns the checkpoints of all the tables whose last
+// synchronization has not completed, sorted by table.
+func GetCheckpoints(db *sql.DB) ([]Checkpoint, error) {
+	checkpoints := []Checkpoint{}
+	err := queryAll(db, func(rows *sql.Rows) error {
+		var c Checkpoint
+		if err := rows.Scan(&c.Table, &c.Since, &c.Skip, &c.Started, &c.Updated); err != nil {
+			return err
+		}
+		checkpoints = append(checkpoints, c)
+		return nil
+	}, "SELECT table_name, since, skip, started, updated FROM "+checkpointTable+" ORDER BY table_name")
+	if err != nil {
+		return nil, err
+	}
+
+	return checkpoints, nil
+}
+
+// saveCheckpoint records the progress of the synchronization of a table
+// along with the page that has just been written.
+func saveCheckpoint(tx *sql.Tx, c *Checkpoint) error {
+	c.Updated = time.Now().UTC()
+	_, err := tx.Exec("INSERT OR REPLACE INTO "+checkpointTable+" (table_name, since, skip, started, updated) VALUES (?, ?, ?, ?, ?)",
+		c.Table, c.Since, c.Skip, c.Started, c.Updated)
+	return err
+}
+
+// removeCheckpoint marks the synchronization of a table as completed.
+func removeCheckpoint(tx *sql.Tx, table string) error {
+	_, err := tx.Exec("DELETE FROM "+checkpointTable+" WHERE table_name = ?", table)
+	return err
+}
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Difference is a row that differs between two databases. Rows only present
+// in the second database are inserts, rows only present in the first one are
+// deletions and rows present in both with different values are updates.
+type Difference struct {
+	Table     string            `json:"table"`
+	ID        int               `json:"id"`
+	Operation Operation         `json:"operation"`
+	Fields    []FieldDifference `json:"fields"`
+}
+
+// FieldDifference is a column of a row that differs between two databases.
+// Old is nil for inserts and New is nil for deletions.
+type FieldDifference struct {
+	Name string      `json:"name"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// DiffSummary counts the differences found in a table.
+type DiffSummary struct {
+	Table   string `json:"table"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Changed int    `json:"changed"`
+}
+
+// DiffOptions restricts the rows to compare.
+type DiffOptions struct {
+	// Tables lists the tables to compare, all the tables of the schema if
+	// empty.
+	Tables []string
+	// Filters holds SQL conditions, by table, that rows must match on both
+	// sides to be compared.
+	Filters map[string]string
+}
+
+// diffCursor iterates over the rows of a table ordered by ID.
+type diffCursor struct {
+	rows  *sql.Rows
+	table *Table
+	id    int
+	row   map[string]interface{}
+	done  bool
+}
+
+func newDiffCursor(db *sql.DB, table *Table, filter string) (*diffCursor, error) {
+	query := "SELECT * FROM " + table.Name
+	if filter != "" {
+		query += " WHERE (" + filter + ")"
+	}
+	rows, err := db.Query(query + " ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &diffCursor{rows: rows, table: table}
+	if err = c.next(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// next reads the following row, setting done once there are no more.
+func (c *diffCursor) next() error {
+	if !c.rows.Next() {
+		c.done = true
+		return c.rows.Err()
+	}
+
+	columns, err := c.rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err = c.rows.Scan(pointers...); err != nil {
+		return err
+	}
+
+	c.row = make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		// Derived columns do not carry any information of their own
+		if c.table.isDerived(column) {
+			continue
+		}
+		if b, ok := values[i].([]byte); ok {
+			c.row[column] = string(b)
+		} else {
+			c.row[column] = values[i]
+		}
+	}
+
+	id, ok := c.row["id"].(int64)
+	if !ok {
+		return fmt.Errorf("row without integer id in %s", c.table.Name)
+	}
+	c.id = int(id)
+
+	return nil
+}
+
+func (c *diffCursor) close() error {
+	return c.rows.Close()
+}
+
+// fieldDifferences returns the columns whose values differ between the two
+// versions of a row, by name. Either version can be nil.
+func fieldDifferences(before, after map[string]interface{}) []FieldDifference {
+	names := make([]string, 0, len(before)+len(after))
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	fields := []FieldDifference{}
+	for _, name := range names {
+		if before != nil && after != nil && valuesEqual(before[name], after[name]) {
+			continue
+		}
+		fields = append(fields, FieldDifference{Name: name, Old: before[name], New: after[name]})
+	}
+	return fields
+}
+
+// Diff compares the rows of the tables of the schema between two databases,
+// matching them by primary key, and calls report for each difference. Tables
+// are compared in alphabetical order, rows by increasing ID, without loading
+// whole tables in memory. It returns the number of differences per table.
+func Diff(a, b *sql.DB, schema *Schema, options DiffOptions, report func(Difference) error) ([]DiffSummary, error) {
+	tables := options.Tables
+	if len(tables) == 0 {
+		tables = schema.GetTableNames()
+	}
+	for name := range options.Filters {
+		if !slices.Contains(tables, name) {
+			return nil, fmt.Errorf("filter on table %s which is not compared", name)
+		}
+	}
+	sort.Strings(tables)
+
+	summaries := make([]DiffSummary, 0, len(tables))
+	for _, name := range tables {
+		table, ok := schema.Tables[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown table %s", name)
+		}
+
+		summary, err := diffTable(a, b, &table, options.Filters[name], report)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare %s: %w", name, err)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+// diffTable compares a table by walking the rows of both databases in ID
+// order at the same time.
+func diffTable(a, b *sql.DB, table *Table, filter string, report func(Difference) error) (DiffSummary, error) {
+	summary := DiffSummary{Table: table.Name}
+
+	before, err := newDiffCursor(a, table, filter)
+	if err != nil {
+		return summary, err
+	}
+	defer before.close()
+	after, err := newDiffCursor(b, table, filter)
+	if err != nil {
+		return summary, err
+	}
+	defer after.close()
+
+	for !before.done || !after.done {
+		difference := Difference{Table: table.Name}
+		switch {
+		case after.done || (!before.done && before.id < after.id):
+			difference.ID, difference.Operation = before.id, OperationDelete
+			difference.Fields = fieldDifferences(before.row, nil)
+			err = before.next()
+		case before.done || after.id < before.id:
+			difference.ID, difference.Operation = after.id, OperationInsert
+			difference.Fields = fieldDifferences(nil, after.row)
+			err = after.next()
+		default:
+			difference.ID, difference.Operation = before.id, OperationUpdate
+			difference.Fields = fieldDifferences(before.row, after.row)
+			if err = before.next(); err == nil {
+				err = after.next()
+			}
+		}
+		if err != nil {
+			return summary, err
+		}
+
+		switch difference.Operation {
+		case OperationDelete:
+			summary.Removed++
+		case OperationInsert:
+			summary.Added++
+		default:
+			if len(difference.Fields) == 0 {
+				continue
+			}
+			summary.Changed++
+		}
+
+		if err = report(difference); err != nil {
+			return summary, err
+		}
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// copyDatabase returns a copy of the database made with the backup API.
+func copyDatabase(t *testing.T, db *sql.DB) *sql.DB {
+	t.Helper()
+
+	copied, err := GetDatabaseConnection(filepath.Join(t.TempDir(), "copy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { copied.Close() })
+
+	if err = Backup(context.Background(), db, copied); err != nil {
+		t.Fatal(err)
+	}
+	return copied
+}
+
+func TestDiff(t *testing.T) {
+	st := newSyncTest(t, false)
+	st.runAll()
+	a := st.sync.DB
+	b := copyDatabase(t, a)
+
+	for _, query := range []string{
+		"UPDATE peeringdb_network SET name = 'Renamed', info_prefixes4 = 42 WHERE id = 10",
+		"DELETE FROM peeringdb_network_ixlan WHERE id = 41",
+	} {
+		if _, err := b.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Seen from the first database, the contact is added
+	if _, err := a.Exec("DELETE FROM peeringdb_network_contact WHERE id = 110"); err != nil {
+		t.Fatal(err)
+	}
+
+	var differences []Difference
+	summaries, err := Diff(a, b, GetSchema(), DiffOptions{}, func(d Difference) error {
+		differences = append(differences, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(differences) != 3 {
+		t.Fatalf("got differences %+v, want 3", differences)
+	}
+
+	update := differences[0]
+	if update.Table != "peeringdb_network" || update.ID != 10 || update.Operation != OperationUpdate ||
+		len(update.Fields) != 2 || update.Fields[0].Name != "info_prefixes4" || update.Fields[1].Name != "name" ||
+		update.Fields[1].New != "Renamed" {
+		t.Errorf("unexpected update: %+v", update)
+	}
+	insert := differences[1]
+	if insert.Table != "peeringdb_network_contact" || insert.ID != 110 || insert.Operation != OperationInsert ||
+		len(insert.Fields) == 0 || insert.Fields[0].Old != nil {
+		t.Errorf("unexpected insert: %+v", insert)
+	}
+	deletion := differences[2]
+	if deletion.Table != "peeringdb_network_ixlan" || deletion.ID != 41 || deletion.Operation != OperationDelete ||
+		len(deletion.Fields) == 0 || deletion.Fields[0].New != nil {
+		t.Errorf("unexpected deletion: %+v", deletion)
+	}
+
+	for _, s := range summaries {
+		if s.Table == "peeringdb_organization" && s.Added+s.Removed+s.Changed != 0 {
+			t.Errorf("unexpected differences in organizations: %+v", s)
+		}
+	}
+
+	// Swapping the databases reverses the differences, filters restrict them
+	differences = nil
+	summaries, err = Diff(b, a, GetSchema(), DiffOptions{
+		Tables:  []string{"peeringdb_network_ixlan", "peeringdb_network"},
+		Filters: map[string]string{"peeringdb_network": "asn <> 64500"},
+	}, func(d Difference) error {
+		differences = append(differences, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || len(differences) != 1 || differences[0].Operation != OperationInsert || differences[0].ID != 41 {
+		t.Errorf("unexpected differences %+v, summaries %+v", differences, summaries)
+	}
+
+	if _, err = Diff(a, b, GetSchema(), DiffOptions{Tables: []string{"peeringdb_unknown"}}, nil); err == nil {
+		t.Error("unknown table compared")
+	}
+}
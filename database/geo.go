@@ -0,0 +1,182 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// earthRadius is the mean radius of the Earth in kilometers.
+const earthRadius = 6371.0
+
+// SpatialIndex is an R*Tree index on the latitude and longitude columns of a
+// table. It is kept up to date by triggers, rows without coordinates are not
+// indexed.
+type SpatialIndex struct {
+	Table string // Indexed table
+}
+
+// Name returns the name of the R*Tree table.
+func (i *SpatialIndex) Name() string {
+	return i.Table + "_geo"
+}
+
+// insert returns the statement indexing the rows designated by the reference,
+// with the given FROM clause if any.
+func (i *SpatialIndex) insert(reference, from string) string {
+	return fmt.Sprintf("INSERT INTO %[1]s SELECT %[2]s.id, %[2]s.latitude, %[2]s.latitude, %[2]s.longitude, %[2]s.longitude%[3]s WHERE %[2]s.latitude IS NOT NULL AND %[2]s.longitude IS NOT NULL",
+		i.Name(), reference, from)
+}
+
+// generateQueries returns the statements creating the index and its triggers.
+func (i *SpatialIndex) generateQueries() []string {
+	remove := fmt.Sprintf("DELETE FROM %s WHERE id = old.id;", i.Name())
+	return []string{
+		fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING rtree(id, min_latitude, max_latitude, min_longitude, max_longitude);", i.Name()),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s_insert AFTER INSERT ON %s BEGIN %s; END;", i.Name(), i.Table, i.insert("new", "")),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s_update AFTER UPDATE OF latitude, longitude ON %s BEGIN %s %s; END;", i.Name(), i.Table, remove, i.insert("new", "")),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s_delete AFTER DELETE ON %s BEGIN %s END;", i.Name(), i.Table, remove),
+	}
+}
+
+// migrateSpatialIndex creates the index if it does not exist and indexes the
+// existing rows.
+func migrateSpatialIndex(tx *sql.Tx, index *SpatialIndex) error {
+	exists, err := objectExists(tx, "table", index.Name())
+	if err != nil || exists {
+		return err
+	}
+
+	for _, statement := range index.generateQueries() {
+		if _, err = tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(index.insert(index.Table, " FROM "+index.Table))
+	return err
+}
+
+// GeoFilter selects the objects returned by geospatial queries.
+type GeoFilter struct {
+	Latitude  float64 // Latitude of the reference point
+	Longitude float64 // Longitude of the reference point
+	Radius    float64 // Maximum distance in kilometers, 0 for no limit
+	ASN       int     // Only facilities where this network is present
+	Limit     int     // Maximum number of results, 0 for no limit
+}
+
+// GeoResult is an object located at some distance of a point.
+type GeoResult struct {
+	ID        int     `json:"id" yaml:"id"`
+	Name      string  `json:"name" yaml:"name"`
+	City      string  `json:"city" yaml:"city"`
+	Country   string  `json:"country" yaml:"country"`
+	Latitude  float64 `json:"latitude" yaml:"latitude"`
+	Longitude float64 `json:"longitude" yaml:"longitude"`
+	Distance  float64 `json:"distance_km" yaml:"distance_km"`
+}
+
+// Distance returns the great-circle distance in kilometers between two
+// points.
+func Distance(latitude1, longitude1, latitude2, longitude2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLatitude := toRadians(latitude2 - latitude1)
+	dLongitude := toRadians(longitude2 - longitude1)
+
+	a := math.Pow(math.Sin(dLatitude/2), 2) +
+		math.Cos(toRadians(latitude1))*math.Cos(toRadians(latitude2))*math.Pow(math.Sin(dLongitude/2), 2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// boundingBox returns the conditions on the R*Tree columns selecting the
+// points that may be within the radius of the filter.
+func (f *GeoFilter) boundingBox(alias string) (string, []interface{}) {
+	if f.Radius <= 0 {
+		return "", nil
+	}
+
+	dLatitude := f.Radius / earthRadius * 180 / math.Pi
+	minLatitude, maxLatitude := f.Latitude-dLatitude, f.Latitude+dLatitude
+	condition := fmt.Sprintf("%[1]s.max_latitude >= ? AND %[1]s.min_latitude <= ?", alias)
+	args := []interface{}{minLatitude, maxLatitude}
+
+	// Longitudes are only bounded if the box contains no pole nor the
+	// antimeridian
+	if minLatitude > -90 && maxLatitude < 90 {
+		dLongitude := dLatitude / math.Cos(f.Latitude*math.Pi/180)
+		minLongitude, maxLongitude := f.Longitude-dLongitude, f.Longitude+dLongitude
+		if minLongitude > -180 && maxLongitude < 180 {
+			condition += fmt.Sprintf(" AND %[1]s.max_longitude >= ? AND %[1]s.min_longitude <= ?", alias)
+			args = append(args, minLongitude, maxLongitude)
+		}
+	}
+
+	return condition, args
+}
+
+// near returns the rows of the table closest to the point of the filter,
+// using the given extra conditions.
+func near(db *sql.DB, index *SpatialIndex, filter GeoFilter, conditions []string, args []interface{}) ([]GeoResult, error) {
+	conditions = append([]string{"t.status = 'ok'"}, conditions...)
+	if box, boxArgs := filter.boundingBox("g"); box != "" {
+		conditions = append(conditions, box)
+		args = append(args, boxArgs...)
+	}
+
+	results := []GeoResult{}
+	err := queryAll(db, func(rows *sql.Rows) error {
+		var r GeoResult
+		if err := rows.Scan(&r.ID, &r.Name, &r.City, &r.Country, &r.Latitude, &r.Longitude); err != nil {
+			return err
+		}
+		r.Distance = Distance(filter.Latitude, filter.Longitude, r.Latitude, r.Longitude)
+		if filter.Radius <= 0 || r.Distance <= filter.Radius {
+			results = append(results, r)
+		}
+		return nil
+	}, fmt.Sprintf(`SELECT t.id, t.name, t.city, t.country, t.latitude, t.longitude
+		FROM %s g JOIN %s t ON t.id = g.id
+		WHERE %s`, index.Name(), index.Table, strings.Join(conditions, " AND ")), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Distance < results[j].Distance })
+	if filter.Limit > 0 && len(results) > filter.Limit {
+		results = results[:filter.Limit]
+	}
+
+	return results, nil
+}
+
+// FacilitiesNear returns the facilities matching the filter, the closest
+// first.
+func FacilitiesNear(db *sql.DB, filter GeoFilter) ([]GeoResult, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.ASN != 0 {
+		conditions = append(conditions, `t.id IN (
+			SELECT netfac.fac_id FROM peeringdb_network_facility netfac
+			JOIN peeringdb_network net ON net.id = netfac.net_id
+			WHERE net.asn = ? AND netfac.status = 'ok')`)
+		args = append(args, filter.ASN)
+	}
+
+	return near(db, &SpatialIndex{Table: "peeringdb_facility"}, filter, conditions, args)
+}
+
+// OrganizationsNear returns the organizations matching the filter, the
+// closest first. Organizations operating the network of the filter are
+// returned if it is set.
+func OrganizationsNear(db *sql.DB, filter GeoFilter) ([]GeoResult, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.ASN != 0 {
+		conditions = append(conditions, "t.id IN (SELECT org_id FROM peeringdb_network WHERE asn = ?)")
+		args = append(args, filter.ASN)
+	}
+
+	return near(db, &SpatialIndex{Table: "peeringdb_organization"}, filter, conditions, args)
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	for _, tc := range []struct {
+		latitude1, longitude1, latitude2, longitude2 float64
+		want                                         float64
+	}{
+		{50.1, 8.7, 50.1, 8.7, 0},
+		{0, 0, 0, 90, math.Pi / 2 * earthRadius},
+		{90, 0, -90, 0, math.Pi * earthRadius},
+		// Paris to London
+		{48.8566, 2.3522, 51.5074, -0.1278, 343.6},
+		// Across the antimeridian and the North pole
+		{0, 179.5, 0, -179.5, math.Pi / 180 * earthRadius},
+		{89.5, 0, 89.5, 180, math.Pi / 180 * earthRadius},
+	} {
+		got := Distance(tc.latitude1, tc.longitude1, tc.latitude2, tc.longitude2)
+		if math.Abs(got-tc.want) > 0.1 {
+			t.Errorf("Distance(%v, %v, %v, %v) = %.2f, want %.2f", tc.latitude1, tc.longitude1, tc.latitude2, tc.longitude2, got, tc.want)
+		}
+		if reverse := Distance(tc.latitude2, tc.longitude2, tc.latitude1, tc.longitude1); math.Abs(reverse-got) > 1e-9 {
+			t.Errorf("reverse distance %.2f differs from %.2f", reverse, got)
+		}
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		filter GeoFilter
+		args   int
+	}{
+		{"no radius", GeoFilter{Latitude: 50.1, Longitude: 8.7}, 0},
+		{"bounded", GeoFilter{Latitude: 50.1, Longitude: 8.7, Radius: 100}, 4},
+		{"north pole", GeoFilter{Latitude: 89.9, Longitude: 8.7, Radius: 50}, 2},
+		{"south pole", GeoFilter{Latitude: -89.9, Longitude: 8.7, Radius: 50}, 2},
+		{"antimeridian", GeoFilter{Latitude: 0, Longitude: 179.9, Radius: 50}, 2},
+		{"antimeridian west", GeoFilter{Latitude: 0, Longitude: -179.9, Radius: 50}, 2},
+	} {
+		condition, args := tc.filter.boundingBox("g")
+		if len(args) != tc.args {
+			t.Errorf("%s: got condition %q with %v, want %d arguments", tc.name, condition, args, tc.args)
+		}
+	}
+
+	// One degree of latitude is about 111 km
+	_, args := (&GeoFilter{Latitude: 0, Longitude: 0, Radius: 111.19}).boundingBox("g")
+	for i, want := range []float64{-1, 1, -1, 1} {
+		if math.Abs(args[i].(float64)-want) > 0.001 {
+			t.Errorf("got bounds %v, want %v", args, []float64{-1, 1, -1, 1})
+			break
+		}
+	}
+}
+
+func TestFacilitiesNear(t *testing.T) {
+	store := openFixture(t)
+
+	nearIDs := func(filter GeoFilter) []int {
+		t.Helper()
+		results, err := FacilitiesNear(store.db, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int{}
+		for _, r := range results {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	// The facility in Amsterdam has no coordinates
+	frankfurt := GeoFilter{Latitude: 50.1, Longitude: 8.7}
+	for _, tc := range []struct {
+		name   string
+		radius float64
+		asn    int
+		limit  int
+		want   []int
+	}{
+		{name: "all", want: []int{50, 52}},
+		{name: "radius", radius: 100, want: []int{50}},
+		{name: "limit", limit: 1, want: []int{50}},
+		{name: "network", asn: 64501, want: []int{50}},
+		{name: "network and radius", asn: 64500, radius: 1000, want: []int{50}},
+		// The presence of AS64502 in Frankfurt has been deleted
+		{name: "deleted presence", asn: 64502, want: []int{}},
+	} {
+		filter := frankfurt
+		filter.Radius, filter.ASN, filter.Limit = tc.radius, tc.asn, tc.limit
+		if ids := nearIDs(filter); !slices.Equal(ids, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, ids, tc.want)
+		}
+	}
+
+	results, err := FacilitiesNear(store.db, GeoFilter{Latitude: 52.5, Longitude: 13.4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Name != "Example DC Berlin" || results[0].Distance != 0 || math.Abs(results[1].Distance-424) > 5 {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	// Points across the antimeridian and the pole are found as the bounding
+	// box is then not limited in longitude
+	for _, tc := range []struct {
+		latitude, longitude float64
+		filter              GeoFilter
+	}{
+		{0, 179.9, GeoFilter{Latitude: 0, Longitude: -179.9, Radius: 50}},
+		{89.9, 0, GeoFilter{Latitude: 89.9, Longitude: 180, Radius: 50}},
+	} {
+		if _, err = store.db.Exec("UPDATE peeringdb_facility SET latitude = ?, longitude = ? WHERE id = 52", tc.latitude, tc.longitude); err != nil {
+			t.Fatal(err)
+		}
+		if ids := nearIDs(tc.filter); !slices.Equal(ids, []int{52}) {
+			t.Errorf("%+v: got %v, want the facility at %v, %v", tc.filter, ids, tc.latitude, tc.longitude)
+		}
+	}
+}
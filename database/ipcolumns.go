@@ -0,0 +1,51 @@
+package database
+
+import (
+	"net/netip"
+)
+
+// addressBytes returns the address on 16 bytes, IPv4 addresses being mapped
+// in IPv6. Comparing the returned values gives the order of the addresses
+// which makes them usable in range queries.
+func addressBytes(addr netip.Addr) []byte {
+	b := addr.As16()
+	return b[:]
+}
+
+// addressFamily returns 4 or 6 depending on the IP version of the address.
+func addressFamily(addr netip.Addr) int {
+	if addr.Is4() {
+		return 4
+	}
+	return 6
+}
+
+// prefixColumns returns the values of the family, prefix_start, prefix_end
+// and prefix_length columns derived from the given prefix. They are all nil
+// if the prefix is invalid.
+func prefixColumns(value string) (family, start, end, length interface{}) {
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return nil, nil, nil, nil
+	}
+	prefix = prefix.Masked()
+
+	// Set all the host bits to get the last address of the prefix
+	last := addressBytes(prefix.Addr())
+	offset := 128 - prefix.Addr().BitLen() + prefix.Bits()
+	for bit := offset; bit < 128; bit++ {
+		last[bit/8] |= 0x80 >> (bit % 8)
+	}
+
+	return addressFamily(prefix.Addr()), addressBytes(prefix.Addr()), last, prefix.Bits()
+}
+
+// addressColumn returns the value of a column derived from the given address,
+// nil if the address is invalid.
+func addressColumn(value string) interface{} {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return nil
+	}
+	return addressBytes(addr.Unmap())
+}
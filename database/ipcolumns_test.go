@@ -0,0 +1,68 @@
+package database
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestPrefixColumns(t *testing.T) {
+	for _, tc := range []struct {
+		prefix     string
+		family     int
+		start, end string
+		length     int
+	}{
+		{"192.0.2.0/24", 4, "192.0.2.0", "192.0.2.255", 24},
+		{"192.0.2.17/28", 4, "192.0.2.16", "192.0.2.31", 28},
+		{"198.51.100.1/32", 4, "198.51.100.1", "198.51.100.1", 32},
+		{"0.0.0.0/0", 4, "0.0.0.0", "255.255.255.255", 0},
+		{"2001:db8::/64", 6, "2001:db8::", "2001:db8::ffff:ffff:ffff:ffff", 64},
+		{"2001:db8:0:1::1/63", 6, "2001:db8::", "2001:db8:0:1:ffff:ffff:ffff:ffff", 63},
+		{"2001:db8::1/128", 6, "2001:db8::1", "2001:db8::1", 128},
+	} {
+		family, start, end, length := prefixColumns(tc.prefix)
+		if family != tc.family || length != tc.length {
+			t.Errorf("%s: got family %v and length %v, want %d and %d", tc.prefix, family, length, tc.family, tc.length)
+		}
+		if want := addressBytes(netip.MustParseAddr(tc.start)); !bytes.Equal(start.([]byte), want) {
+			t.Errorf("%s: start is %x, want %x", tc.prefix, start, want)
+		}
+		if want := addressBytes(netip.MustParseAddr(tc.end)); !bytes.Equal(end.([]byte), want) {
+			t.Errorf("%s: end is %x, want %x", tc.prefix, end, want)
+		}
+	}
+
+	// IPv4 ranges are below IPv6 ones and never overlap them
+	_, _, end4, _ := prefixColumns("0.0.0.0/0")
+	_, start6, _, _ := prefixColumns("2001:db8::/32")
+	if bytes.Compare(end4.([]byte), start6.([]byte)) >= 0 {
+		t.Errorf("IPv4 range ends at %x, after the IPv6 range start %x", end4, start6)
+	}
+
+	for _, prefix := range []string{"", "192.0.2.0", "192.0.2.0/33", "2001:db8::/129", "not a prefix"} {
+		if family, start, end, length := prefixColumns(prefix); family != nil || start != nil || end != nil || length != nil {
+			t.Errorf("%q: got %v %v %v %v, want nil columns", prefix, family, start, end, length)
+		}
+	}
+}
+
+func TestAddressColumn(t *testing.T) {
+	for value, want := range map[string]string{
+		"192.0.2.10":          "192.0.2.10",
+		"::ffff:192.0.2.10":   "192.0.2.10",
+		"2001:db8::10":        "2001:db8::10",
+		"2001:0db8:0:0::0010": "2001:db8::10",
+	} {
+		got, ok := addressColumn(value).([]byte)
+		if !ok || !bytes.Equal(got, addressBytes(netip.MustParseAddr(want))) {
+			t.Errorf("%q: got %x, want the bytes of %s", value, got, want)
+		}
+	}
+
+	for _, value := range []string{"", "192.0.2", "192.0.2.0/24", "2001:db8::g"} {
+		if got := addressColumn(value); got != nil {
+			t.Errorf("%q: got %x, want nil", value, got)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"net/netip"
+	"slices"
+)
+
+// IXPrefix is a prefix used by an IX LAN.
+type IXPrefix struct {
+	Prefix   netip.Prefix
+	IXLANID  int
+	IXID     int
+	IXName   string
+	Protocol string
+}
+
+// IPOwner is a network using an address on an IX LAN.
+type IPOwner struct {
+	NetID       int    `json:"net_id" yaml:"net_id"`
+	ASN         int    `json:"asn" yaml:"asn"`
+	Name        string `json:"name" yaml:"name"`
+	IXLANID     int    `json:"ixlan_id" yaml:"ixlan_id"`
+	Speed       int    `json:"speed" yaml:"speed"`
+	IsRSPeer    bool   `json:"is_rs_peer" yaml:"is_rs_peer"`
+	Operational bool   `json:"operational" yaml:"operational"`
+}
+
+// IPLookup is the result of the lookup of an address.
+type IPLookup struct {
+	Address string    `json:"address" yaml:"address"`
+	Prefix  string    `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	IXID    int       `json:"ix_id,omitempty" yaml:"ix_id,omitempty"`
+	IXName  string    `json:"ix_name,omitempty" yaml:"ix_name,omitempty"`
+	IXLANID int       `json:"ixlan_id,omitempty" yaml:"ixlan_id,omitempty"`
+	Owners  []IPOwner `json:"owners" yaml:"owners"`
+}
+
+// IPIndex is an in-memory index of the IX LAN prefixes and of the addresses
+// used by networks on them. Building it reads the whole tables once, which
+// pays off when looking up many addresses; LookupIP is cheaper for a single
+// one.
+type IPIndex struct {
+	// prefixes holds the prefixes by length, lengths are sorted from the
+	// most specific to the least specific one
+	prefixes map[int]map[netip.Prefix]IXPrefix
+	lengths  []int
+	owners   map[netip.Addr][]IPOwner
+}
+
+// NewIPIndex reads the prefixes and the addresses from the database to build
+// the index. Invalid prefixes or addresses are ignored.
+func NewIPIndex(db *sql.DB) (*IPIndex, error) {
+	index := &IPIndex{
+		prefixes: make(map[int]map[netip.Prefix]IXPrefix),
+		owners:   make(map[netip.Addr][]IPOwner),
+	}
+
+	err := queryAll(db, func(rows *sql.Rows) error {
+		var value string
+		var p IXPrefix
+		if err := rows.Scan(&value, &p.Protocol, &p.IXLANID, &p.IXID, &p.IXName); err != nil {
+			return err
+		}
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			p.Prefix = prefix.Masked()
+			index.addPrefix(p)
+		}
+		return nil
+	}, `SELECT pfx.prefix, pfx.protocol, pfx.ixlan_id, ix.id, ix.name
+		FROM peeringdb_ix_prefix pfx
+		JOIN peeringdb_ixlan ixlan ON ixlan.id = pfx.ixlan_id
+		JOIN peeringdb_ix ix ON ix.id = ixlan.ix_id
+		WHERE pfx.status = 'ok'`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryAll(db, func(rows *sql.Rows) error {
+		var ipaddr4, ipaddr6 string
+		var o IPOwner
+		if err := rows.Scan(&ipaddr4, &ipaddr6, &o.NetID, &o.ASN, &o.Name, &o.IXLANID, &o.Speed, &o.IsRSPeer, &o.Operational); err != nil {
+			return err
+		}
+		for _, value := range []string{ipaddr4, ipaddr6} {
+			if addr, err := netip.ParseAddr(value); err == nil {
+				addr = addr.Unmap()
+				index.owners[addr] = append(index.owners[addr], o)
+			}
+		}
+		return nil
+	}, `SELECT COALESCE(nixl.ipaddr4, ''), COALESCE(nixl.ipaddr6, ''), nixl.net_id, nixl.asn,
+		COALESCE(net.name, ''), nixl.ixlan_id, nixl.speed, nixl.is_rs_peer, nixl.operational
+		FROM peeringdb_network_ixlan nixl
+		LEFT JOIN peeringdb_network net ON net.id = nixl.net_id
+		WHERE nixl.status = 'ok'
+		ORDER BY nixl.asn`)
+	if err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// addPrefix adds the prefix to the index.
+func (i *IPIndex) addPrefix(p IXPrefix) {
+	length := p.Prefix.Bits()
+	if _, ok := i.prefixes[length]; !ok {
+		i.prefixes[length] = make(map[netip.Prefix]IXPrefix)
+		i.lengths = append(i.lengths, length)
+		slices.Sort(i.lengths)
+		slices.Reverse(i.lengths)
+	}
+	i.prefixes[length][p.Prefix] = p
+}
+
+// Prefix returns the most specific IX LAN prefix containing the address.
+func (i *IPIndex) Prefix(addr netip.Addr) (IXPrefix, bool) {
+	addr = addr.Unmap()
+	for _, length := range i.lengths {
+		if length > addr.BitLen() {
+			continue
+		}
+
+		prefix, err := addr.Prefix(length)
+		if err != nil {
+			continue
+		}
+		if p, ok := i.prefixes[length][prefix]; ok {
+			return p, true
+		}
+	}
+
+	return IXPrefix{}, false
+}
+
+// Owners returns the networks using the address on an IX LAN.
+func (i *IPIndex) Owners(addr netip.Addr) []IPOwner {
+	return i.owners[addr.Unmap()]
+}
+
+// Lookup returns the IX LAN the address belongs to and the networks using
+// it. It returns ErrNotFound if the address is neither in an IX LAN prefix
+// nor used by a network.
+func (i *IPIndex) Lookup(addr netip.Addr) (*IPLookup, error) {
+	addr = addr.Unmap()
+	result := &IPLookup{Address: addr.String(), Owners: []IPOwner{}}
+
+	prefix, found := i.Prefix(addr)
+	if found {
+		result.Prefix = prefix.Prefix.String()
+		result.IXID, result.IXName, result.IXLANID = prefix.IXID, prefix.IXName, prefix.IXLANID
+	}
+
+	if owners := i.Owners(addr); len(owners) > 0 {
+		result.Owners = append(result.Owners, owners...)
+		found = true
+	}
+
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	return result, nil
+}
+
+// LookupIP looks up a single address with queries on the columns derived
+// from the prefixes and the addresses, which are indexed. Use an IPIndex to
+// look up many addresses.
+func LookupIP(db *sql.DB, addr netip.Addr) (*IPLookup, error) {
+	addr = addr.Unmap()
+	key := addressBytes(addr)
+	result := &IPLookup{Address: addr.String(), Owners: []IPOwner{}}
+
+	var value string
+	err := db.QueryRow(`SELECT pfx.prefix, pfx.ixlan_id, ix.id, ix.name
+		FROM peeringdb_ix_prefix pfx
+		JOIN peeringdb_ixlan ixlan ON ixlan.id = pfx.ixlan_id
+		JOIN peeringdb_ix ix ON ix.id = ixlan.ix_id
+		WHERE pfx.status = 'ok' AND pfx.family = ? AND pfx.prefix_start <= ? AND pfx.prefix_end >= ?
+		ORDER BY pfx.prefix_length DESC
+		LIMIT 1`, addressFamily(addr), key, key).Scan(&value, &result.IXLANID, &result.IXID, &result.IXName)
+	switch {
+	case err == nil:
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, err
+		}
+		result.Prefix = prefix.Masked().String()
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	column := "ipaddr4_bin"
+	if addr.Is6() {
+		column = "ipaddr6_bin"
+	}
+	err = queryAll(db, func(rows *sql.Rows) error {
+		var o IPOwner
+		if err := rows.Scan(&o.NetID, &o.ASN, &o.Name, &o.IXLANID, &o.Speed, &o.IsRSPeer, &o.Operational); err != nil {
+			return err
+		}
+		result.Owners = append(result.Owners, o)
+		return nil
+	}, `SELECT nixl.net_id, nixl.asn, COALESCE(net.name, ''), nixl.ixlan_id, nixl.speed, nixl.is_rs_peer, nixl.operational
+		FROM peeringdb_network_ixlan nixl
+		LEFT JOIN peeringdb_network net ON net.id = nixl.net_id
+		WHERE nixl.`+column+` = ? AND nixl.status = 'ok'
+		ORDER BY nixl.asn`, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Prefix == "" && len(result.Owners) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return result, nil
+}
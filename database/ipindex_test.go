@@ -0,0 +1,69 @@
+package database
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestLookupIP(t *testing.T) {
+	st := newSyncTest(t, false)
+
+	// A more specific prefix of the same IX LAN
+	more := st.server.Object("ixpfx", 100)
+	more["id"], more["prefix"] = 102, "192.0.2.128/25"
+	if err := st.server.Put("ixpfx", more); err != nil {
+		t.Fatal(err)
+	}
+	st.runAll()
+
+	first := IPOwner{NetID: 10, ASN: 64500, Name: "Example Network", IXLANID: 30, Speed: 100000, IsRSPeer: true, Operational: true}
+	second := IPOwner{NetID: 11, ASN: 64501, Name: "Second Network", IXLANID: 30, Speed: 10000}
+	lookup := func(address, prefix string, owners ...IPOwner) *IPLookup {
+		return &IPLookup{Address: address, Prefix: prefix, IXID: 20, IXName: "Example-IX", IXLANID: 30, Owners: append([]IPOwner{}, owners...)}
+	}
+
+	index, err := NewIPIndex(st.sync.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		address string
+		want    *IPLookup
+	}{
+		{"192.0.2.10", lookup("192.0.2.10", "192.0.2.0/24", first)},
+		{"::ffff:192.0.2.11", lookup("192.0.2.11", "192.0.2.0/24", second)},
+		{"192.0.2.99", lookup("192.0.2.99", "192.0.2.0/24")},
+		{"192.0.2.200", lookup("192.0.2.200", "192.0.2.128/25")},
+		{"2001:db8::10", lookup("2001:db8::10", "2001:db8::/64", first)},
+		{"2001:0db8:0:0::0010", lookup("2001:db8::10", "2001:db8::/64", first)},
+		{"2001:db8::ffff:ffff:ffff:ffff", lookup("2001:db8::ffff:ffff:ffff:ffff", "2001:db8::/64")},
+		// IPv4-compatible IPv6 addresses are not IPv4 addresses
+		{"::c000:20a", nil},
+		{"2001:db8:0:1::10", nil},
+		{"198.51.100.1", nil},
+	} {
+		addr := netip.MustParseAddr(tc.address)
+		for name, lookupIP := range map[string]func(netip.Addr) (*IPLookup, error){
+			"LookupIP":       func(addr netip.Addr) (*IPLookup, error) { return LookupIP(st.sync.DB, addr) },
+			"IPIndex.Lookup": index.Lookup,
+		} {
+			got, err := lookupIP(addr)
+			if tc.want == nil {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("%s(%s): got %+v, %v, want ErrNotFound", name, tc.address, got, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s(%s): %v", name, tc.address, err)
+				continue
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s(%s): got %+v, want %+v", name, tc.address, got, tc.want)
+			}
+		}
+	}
+}
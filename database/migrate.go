@@ -0,0 +1,247 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Migrate upgrades a database created with an older version of the schema.
+// Missing tables, columns and indexes, spatial ones included, are created,
+// views are created or replaced if their definition has changed and derived
+// columns are filled for the rows lacking them. The full-text search
+// index is created if SQLite supports it. It does nothing on an up to date
+// database.
+func Migrate(db *sql.DB, schema *Schema) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	names := schema.GetTableNames()
+	slices.Sort(names)
+	for _, name := range names {
+		table := schema.Tables[name]
+		if err = migrateTable(tx, &table); err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", name, err)
+		}
+	}
+
+	if err = createStateTables(tx); err != nil {
+		return err
+	}
+
+	for _, index := range schema.Indexes {
+		if _, err = tx.Exec(strings.Replace(index, "CREATE INDEX ", "CREATE INDEX IF NOT EXISTS ", 1)); err != nil {
+			return err
+		}
+	}
+
+	for _, view := range schema.Views {
+		if err = migrateView(tx, &view); err != nil {
+			return fmt.Errorf("failed to migrate view %s: %w", view.Name, err)
+		}
+	}
+
+	for _, index := range schema.SpatialIndexes {
+		if err = migrateSpatialIndex(tx, &index); err != nil {
+			return fmt.Errorf("failed to create %s: %w", index.Name(), err)
+		}
+	}
+
+	if err = backfillPrefixes(tx); err != nil {
+		return fmt.Errorf("failed to fill derived prefix columns: %w", err)
+	}
+	if err = backfillAddresses(tx); err != nil {
+		return fmt.Errorf("failed to fill derived address columns: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	if err = CreateSearchIndex(db); err != nil {
+		return fmt.Errorf("failed to create the search index: %w", err)
+	}
+
+	return nil
+}
+
+// migrateTable creates the table if it does not exist or adds its missing
+// columns. Columns are only ever added at the end of tables so the columns
+// of the schema must be appended in the same way.
+func migrateTable(tx *sql.Tx, table *Table) error {
+	var existing []string
+	err := func() error {
+		rows, err := tx.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table.Name))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return err
+			}
+			existing = append(existing, name)
+		}
+		return rows.Err()
+	}()
+	if err != nil {
+		return err
+	}
+
+	if len(existing) == 0 {
+		slog.Info("creating table", "table", table.Name)
+		_, err = tx.Exec(table.generateCreateTableQuery())
+		return err
+	}
+
+	for _, column := range table.Columns {
+		if slices.Contains(existing, column.Name) {
+			continue
+		}
+
+		slog.Info("adding column", "table", table.Name, "column", column.Name)
+		if _, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s %s", table.Name, column.Name, column.Type, column.Constraints)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateView creates the view if it does not exist or replaces it if its
+// definition is outdated.
+func migrateView(tx *sql.Tx, view *View) error {
+	var definition string
+	err := tx.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'view' AND name = ?", view.Name).Scan(&definition)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		slog.Info("creating view", "view", view.Name)
+	case err != nil:
+		return err
+	case definition+";" == view.generateCreateViewQuery():
+		return nil
+	default:
+		slog.Info("replacing view", "view", view.Name)
+		if _, err = tx.Exec("DROP VIEW " + view.Name); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(view.generateCreateViewQuery())
+	return err
+}
+
+// backfillPrefixes fills the derived columns of the IX prefixes lacking them.
+func backfillPrefixes(tx *sql.Tx) error {
+	type row struct {
+		id     int
+		prefix string
+	}
+
+	var pending []row
+	err := func() error {
+		rows, err := tx.Query("SELECT id, prefix FROM peeringdb_ix_prefix WHERE family IS NULL")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.prefix); err != nil {
+				return err
+			}
+			pending = append(pending, r)
+		}
+		return rows.Err()
+	}()
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	statement, err := tx.Prepare("UPDATE peeringdb_ix_prefix SET family = ?, prefix_start = ?, prefix_end = ?, prefix_length = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	filled := 0
+	for _, r := range pending {
+		family, start, end, length := prefixColumns(r.prefix)
+		if family == nil {
+			continue
+		}
+		if _, err = statement.Exec(family, start, end, length, r.id); err != nil {
+			return err
+		}
+		filled++
+	}
+	if filled > 0 {
+		slog.Info("derived columns filled", "table", "peeringdb_ix_prefix", "rows", filled)
+	}
+
+	return nil
+}
+
+// backfillAddresses fills the derived columns of the network IX LAN
+// connections lacking them.
+func backfillAddresses(tx *sql.Tx) error {
+	type row struct {
+		id               int
+		ipaddr4, ipaddr6 string
+	}
+
+	var pending []row
+	err := func() error {
+		rows, err := tx.Query(`SELECT id, COALESCE(ipaddr4, ''), COALESCE(ipaddr6, '') FROM peeringdb_network_ixlan
+			WHERE (ipaddr4 IS NOT NULL AND ipaddr4 != '' AND ipaddr4_bin IS NULL)
+			OR (ipaddr6 IS NOT NULL AND ipaddr6 != '' AND ipaddr6_bin IS NULL)`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.ipaddr4, &r.ipaddr6); err != nil {
+				return err
+			}
+			pending = append(pending, r)
+		}
+		return rows.Err()
+	}()
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	statement, err := tx.Prepare("UPDATE peeringdb_network_ixlan SET ipaddr4_bin = ?, ipaddr6_bin = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	filled := 0
+	for _, r := range pending {
+		ipaddr4, ipaddr6 := addressColumn(r.ipaddr4), addressColumn(r.ipaddr6)
+		if ipaddr4 == nil && ipaddr6 == nil {
+			continue
+		}
+		if _, err = statement.Exec(ipaddr4, ipaddr6, r.id); err != nil {
+			return err
+		}
+		filled++
+	}
+	if filled > 0 {
+		slog.Info("derived columns filled", "table", "peeringdb_network_ixlan", "rows", filled)
+	}
+
+	return nil
+}
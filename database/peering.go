@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// PeeringConnection is the connection of a network to an Internet exchange.
+type PeeringConnection struct {
+	ASN      int    `json:"asn" yaml:"asn"`
+	Speed    int    `json:"speed" yaml:"speed"`
+	IPAddr4  string `json:"ipaddr4,omitempty" yaml:"ipaddr4,omitempty"`
+	IPAddr6  string `json:"ipaddr6,omitempty" yaml:"ipaddr6,omitempty"`
+	IsRSPeer bool   `json:"is_rs_peer" yaml:"is_rs_peer"`
+}
+
+// CommonExchange is an Internet exchange where all the networks of a set are
+// connected.
+type CommonExchange struct {
+	ExchangeSummary `yaml:",inline"`
+	Connections     []PeeringConnection `json:"connections" yaml:"connections"`
+}
+
+// CommonLocations lists the Internet exchanges and facilities shared by a set
+// of networks.
+type CommonLocations struct {
+	ASNs       []int             `json:"asns" yaml:"asns"`
+	Exchanges  []CommonExchange  `json:"exchanges" yaml:"exchanges"`
+	Facilities []FacilitySummary `json:"facilities" yaml:"facilities"`
+}
+
+// placeholders returns the ASNs as query arguments along with the matching
+// list of placeholders.
+func placeholders(asns []int) (string, []interface{}) {
+	args := make([]interface{}, len(asns))
+	for i, asn := range asns {
+		args[i] = asn
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(asns)), ", "), args
+}
+
+// GetCommonExchanges returns the Internet exchanges where all the given ASNs
+// are connected, with the connections of each of them.
+func GetCommonExchanges(db *sql.DB, asns []int) ([]CommonExchange, error) {
+	list, args := placeholders(asns)
+	args = append(args, len(asns))
+	args = append(args, args[:len(asns)]...)
+
+	exchanges := []CommonExchange{}
+	err := queryAll(db, func(rows *sql.Rows) error {
+		var e ExchangeSummary
+		var c PeeringConnection
+		if err := rows.Scan(&e.ID, &e.Name, &e.City, &e.Country, &c.ASN, &c.Speed, &c.IPAddr4, &c.IPAddr6, &c.IsRSPeer); err != nil {
+			return err
+		}
+
+		// Rows are ordered by IX, connections to the same IX are grouped
+		if len(exchanges) == 0 || exchanges[len(exchanges)-1].ID != e.ID {
+			exchanges = append(exchanges, CommonExchange{ExchangeSummary: e})
+		}
+		last := &exchanges[len(exchanges)-1]
+		last.Connections = append(last.Connections, c)
+		return nil
+	}, `SELECT ix.id, ix.name, ix.city, ix.country, nixl.asn, nixl.speed, COALESCE(nixl.ipaddr4, ''),
+		COALESCE(nixl.ipaddr6, ''), nixl.is_rs_peer
+		FROM peeringdb_network_ixlan nixl
+		JOIN peeringdb_ix ix ON ix.id = nixl.ix_id
+		WHERE nixl.ix_id IN (
+			SELECT ix_id FROM peeringdb_network_ixlan
+			WHERE asn IN (`+list+`) AND status = 'ok'
+			GROUP BY ix_id HAVING COUNT(DISTINCT asn) = ?
+		) AND nixl.asn IN (`+list+`) AND nixl.status = 'ok'
+		ORDER BY ix.name, ix.id, nixl.asn, nixl.ipaddr4, nixl.ipaddr6`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return exchanges, nil
+}
+
+// GetCommonFacilities returns the facilities where all the given ASNs are
+// present.
+func GetCommonFacilities(db *sql.DB, asns []int) ([]FacilitySummary, error) {
+	list, args := placeholders(asns)
+	args = append(args, len(asns))
+
+	facilities := []FacilitySummary{}
+	err := queryAll(db, func(rows *sql.Rows) error {
+		var f FacilitySummary
+		if err := rows.Scan(&f.ID, &f.Name, &f.City, &f.Country); err != nil {
+			return err
+		}
+		facilities = append(facilities, f)
+		return nil
+	}, `SELECT fac.id, fac.name, fac.city, fac.country
+		FROM peeringdb_facility fac
+		WHERE fac.id IN (
+			SELECT netfac.fac_id FROM peeringdb_network_facility netfac
+			JOIN peeringdb_network net ON net.id = netfac.net_id
+			WHERE net.asn IN (`+list+`) AND netfac.status = 'ok'
+			GROUP BY netfac.fac_id HAVING COUNT(DISTINCT net.asn) = ?
+		)
+		ORDER BY fac.country, fac.city, fac.name`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return facilities, nil
+}
+
+// GetCommonLocations returns the Internet exchanges and the facilities shared
+// by all the given ASNs. Either of them can be skipped, they are then left
+// empty.
+func GetCommonLocations(db *sql.DB, asns []int, exchanges, facilities bool) (*CommonLocations, error) {
+	locations := &CommonLocations{ASNs: asns, Exchanges: []CommonExchange{}, Facilities: []FacilitySummary{}}
+
+	var err error
+	if exchanges {
+		if locations.Exchanges, err = GetCommonExchanges(db, asns); err != nil {
+			return nil, err
+		}
+	}
+	if facilities {
+		if locations.Facilities, err = GetCommonFacilities(db, asns); err != nil {
+			return nil, err
+		}
+	}
+
+	return locations, nil
+}
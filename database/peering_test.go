@@ -0,0 +1,78 @@
+package database
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGetCommonExchanges(t *testing.T) {
+	store := openFixture(t)
+
+	exchanges, err := GetCommonExchanges(store.db, []int{64501, 64500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CommonExchange{{
+		ExchangeSummary: ExchangeSummary{ID: 20, Name: "Example-IX", City: "Frankfurt", Country: "DE"},
+		Connections: []PeeringConnection{
+			{ASN: 64500, Speed: 10000, IPAddr4: "192.0.2.10", IPAddr6: "2001:db8::10", IsRSPeer: true},
+			{ASN: 64500, Speed: 10000, IPAddr4: "192.0.2.11", IPAddr6: "2001:db8::11"},
+			{ASN: 64501, Speed: 1000, IPAddr4: "192.0.2.20", IsRSPeer: true},
+		},
+	}}
+	if !reflect.DeepEqual(exchanges, want) {
+		t.Errorf("got %+v, want %+v", exchanges, want)
+	}
+
+	// The connection of AS64502 to Unused-IX has been deleted
+	if exchanges, err = GetCommonExchanges(store.db, []int{64500, 64502}); err != nil || exchanges == nil || len(exchanges) != 0 {
+		t.Errorf("got %+v (%v), want no exchange", exchanges, err)
+	}
+}
+
+func TestGetCommonFacilities(t *testing.T) {
+	store := openFixture(t)
+
+	facilities, err := GetCommonFacilities(store.db, []int{64500, 64501})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FacilitySummary{{ID: 50, Name: "Example DC Frankfurt", City: "Frankfurt", Country: "DE"}}
+	if !reflect.DeepEqual(facilities, want) {
+		t.Errorf("got %+v, want %+v", facilities, want)
+	}
+
+	// The presence of AS64502 in Frankfurt has been deleted
+	if facilities, err = GetCommonFacilities(store.db, []int{64501, 64502}); err != nil || facilities == nil || len(facilities) != 0 {
+		t.Errorf("got %+v (%v), want no facility", facilities, err)
+	}
+}
+
+func TestGetCommonLocations(t *testing.T) {
+	store := openFixture(t)
+
+	// Skipped locations are listed as empty rather than null
+	for _, tc := range []struct {
+		exchanges, facilities bool
+		want                  string
+	}{
+		{true, false, `{"asns":[64500,64501],"exchanges":[{"id":20,"name":"Example-IX","city":"Frankfurt","country":"DE","connections":[` +
+			`{"asn":64500,"speed":10000,"ipaddr4":"192.0.2.10","ipaddr6":"2001:db8::10","is_rs_peer":true},` +
+			`{"asn":64500,"speed":10000,"ipaddr4":"192.0.2.11","ipaddr6":"2001:db8::11","is_rs_peer":false},` +
+			`{"asn":64501,"speed":1000,"ipaddr4":"192.0.2.20","is_rs_peer":true}]}],"facilities":[]}`},
+		{false, true, `{"asns":[64500,64501],"exchanges":[],"facilities":[{"id":50,"name":"Example DC Frankfurt","city":"Frankfurt","country":"DE"}]}`},
+	} {
+		locations, err := GetCommonLocations(store.db, []int{64500, 64501}, tc.exchanges, tc.facilities)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := json.Marshal(locations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(encoded) != tc.want {
+			t.Errorf("got %s, want %s", encoded, tc.want)
+		}
+	}
+}
@@ -0,0 +1,453 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned when no record matches a lookup.
+var ErrNotFound = errors.New("no matching record found")
+
+// AmbiguousError is returned when a name matches several records.
+type AmbiguousError struct {
+	Table   string
+	Name    string
+	Matches []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("several records of %s match, use one of the IDs: %s", e.Table, strings.Join(e.Matches, ", "))
+}
+
+// ExchangePresence is the connection of a network to an IX LAN.
+type ExchangePresence struct {
+	IXID        int    `json:"ix_id" yaml:"ix_id"`
+	IXName      string `json:"ix_name" yaml:"ix_name"`
+	IXLANID     int    `json:"ixlan_id" yaml:"ixlan_id"`
+	Speed       int    `json:"speed" yaml:"speed"`
+	IPAddr4     string `json:"ipaddr4,omitempty" yaml:"ipaddr4,omitempty"`
+	IPAddr6     string `json:"ipaddr6,omitempty" yaml:"ipaddr6,omitempty"`
+	IsRSPeer    bool   `json:"is_rs_peer" yaml:"is_rs_peer"`
+	Operational bool   `json:"operational" yaml:"operational"`
+}
+
+// FacilityPresence is the presence of a network in a facility.
+type FacilityPresence struct {
+	FacID    int    `json:"fac_id" yaml:"fac_id"`
+	Name     string `json:"name" yaml:"name"`
+	City     string `json:"city" yaml:"city"`
+	Country  string `json:"country" yaml:"country"`
+	LocalASN int    `json:"local_asn,omitempty" yaml:"local_asn,omitempty"`
+}
+
+// NetworkDetails describes a network with its IX and facility presence.
+type NetworkDetails struct {
+	ID            int                `json:"id" yaml:"id"`
+	ASN           int                `json:"asn" yaml:"asn"`
+	Name          string             `json:"name" yaml:"name"`
+	AKA           string             `json:"aka,omitempty" yaml:"aka,omitempty"`
+	Status        string             `json:"status" yaml:"status"`
+	Website       string             `json:"website,omitempty" yaml:"website,omitempty"`
+	IRRASSet      string             `json:"irr_as_set,omitempty" yaml:"irr_as_set,omitempty"`
+	InfoType      string             `json:"info_type,omitempty" yaml:"info_type,omitempty"`
+	PolicyGeneral string             `json:"policy_general,omitempty" yaml:"policy_general,omitempty"`
+	PolicyURL     string             `json:"policy_url,omitempty" yaml:"policy_url,omitempty"`
+	OrgID         int                `json:"org_id" yaml:"org_id"`
+	Organization  string             `json:"organization" yaml:"organization"`
+	Exchanges     []ExchangePresence `json:"exchanges" yaml:"exchanges"`
+	Facilities    []FacilityPresence `json:"facilities" yaml:"facilities"`
+}
+
+// NetworkSummary identifies a network.
+type NetworkSummary struct {
+	ID   int    `json:"id" yaml:"id"`
+	ASN  int    `json:"asn" yaml:"asn"`
+	Name string `json:"name" yaml:"name"`
+}
+
+// ExchangeSummary identifies an Internet exchange.
+type ExchangeSummary struct {
+	ID      int    `json:"id" yaml:"id"`
+	Name    string `json:"name" yaml:"name"`
+	City    string `json:"city" yaml:"city"`
+	Country string `json:"country" yaml:"country"`
+}
+
+// FacilitySummary identifies a facility.
+type FacilitySummary struct {
+	ID      int    `json:"id" yaml:"id"`
+	Name    string `json:"name" yaml:"name"`
+	City    string `json:"city" yaml:"city"`
+	Country string `json:"country" yaml:"country"`
+}
+
+// ExchangeMember is a network connected to an Internet exchange.
+type ExchangeMember struct {
+	ASN      int    `json:"asn" yaml:"asn"`
+	Name     string `json:"name" yaml:"name"`
+	Speed    int    `json:"speed" yaml:"speed"`
+	IPAddr4  string `json:"ipaddr4,omitempty" yaml:"ipaddr4,omitempty"`
+	IPAddr6  string `json:"ipaddr6,omitempty" yaml:"ipaddr6,omitempty"`
+	IsRSPeer bool   `json:"is_rs_peer" yaml:"is_rs_peer"`
+}
+
+// ExchangeDetails describes an Internet exchange with its prefixes, facilities
+// and members.
+type ExchangeDetails struct {
+	ID           int               `json:"id" yaml:"id"`
+	Name         string            `json:"name" yaml:"name"`
+	NameLong     string            `json:"name_long,omitempty" yaml:"name_long,omitempty"`
+	Status       string            `json:"status" yaml:"status"`
+	City         string            `json:"city" yaml:"city"`
+	Country      string            `json:"country" yaml:"country"`
+	Website      string            `json:"website,omitempty" yaml:"website,omitempty"`
+	OrgID        int               `json:"org_id" yaml:"org_id"`
+	Organization string            `json:"organization" yaml:"organization"`
+	Prefixes     []string          `json:"prefixes" yaml:"prefixes"`
+	Facilities   []FacilitySummary `json:"facilities" yaml:"facilities"`
+	Members      []ExchangeMember  `json:"members" yaml:"members"`
+}
+
+// FacilityDetails describes a facility with the networks and Internet
+// exchanges present in it.
+type FacilityDetails struct {
+	ID           int               `json:"id" yaml:"id"`
+	Name         string            `json:"name" yaml:"name"`
+	Status       string            `json:"status" yaml:"status"`
+	Address1     string            `json:"address1,omitempty" yaml:"address1,omitempty"`
+	Address2     string            `json:"address2,omitempty" yaml:"address2,omitempty"`
+	City         string            `json:"city" yaml:"city"`
+	State        string            `json:"state,omitempty" yaml:"state,omitempty"`
+	Zipcode      string            `json:"zipcode,omitempty" yaml:"zipcode,omitempty"`
+	Country      string            `json:"country" yaml:"country"`
+	Latitude     *float64          `json:"latitude,omitempty" yaml:"latitude,omitempty"`
+	Longitude    *float64          `json:"longitude,omitempty" yaml:"longitude,omitempty"`
+	Website      string            `json:"website,omitempty" yaml:"website,omitempty"`
+	OrgID        int               `json:"org_id" yaml:"org_id"`
+	Organization string            `json:"organization" yaml:"organization"`
+	Exchanges    []ExchangeSummary `json:"exchanges" yaml:"exchanges"`
+	Networks     []NetworkSummary  `json:"networks" yaml:"networks"`
+}
+
+// OrganizationDetails describes an organization with the objects it owns.
+type OrganizationDetails struct {
+	ID         int               `json:"id" yaml:"id"`
+	Name       string            `json:"name" yaml:"name"`
+	AKA        string            `json:"aka,omitempty" yaml:"aka,omitempty"`
+	Status     string            `json:"status" yaml:"status"`
+	Website    string            `json:"website,omitempty" yaml:"website,omitempty"`
+	City       string            `json:"city,omitempty" yaml:"city,omitempty"`
+	Country    string            `json:"country,omitempty" yaml:"country,omitempty"`
+	Networks   []NetworkSummary  `json:"networks" yaml:"networks"`
+	Exchanges  []ExchangeSummary `json:"exchanges" yaml:"exchanges"`
+	Facilities []FacilitySummary `json:"facilities" yaml:"facilities"`
+}
+
+// queryAll runs the query and calls scan for each row returned.
+func queryAll(db *sql.DB, scan func(*sql.Rows) error, query string, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, along with the escape
+// character itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// resolveID returns the ID of the record of the table designated by the given
+// value. The value can be an ID, a name or an alias; a name partially
+// matching a single record is also accepted.
+func resolveID(db *sql.DB, table, value string) (int, error) {
+	if id, err := strconv.Atoi(value); err == nil {
+		return id, nil
+	}
+
+	queries := []struct {
+		query string
+		arg   string
+	}{
+		{fmt.Sprintf("SELECT id, name FROM %s WHERE name = ?1 COLLATE NOCASE OR aka = ?1 COLLATE NOCASE ORDER BY name", table), value},
+		{fmt.Sprintf(`SELECT id, name FROM %s WHERE name LIKE ?1 ESCAPE '\' OR aka LIKE ?1 ESCAPE '\' ORDER BY name`, table), "%" + likeEscaper.Replace(value) + "%"},
+	}
+	for _, q := range queries {
+		var ids []int
+		var names []string
+		err := queryAll(db, func(rows *sql.Rows) error {
+			var id int
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+			names = append(names, fmt.Sprintf("%s (%d)", name, id))
+			return nil
+		}, q.query, q.arg)
+		if err != nil {
+			return 0, err
+		}
+
+		switch len(ids) {
+		case 0:
+			continue
+		case 1:
+			return ids[0], nil
+		default:
+			return 0, &AmbiguousError{Table: table, Name: value, Matches: names}
+		}
+	}
+
+	return 0, ErrNotFound
+}
+
+// scanOne wraps the error of a single row lookup to return ErrNotFound when
+// there is no row.
+func scanOne(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// organizationName returns the name of the organization with the given ID,
+// an empty string if there is none.
+func (s *Store) organizationName(id int) (string, error) {
+	var name string
+	err := s.db.QueryRow("SELECT name FROM peeringdb_organization WHERE id = ?", id).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return name, err
+}
+
+// DescribeNetwork returns the network using the given ASN along with the
+// Internet exchanges and facilities where it is present.
+func (s *Store) DescribeNetwork(asn int) (*NetworkDetails, error) {
+	network, err := s.NetworkByASN(asn)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &NetworkDetails{
+		ID: network.ID, ASN: network.ASN, Name: network.Name, AKA: network.AKA, Status: network.Status,
+		Website: network.Website, IRRASSet: network.IRRASSet, InfoType: network.InfoType,
+		PolicyGeneral: network.PolicyGeneral, PolicyURL: network.PolicyURL, OrgID: network.OrganizationID,
+		Exchanges: []ExchangePresence{}, Facilities: []FacilityPresence{},
+	}
+	if n.Organization, err = s.organizationName(n.OrgID); err != nil {
+		return nil, err
+	}
+
+	err = queryAll(s.db, func(rows *sql.Rows) error {
+		var e ExchangePresence
+		if err := rows.Scan(&e.IXID, &e.IXName, &e.IXLANID, &e.Speed, &e.IPAddr4, &e.IPAddr6, &e.IsRSPeer, &e.Operational); err != nil {
+			return err
+		}
+		n.Exchanges = append(n.Exchanges, e)
+		return nil
+	}, `SELECT ix.id, ix.name, ixlan.id, nixl.speed, COALESCE(nixl.ipaddr4, ''), COALESCE(nixl.ipaddr6, ''),
+		nixl.is_rs_peer, nixl.operational
+		FROM peeringdb_network_ixlan nixl
+		JOIN peeringdb_ixlan ixlan ON ixlan.id = nixl.ixlan_id
+		JOIN peeringdb_ix ix ON ix.id = ixlan.ix_id
+		WHERE nixl.net_id = ? AND nixl.status = 'ok'
+		ORDER BY ix.name, nixl.ipaddr4, nixl.ipaddr6`, n.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryAll(s.db, func(rows *sql.Rows) error {
+		var f FacilityPresence
+		if err := rows.Scan(&f.FacID, &f.Name, &f.City, &f.Country, &f.LocalASN); err != nil {
+			return err
+		}
+		n.Facilities = append(n.Facilities, f)
+		return nil
+	}, `SELECT fac.id, fac.name, fac.city, fac.country, COALESCE(netfac.local_asn, 0)
+		FROM peeringdb_network_facility netfac
+		JOIN peeringdb_facility fac ON fac.id = netfac.fac_id
+		WHERE netfac.net_id = ? AND netfac.status = 'ok'
+		ORDER BY fac.country, fac.city, fac.name`, n.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// DescribeExchange returns the Internet exchange designated by the given ID
+// or name along with its prefixes, facilities and members.
+func (s *Store) DescribeExchange(idOrName string) (*ExchangeDetails, error) {
+	id, err := resolveID(s.db, "peeringdb_ix", idOrName)
+	if err != nil {
+		return nil, err
+	}
+	exchange, err := s.InternetExchangeByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ix := &ExchangeDetails{
+		ID: exchange.ID, Name: exchange.Name, NameLong: exchange.NameLong, Status: exchange.Status,
+		City: exchange.City, Country: exchange.Country, Website: exchange.Website, OrgID: exchange.OrganizationID,
+		Prefixes: []string{}, Facilities: []FacilitySummary{}, Members: []ExchangeMember{},
+	}
+	if ix.Organization, err = s.organizationName(ix.OrgID); err != nil {
+		return nil, err
+	}
+
+	err = queryAll(s.db, func(rows *sql.Rows) error {
+		var prefix string
+		if err := rows.Scan(&prefix); err != nil {
+			return err
+		}
+		ix.Prefixes = append(ix.Prefixes, prefix)
+		return nil
+	}, `SELECT pfx.prefix
+		FROM peeringdb_ix_prefix pfx
+		JOIN peeringdb_ixlan ixlan ON ixlan.id = pfx.ixlan_id
+		WHERE ixlan.ix_id = ? AND pfx.status = 'ok'
+		ORDER BY pfx.protocol, pfx.prefix`, ix.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	facilities, err := s.FacilitiesForIX(ix.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range facilities {
+		ix.Facilities = append(ix.Facilities, FacilitySummary{ID: f.ID, Name: f.Name, City: f.City, Country: f.Country})
+	}
+
+	err = queryAll(s.db, func(rows *sql.Rows) error {
+		var m ExchangeMember
+		if err := rows.Scan(&m.ASN, &m.Name, &m.Speed, &m.IPAddr4, &m.IPAddr6, &m.IsRSPeer); err != nil {
+			return err
+		}
+		ix.Members = append(ix.Members, m)
+		return nil
+	}, `SELECT nixl.asn, COALESCE(net.name, ''), nixl.speed, COALESCE(nixl.ipaddr4, ''), COALESCE(nixl.ipaddr6, ''),
+		nixl.is_rs_peer
+		FROM peeringdb_network_ixlan nixl
+		LEFT JOIN peeringdb_network net ON net.id = nixl.net_id
+		WHERE nixl.ix_id = ? AND nixl.status = 'ok'
+		ORDER BY nixl.asn, nixl.ipaddr4, nixl.ipaddr6`, ix.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ix, nil
+}
+
+// DescribeFacility returns the facility designated by the given ID or name
+// along with the Internet exchanges and networks present in it.
+func (s *Store) DescribeFacility(idOrName string) (*FacilityDetails, error) {
+	id, err := resolveID(s.db, "peeringdb_facility", idOrName)
+	if err != nil {
+		return nil, err
+	}
+	facility, err := s.FacilityByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FacilityDetails{
+		ID: facility.ID, Name: facility.Name, Status: facility.Status, Address1: facility.Address1,
+		Address2: facility.Address2, City: facility.City, State: facility.State, Zipcode: facility.Zipcode,
+		Country: facility.Country, Website: facility.Website, OrgID: facility.OrganizationID,
+		Organization: facility.OrganizationName, Exchanges: []ExchangeSummary{}, Networks: []NetworkSummary{},
+	}
+	// Unknown coordinates are read as zeros
+	if facility.Latitude != 0 || facility.Longitude != 0 {
+		f.Latitude, f.Longitude = &facility.Latitude, &facility.Longitude
+	}
+
+	err = queryAll(s.db, func(rows *sql.Rows) error {
+		var e ExchangeSummary
+		if err := rows.Scan(&e.ID, &e.Name, &e.City, &e.Country); err != nil {
+			return err
+		}
+		f.Exchanges = append(f.Exchanges, e)
+		return nil
+	}, `SELECT ix.id, ix.name, ix.city, ix.country
+		FROM peeringdb_ix_facility ixfac
+		JOIN peeringdb_ix ix ON ix.id = ixfac.ix_id
+		WHERE ixfac.fac_id = ? AND ixfac.status = 'ok'
+		ORDER BY ix.name`, f.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	networks, err := s.NetworksAtFacility(f.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range networks {
+		f.Networks = append(f.Networks, NetworkSummary{ID: n.ID, ASN: n.ASN, Name: n.Name})
+	}
+
+	return f, nil
+}
+
+// DescribeOrganization returns the organization with the given ID along with
+// the networks, Internet exchanges and facilities it owns.
+func (s *Store) DescribeOrganization(id int) (*OrganizationDetails, error) {
+	o := &OrganizationDetails{Networks: []NetworkSummary{}, Exchanges: []ExchangeSummary{}, Facilities: []FacilitySummary{}}
+	err := s.db.QueryRow(`SELECT id, name, aka, status, website, city, country
+		FROM peeringdb_organization WHERE id = ?`, id).Scan(&o.ID, &o.Name, &o.AKA, &o.Status, &o.Website,
+		&o.City, &o.Country)
+	if err != nil {
+		return nil, scanOne(err)
+	}
+
+	err = queryAll(s.db, func(rows *sql.Rows) error {
+		var n NetworkSummary
+		if err := rows.Scan(&n.ID, &n.ASN, &n.Name); err != nil {
+			return err
+		}
+		o.Networks = append(o.Networks, n)
+		return nil
+	}, `SELECT id, asn, name FROM peeringdb_network WHERE org_id = ? AND status = 'ok' ORDER BY asn`, o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryAll(s.db, func(rows *sql.Rows) error {
+		var e ExchangeSummary
+		if err := rows.Scan(&e.ID, &e.Name, &e.City, &e.Country); err != nil {
+			return err
+		}
+		o.Exchanges = append(o.Exchanges, e)
+		return nil
+	}, `SELECT id, name, city, country FROM peeringdb_ix WHERE org_id = ? AND status = 'ok' ORDER BY name`, o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryAll(s.db, func(rows *sql.Rows) error {
+		var f FacilitySummary
+		if err := rows.Scan(&f.ID, &f.Name, &f.City, &f.Country); err != nil {
+			return err
+		}
+		o.Facilities = append(o.Facilities, f)
+		return nil
+	}, `SELECT id, name, city, country FROM peeringdb_facility WHERE org_id = ? AND status = 'ok' ORDER BY name`, o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDescribeNetwork(t *testing.T) {
+	store := openFixture(t)
+
+	n, err := store.DescribeNetwork(64500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.ID != 10 || n.Name != "Example Network" || n.Organization != "Example Org" || n.IRRASSet != "AS-EXAMPLE" || n.PolicyGeneral != "Open" {
+		t.Errorf("unexpected network: %+v", n)
+	}
+
+	// The connection to Unused-IX has been deleted
+	var exchanges []int
+	for _, e := range n.Exchanges {
+		exchanges = append(exchanges, e.IXID)
+	}
+	if want := []int{20, 20, 21}; !slices.Equal(exchanges, want) {
+		t.Errorf("got exchanges %v, want %v", exchanges, want)
+	}
+	if e := n.Exchanges[0]; e.IPAddr4 != "192.0.2.10" || e.IPAddr6 != "2001:db8::10" || !e.IsRSPeer || e.IXLANID != 30 {
+		t.Errorf("unexpected connection: %+v", e)
+	}
+	if e := n.Exchanges[2]; e.IPAddr6 != "" || e.Speed != 1000 {
+		t.Errorf("unexpected connection: %+v", e)
+	}
+	if len(n.Facilities) != 1 || n.Facilities[0].FacID != 50 || n.Facilities[0].LocalASN != 64500 {
+		t.Errorf("unexpected facilities: %+v", n.Facilities)
+	}
+
+	if n, err = store.DescribeNetwork(64502); err != nil || len(n.Exchanges) != 0 || len(n.Facilities) != 0 {
+		t.Errorf("got %+v (%v), want a network present nowhere", n, err)
+	}
+	if _, err = store.DescribeNetwork(64511); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestDescribeExchange(t *testing.T) {
+	store := openFixture(t)
+
+	// A name being part of another one
+	if _, err := store.db.Exec("UPDATE peeringdb_ix SET name = 'Example' WHERE id = 22"); err != nil {
+		t.Fatal(err)
+	}
+
+	for value, want := range map[string]int{
+		"21":         21,
+		"Example-IX": 20,
+		"example-ix": 20,
+		"EXIX":       20,
+		// Names are matched exactly first, then partially
+		"example": 22,
+		"second":  21,
+	} {
+		ix, err := store.DescribeExchange(value)
+		if err != nil {
+			t.Errorf("%q: %v", value, err)
+			continue
+		}
+		if ix.ID != want {
+			t.Errorf("%q: got exchange %d, want %d", value, ix.ID, want)
+		}
+	}
+
+	// Wildcards are matched literally
+	if _, err := store.db.Exec(`UPDATE peeringdb_ix SET name = 'Second_IX', aka = '100% Peering' WHERE id = 21`); err != nil {
+		t.Fatal(err)
+	}
+	for value, want := range map[string]int{
+		"d_I":   21,
+		"0% p":  21,
+		"_":     21,
+		"Exa%e": 0,
+		`\`:     0,
+	} {
+		id, err := resolveID(store.db, "peeringdb_ix", value)
+		if want == 0 {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("%q: got %d (%v), want %v", value, id, err, ErrNotFound)
+			}
+			continue
+		}
+		if err != nil || id != want {
+			t.Errorf("%q: got %d (%v), want %d", value, id, err, want)
+		}
+	}
+
+	var ambiguous *AmbiguousError
+	if _, err := store.DescribeExchange("exam"); !errors.As(err, &ambiguous) || !slices.Equal(ambiguous.Matches, []string{"Example (22)", "Example-IX (20)"}) {
+		t.Errorf("got error %v, want an ambiguous match", err)
+	}
+	if _, err := store.DescribeExchange("Missing-IX"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+	if _, err := store.DescribeExchange("99"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+
+	ix, err := store.DescribeExchange("20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.NameLong != "Example Internet Exchange" || ix.City != "Frankfurt" || ix.Organization != "Example Org" || len(ix.Prefixes) != 0 {
+		t.Errorf("unexpected exchange: %+v", ix)
+	}
+	var facilities, members []int
+	for _, f := range ix.Facilities {
+		facilities = append(facilities, f.ID)
+	}
+	for _, m := range ix.Members {
+		members = append(members, m.ASN)
+	}
+	if want := []int{50, 51}; !slices.Equal(facilities, want) {
+		t.Errorf("got facilities %v, want %v", facilities, want)
+	}
+	if want := []int{64500, 64500, 64501}; !slices.Equal(members, want) {
+		t.Errorf("got members %v, want %v", members, want)
+	}
+}
+
+func TestDescribeFacility(t *testing.T) {
+	store := openFixture(t)
+
+	f, err := store.DescribeFacility("frankfurt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ID != 50 || f.Organization != "Example Org" || f.Zipcode != "60314" || f.Latitude == nil || *f.Latitude != 50.1 || *f.Longitude != 8.7 {
+		t.Errorf("unexpected facility: %+v", f)
+	}
+	if len(f.Exchanges) != 1 || f.Exchanges[0].ID != 20 {
+		t.Errorf("unexpected exchanges: %+v", f.Exchanges)
+	}
+	var networks []int
+	for _, n := range f.Networks {
+		networks = append(networks, n.ASN)
+	}
+	if want := []int{64500, 64501}; !slices.Equal(networks, want) {
+		t.Errorf("got networks %v, want %v", networks, want)
+	}
+
+	if f, err = store.DescribeFacility("51"); err != nil || f.Latitude != nil || f.Longitude != nil {
+		t.Errorf("got %+v (%v), want a facility without coordinates", f, err)
+	}
+
+	var ambiguous *AmbiguousError
+	if _, err = store.DescribeFacility("Example DC"); !errors.As(err, &ambiguous) || len(ambiguous.Matches) != 3 {
+		t.Errorf("got error %v, want an ambiguous match", err)
+	}
+}
+
+func TestDescribeOrganization(t *testing.T) {
+	store := openFixture(t)
+
+	o, err := store.DescribeOrganization(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Name != "Example Org" || o.AKA != "ExOrg" || o.City != "Frankfurt" {
+		t.Errorf("unexpected organization: %+v", o)
+	}
+	if len(o.Networks) != 3 || len(o.Exchanges) != 3 || len(o.Facilities) != 3 {
+		t.Errorf("got %d networks, %d exchanges and %d facilities, want 3 of each", len(o.Networks), len(o.Exchanges), len(o.Facilities))
+	}
+
+	if _, err = store.DescribeOrganization(2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestGetExchangeName(t *testing.T) {
+	store := openFixture(t)
+
+	if name, err := GetExchangeName(store.db, 21); err != nil || name != "Second-IX" {
+		t.Errorf("got %q (%v), want Second-IX", name, err)
+	}
+	if _, err := GetExchangeName(store.db, 99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+}
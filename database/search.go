@@ -0,0 +1,270 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// searchTable is the name of the FTS5 table indexing the searchable objects.
+const searchTable = "peeringdb_search"
+
+// ErrSearchUnavailable is returned when SQLite has been built without FTS5.
+var ErrSearchUnavailable = errors.New("full-text search is not available, peeringdb-sync must be built with -tags sqlite_fts5")
+
+// searchSource describes how the rows of a table are indexed for full-text
+// search. Columns not available in the table are replaced by empty strings.
+type searchSource struct {
+	Type    string            // Object type reported in results
+	Table   string            // Table holding the objects
+	Key     int               // Distinguishes rows of different tables in the index
+	Columns map[string]string // Table column for each indexed column
+}
+
+// searchColumns lists the indexed columns, by order of relevance.
+var searchColumns = []string{"name", "aka", "name_long", "notes", "city", "website"}
+
+// searchWeights are the bm25 weights of the columns of the search table, the
+// type and object_id columns are not indexed.
+var searchWeights = []string{"0", "0", "10.0", "5.0", "5.0", "1.0", "2.0", "1.0"}
+
+var searchSources = []searchSource{
+	{Type: "org", Table: "peeringdb_organization", Key: 1, Columns: map[string]string{"name": "name", "aka": "aka", "name_long": "name_long", "notes": "notes", "city": "city", "website": "website"}},
+	{Type: "net", Table: "peeringdb_network", Key: 2, Columns: map[string]string{"name": "name", "aka": "aka", "name_long": "name_long", "notes": "notes", "website": "website"}},
+	{Type: "fac", Table: "peeringdb_facility", Key: 3, Columns: map[string]string{"name": "name", "aka": "aka", "name_long": "name_long", "notes": "notes", "city": "city", "website": "website"}},
+	{Type: "ix", Table: "peeringdb_ix", Key: 4, Columns: map[string]string{"name": "name", "aka": "aka", "name_long": "name_long", "notes": "notes", "city": "city", "website": "website"}},
+	{Type: "campus", Table: "peeringdb_campus", Key: 5, Columns: map[string]string{"name": "name", "aka": "aka", "name_long": "name_long", "notes": "notes", "city": "city", "website": "website"}},
+	{Type: "carrier", Table: "peeringdb_carrier", Key: 6, Columns: map[string]string{"name": "name", "aka": "aka", "name_long": "name_long", "notes": "notes", "website": "website"}},
+}
+
+// SearchTypes lists the types of the objects that can be searched.
+func SearchTypes() []string {
+	types := make([]string, 0, len(searchSources))
+	for _, source := range searchSources {
+		types = append(types, source.Type)
+	}
+	return types
+}
+
+// rowid returns the expression giving the rowid in the index of the row
+// designated by the given reference (new, old or the table itself).
+func (s *searchSource) rowid(reference string) string {
+	return fmt.Sprintf("%s.id * 8 + %d", reference, s.Key)
+}
+
+// values returns the expressions giving the values to index for the row
+// designated by the given reference.
+func (s *searchSource) values(reference string) string {
+	values := []string{s.rowid(reference), "'" + s.Type + "'", reference + ".id"}
+	for _, column := range searchColumns {
+		if name, ok := s.Columns[column]; ok {
+			values = append(values, fmt.Sprintf("COALESCE(%s.%s, '')", reference, name))
+		} else {
+			values = append(values, "''")
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// insert returns the statement indexing the row designated by the reference.
+func (s *searchSource) insert(reference string) string {
+	return fmt.Sprintf("INSERT INTO %s (rowid, type, object_id, %s) SELECT %s",
+		searchTable, strings.Join(searchColumns, ", "), s.values(reference))
+}
+
+// triggers returns the statements creating the triggers keeping the index in
+// sync with the table.
+func (s *searchSource) triggers() []string {
+	remove := fmt.Sprintf("DELETE FROM %s WHERE rowid = %s;", searchTable, s.rowid("old"))
+	return []string{
+		fmt.Sprintf("CREATE TRIGGER %s_search_insert AFTER INSERT ON %s BEGIN %s; END;", s.Table, s.Table, s.insert("new")),
+		fmt.Sprintf("CREATE TRIGGER %s_search_update AFTER UPDATE ON %s BEGIN %s %s; END;", s.Table, s.Table, remove, s.insert("new")),
+		fmt.Sprintf("CREATE TRIGGER %s_search_delete AFTER DELETE ON %s BEGIN %s END;", s.Table, s.Table, remove),
+	}
+}
+
+// dropTriggers returns the statements removing the triggers of the table.
+func (s *searchSource) dropTriggers() []string {
+	var statements []string
+	for _, operation := range []string{"insert", "update", "delete"} {
+		statements = append(statements, fmt.Sprintf("DROP TRIGGER IF EXISTS %s_search_%s;", s.Table, operation))
+	}
+	return statements
+}
+
+// SearchAvailable returns true if SQLite has been built with FTS5.
+func SearchAvailable(db *sql.DB) (bool, error) {
+	var available bool
+	err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&available)
+	return available, err
+}
+
+// objectExists returns true if the database has a schema object of the given
+// type and name.
+func objectExists(tx *sql.Tx, kind, name string) (bool, error) {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = ? AND name = ?", kind, name).Scan(&count)
+	return count > 0, err
+}
+
+// searchIndexState tells whether some of the search triggers exist and
+// whether the index is complete, that is the search table and all the
+// triggers exist.
+func searchIndexState(tx *sql.Tx) (found, complete bool, err error) {
+	complete, err = objectExists(tx, "table", searchTable)
+	if err != nil {
+		return false, false, err
+	}
+
+	for _, source := range searchSources {
+		for _, operation := range []string{"insert", "update", "delete"} {
+			exists, err := objectExists(tx, "trigger", source.Table+"_search_"+operation)
+			if err != nil {
+				return false, false, err
+			}
+			found = found || exists
+			complete = complete && exists
+		}
+	}
+
+	return found, complete, nil
+}
+
+// CreateSearchIndex creates the full-text search table and the triggers
+// maintaining it, then indexes the existing rows. It does nothing if the
+// table and all the triggers already exist, so that it can be called before
+// every synchronization without indexing everything again.
+//
+// If SQLite has been built without FTS5, the triggers are removed instead as
+// they would make every write fail; the index is rebuilt the next time this
+// function is called with FTS5 available.
+func CreateSearchIndex(db *sql.DB) error {
+	available, err := SearchAvailable(db)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	maintained, complete, err := searchIndexState(tx)
+	if err != nil {
+		return err
+	}
+
+	if !available {
+		if !maintained {
+			return nil
+		}
+
+		slog.Warn("SQLite built without FTS5, the search index will not be maintained anymore")
+		for _, source := range searchSources {
+			for _, statement := range source.dropTriggers() {
+				if _, err = tx.Exec(statement); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Commit()
+	}
+
+	if complete {
+		return nil
+	}
+
+	slog.Info("building the search index")
+	statements := []string{
+		fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(type UNINDEXED, object_id UNINDEXED, %s, tokenize = 'unicode61 remove_diacritics 2', prefix = '2 3');",
+			searchTable, strings.Join(searchColumns, ", ")),
+		fmt.Sprintf("DELETE FROM %s;", searchTable),
+	}
+	for _, source := range searchSources {
+		statements = append(statements, source.dropTriggers()...)
+		statements = append(statements, source.insert(source.Table)+" FROM "+source.Table+";")
+		statements = append(statements, source.triggers()...)
+	}
+
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SearchResult is an object matching a full-text search.
+type SearchResult struct {
+	Type    string  `json:"type" yaml:"type"`
+	ID      int     `json:"id" yaml:"id"`
+	Name    string  `json:"name" yaml:"name"`
+	ASN     int     `json:"asn,omitempty" yaml:"asn,omitempty"`
+	City    string  `json:"city,omitempty" yaml:"city,omitempty"`
+	Snippet string  `json:"snippet" yaml:"snippet"`
+	Score   float64 `json:"score" yaml:"score"`
+}
+
+// searchQuery turns the terms into an FTS5 query matching the objects having
+// all the terms, each term being also matched as a prefix.
+func searchQuery(terms []string) string {
+	var query []string
+	for _, term := range terms {
+		for _, word := range strings.Fields(term) {
+			query = append(query, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+		}
+	}
+	return strings.Join(query, " ")
+}
+
+// Search returns the objects matching all the given terms, the best matches
+// first. Results can be restricted to some types of objects, see SearchTypes.
+func Search(db *sql.DB, terms []string, types []string, limit int) ([]SearchResult, error) {
+	available, err := SearchAvailable(db)
+	if err != nil {
+		return nil, err
+	}
+	if !available {
+		return nil, ErrSearchUnavailable
+	}
+
+	query := searchQuery(terms)
+	if query == "" {
+		return nil, errors.New("no search terms given")
+	}
+
+	conditions := []string{searchTable + " MATCH ?"}
+	args := []interface{}{query}
+	if len(types) > 0 {
+		conditions = append(conditions, searchTable+".type IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(types)), ", ")+")")
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+	args = append(args, limit)
+
+	results := []SearchResult{}
+	err = queryAll(db, func(rows *sql.Rows) error {
+		var r SearchResult
+		if err := rows.Scan(&r.Type, &r.ID, &r.Name, &r.ASN, &r.City, &r.Snippet, &r.Score); err != nil {
+			return err
+		}
+		results = append(results, r)
+		return nil
+	}, fmt.Sprintf(`SELECT %[1]s.type, %[1]s.object_id, %[1]s.name, COALESCE(net.asn, 0), %[1]s.city,
+		snippet(%[1]s, -1, '[', ']', '...', 8), -bm25(%[1]s, %[2]s)
+		FROM %[1]s
+		LEFT JOIN peeringdb_network net ON %[1]s.type = 'net' AND net.id = %[1]s.object_id
+		WHERE %[3]s
+		ORDER BY bm25(%[1]s, %[2]s)
+		LIMIT ?`, searchTable, strings.Join(searchWeights, ", "), strings.Join(conditions, " AND ")), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
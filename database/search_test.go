@@ -0,0 +1,150 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+)
+
+// openSearchFixture returns the fixture database with the search index. The
+// test is skipped if SQLite has been built without FTS5, unless the
+// PEERINGDB_SYNC_REQUIRE_FTS5 environment variable is set as done by make
+// test, it then fails.
+func openSearchFixture(t *testing.T) *Store {
+	t.Helper()
+
+	store := openFixture(t)
+	if available, err := SearchAvailable(store.db); err != nil {
+		t.Fatal(err)
+	} else if !available {
+		if os.Getenv("PEERINGDB_SYNC_REQUIRE_FTS5") != "" {
+			t.Fatal("SQLite built without FTS5 although required, run the tests with -tags sqlite_fts5")
+		}
+		t.Skip("SQLite built without FTS5, run the tests with -tags sqlite_fts5")
+	}
+	if err := CreateSearchIndex(store.db); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+// searchIDs returns the type and ID of the results of the search.
+func searchIDs(t *testing.T, store *Store, term string, types ...string) []string {
+	t.Helper()
+
+	results, err := Search(store.db, []string{term}, types, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, r := range results {
+		ids = append(ids, fmt.Sprintf("%s %d", r.Type, r.ID))
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	store := openSearchFixture(t)
+
+	// Names weigh more than notes
+	if _, err := store.db.Exec("UPDATE peeringdb_network SET notes = 'Formerly Other Network' WHERE id = 12"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		term  string
+		types []string
+		want  []string
+	}{
+		{"other", []string{"net"}, []string{"net 11", "net 12"}},
+		{"example network", []string{"net"}, []string{"net 10"}},
+		{"exam", []string{"ix"}, []string{"ix 20"}},
+		{"EXIX", nil, []string{"ix 20"}},
+		{"frankfurt", []string{"fac", "ix"}, []string{"fac 50", "ix 20"}},
+		{"frankfurt", nil, []string{"fac 50", "ix 20", "org 1"}},
+		{"frankfurt", []string{"net", "campus"}, []string{}},
+		{"nothing", nil, []string{}},
+	} {
+		ids := searchIDs(t, store, tc.term, tc.types...)
+		if tc.term == "frankfurt" {
+			// The objects only match on their city
+			slices.Sort(ids)
+		}
+		if !slices.Equal(ids, tc.want) {
+			t.Errorf("%s %v: got %v, want %v", tc.term, tc.types, ids, tc.want)
+		}
+	}
+
+	results, err := Search(store.db, []string{"other"}, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].ASN != 64501 || results[0].Name != "Other Network" || results[0].Snippet != "[Other] Network" {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	if _, err = Search(store.db, []string{" "}, nil, 10); err == nil {
+		t.Error("empty search accepted")
+	}
+}
+
+func TestSearchTriggers(t *testing.T) {
+	store := openSearchFixture(t)
+
+	if _, err := store.db.Exec(`CREATE TEMPORARY TABLE ix AS SELECT * FROM peeringdb_ix WHERE id = 22;
+		UPDATE ix SET id = 23, name = 'Inserted-IX', city = 'Lyon';
+		INSERT INTO peeringdb_ix SELECT * FROM ix;`); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, store, "lyon"); !slices.Equal(ids, []string{"ix 23"}) {
+		t.Errorf("after insert: got %v", ids)
+	}
+
+	if _, err := store.db.Exec("UPDATE peeringdb_ix SET city = 'Marseille' WHERE id = 23"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, store, "lyon"); len(ids) != 0 {
+		t.Errorf("after update: got %v for the old value", ids)
+	}
+	if ids := searchIDs(t, store, "marseille"); !slices.Equal(ids, []string{"ix 23"}) {
+		t.Errorf("after update: got %v", ids)
+	}
+
+	if _, err := store.db.Exec("DELETE FROM peeringdb_ix WHERE id = 23"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, store, "marseille"); len(ids) != 0 {
+		t.Errorf("after delete: got %v", ids)
+	}
+}
+
+func TestCreateSearchIndex(t *testing.T) {
+	store := openSearchFixture(t)
+
+	// A row only known to the index is kept as long as it is not rebuilt
+	if _, err := store.db.Exec("INSERT INTO peeringdb_search VALUES ('net', 1000, 'Orphan', '', '', '', '', '')"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(store.db, GetSchema()); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, store, "orphan"); !slices.Equal(ids, []string{"net 1000"}) {
+		t.Errorf("index rebuilt by the migration: got %v", ids)
+	}
+
+	// A missing trigger makes the index be rebuilt
+	if _, err := store.db.Exec("DROP TRIGGER peeringdb_carrier_search_delete"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(store.db, GetSchema()); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, store, "orphan"); len(ids) != 0 {
+		t.Errorf("index not rebuilt: got %v", ids)
+	}
+	if ids := searchIDs(t, store, "example network", "net"); !slices.Equal(ids, []string{"net 10"}) {
+		t.Errorf("got %v after the rebuild", ids)
+	}
+}
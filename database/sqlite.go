@@ -241,6 +241,10 @@ func GetSchema() *Schema {
 					{Name: "prefix", Type: "varchar(43)", Constraints: "NOT NULL UNIQUE"},
 					{Name: "in_dfz", Type: "bool", Constraints: "NOT NULL"},
 					{Name: "ixlan_id", Type: "integer", Constraints: "NOT NULL REFERENCES peeringdb_ixlan (id)"},
+					{Name: "family", Type: "integer", Constraints: "NULL", Derived: true},
+					{Name: "prefix_start", Type: "blob", Constraints: "NULL", Derived: true},
+					{Name: "prefix_end", Type: "blob", Constraints: "NULL", Derived: true},
+					{Name: "prefix_length", Type: "integer", Constraints: "NULL", Derived: true},
 				},
 			},
 			"peeringdb_network_contact": {
@@ -296,6 +300,8 @@ func GetSchema() *Schema {
 					{Name: "ixlan_id", Type: "integer", Constraints: "NOT NULL REFERENCES peeringdb_ixlan (id)"},
 					{Name: "net_side", Type: "integer", Constraints: "NULL REFERENCES peeringdb_facility (id)"},
 					{Name: "ix_side", Type: "integer", Constraints: "NULL REFERENCES peeringdb_facility (id)"},
+					{Name: "ipaddr4_bin", Type: "blob", Constraints: "NULL", Derived: true},
+					{Name: "ipaddr6_bin", Type: "blob", Constraints: "NULL", Derived: true},
 				},
 			},
 		},
@@ -312,12 +318,69 @@ func GetSchema() *Schema {
 			"CREATE INDEX peeringdb_ix_facility_fac_id ON peeringdb_ix_facility (fac_id);",
 			"CREATE INDEX peeringdb_ixlan_ix_id ON peeringdb_ixlan (ix_id);",
 			"CREATE INDEX peeringdb_ix_prefix_ixlan_id ON peeringdb_ix_prefix (ixlan_id);",
+			"CREATE INDEX peeringdb_ix_prefix_range ON peeringdb_ix_prefix (prefix_start, prefix_end);",
 			"CREATE INDEX peeringdb_network_facility_net_id ON peeringdb_network_facility (net_id);",
 			"CREATE INDEX peeringdb_network_facility_fac_id ON peeringdb_network_facility (fac_id);",
 			"CREATE INDEX peeringdb_network_ixlan_ixlan_id ON peeringdb_network_ixlan (ixlan_id);",
 			"CREATE INDEX peeringdb_network_ixlan_net_id ON peeringdb_network_ixlan (net_id);",
 			"CREATE INDEX peeringdb_network_ixlan_ix_side ON peeringdb_network_ixlan (ix_side);",
 			"CREATE INDEX peeringdb_network_ixlan_net_side ON peeringdb_network_ixlan (net_side);",
+			"CREATE INDEX peeringdb_network_ixlan_ipaddr4_bin ON peeringdb_network_ixlan (ipaddr4_bin);",
+			"CREATE INDEX peeringdb_network_ixlan_ipaddr6_bin ON peeringdb_network_ixlan (ipaddr6_bin);",
+		},
+		SpatialIndexes: []SpatialIndex{
+			{Table: "peeringdb_facility"},
+			{Table: "peeringdb_organization"},
+		},
+		Views: []View{
+			{
+				Name: "v_network_ix_presence",
+				Query: `SELECT nixl.id, nixl.net_id, nixl.asn, net.name AS net_name, nixl.ix_id, ix.name AS ix_name,
+  ix.city AS ix_city, ix.country AS ix_country, nixl.ixlan_id, nixl.speed, nixl.ipaddr4, nixl.ipaddr6,
+  nixl.is_rs_peer, nixl.bfd_support, nixl.operational
+FROM peeringdb_network_ixlan nixl
+JOIN peeringdb_network net ON net.id = nixl.net_id
+JOIN peeringdb_ix ix ON ix.id = nixl.ix_id
+WHERE nixl.status = 'ok'`,
+			},
+			{
+				Name: "v_network_facility_presence",
+				Query: `SELECT netfac.id, netfac.net_id, net.asn, net.name AS net_name, netfac.local_asn, netfac.fac_id,
+  fac.name AS fac_name, fac.city AS fac_city, fac.state AS fac_state, fac.country AS fac_country,
+  fac.latitude, fac.longitude
+FROM peeringdb_network_facility netfac
+JOIN peeringdb_network net ON net.id = netfac.net_id
+JOIN peeringdb_facility fac ON fac.id = netfac.fac_id
+WHERE netfac.status = 'ok'`,
+			},
+			{
+				Name: "v_ix_summary",
+				Query: `SELECT ix.id, ix.name, ix.name_long, ix.city, ix.country, ix.region_continent, ix.org_id,
+  org.name AS org_name, ix.website,
+  (SELECT COUNT(DISTINCT asn) FROM peeringdb_network_ixlan WHERE ix_id = ix.id AND status = 'ok') AS member_count,
+  (SELECT COUNT(*) FROM peeringdb_network_ixlan WHERE ix_id = ix.id AND status = 'ok') AS connection_count,
+  (SELECT COALESCE(SUM(speed), 0) FROM peeringdb_network_ixlan WHERE ix_id = ix.id AND status = 'ok') AS total_speed,
+  (SELECT COUNT(DISTINCT asn) FROM peeringdb_network_ixlan WHERE ix_id = ix.id AND status = 'ok' AND is_rs_peer) AS rs_peer_count,
+  (SELECT COUNT(*) FROM peeringdb_ix_facility WHERE ix_id = ix.id AND status = 'ok') AS facility_count,
+  (SELECT GROUP_CONCAT(pfx.prefix, ' ') FROM peeringdb_ix_prefix pfx JOIN peeringdb_ixlan ixlan ON ixlan.id = pfx.ixlan_id
+    WHERE ixlan.ix_id = ix.id AND pfx.status = 'ok' AND pfx.protocol = 'IPv4') AS ipv4_prefixes,
+  (SELECT GROUP_CONCAT(pfx.prefix, ' ') FROM peeringdb_ix_prefix pfx JOIN peeringdb_ixlan ixlan ON ixlan.id = pfx.ixlan_id
+    WHERE ixlan.ix_id = ix.id AND pfx.status = 'ok' AND pfx.protocol = 'IPv6') AS ipv6_prefixes
+FROM peeringdb_ix ix
+LEFT JOIN peeringdb_organization org ON org.id = ix.org_id
+WHERE ix.status = 'ok'`,
+			},
+			{
+				Name: "v_facility_summary",
+				Query: `SELECT fac.id, fac.name, fac.address1, fac.city, fac.state, fac.zipcode, fac.country,
+  fac.latitude, fac.longitude, fac.org_id, org.name AS org_name, fac.campus_id, fac.website,
+  (SELECT COUNT(DISTINCT net_id) FROM peeringdb_network_facility WHERE fac_id = fac.id AND status = 'ok') AS network_count,
+  (SELECT COUNT(*) FROM peeringdb_ix_facility WHERE fac_id = fac.id AND status = 'ok') AS ix_count,
+  (SELECT COUNT(*) FROM peeringdb_carrier_facility WHERE fac_id = fac.id) AS carrier_count
+FROM peeringdb_facility fac
+LEFT JOIN peeringdb_organization org ON org.id = fac.org_id
+WHERE fac.status = 'ok'`,
+			},
 		},
 	}
 }
@@ -365,6 +428,9 @@ func CreateDatabaseSchema(db *sql.DB, schema *Schema) (*sql.Result, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err = createStateTables(db); err != nil {
+		return nil, err
+	}
 	return &result, nil
 }
 
@@ -383,5 +449,19 @@ func ClearDatabase(db *sql.DB, schema *Schema) error {
 			return err
 		}
 	}
-	return nil
+
+	// Forget about interrupted synchronizations and changes to rows which
+	// are gone, sequence numbers keep growing
+	if err := createStateTables(db); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM " + checkpointTable + "; DELETE FROM " + captureTable)
+	return err
+}
+
+// CountRows returns the number of rows stored in the given table.
+func CountRows(db *sql.DB, table string) (int64, error) {
+	var count int64
+	err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count)
+	return count, err
 }
@@ -0,0 +1,243 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/gmazoyer/peeringdb"
+)
+
+// Store gives a typed read access to a synchronized database, returning the
+// objects as the structures of the PeeringDB API package. Only the fields
+// stored in the database are set, the sets of related IDs and the counters
+// computed by PeeringDB are left empty. Its Describe methods gather an object
+// with the ones related to it for display.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore returns a store reading from the given database connection.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// OpenStore opens the SQLite database file and returns a store reading from
+// it. The store must be closed once done.
+func OpenStore(filename string) (*Store, error) {
+	db, err := GetDatabaseConnection(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(db), nil
+}
+
+// Close closes the database connection used by the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// rowScanner is implemented by both sql.Row and sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// unmarshalJSON decodes a value stored as JSON text, empty or NULL values
+// are left as is.
+func unmarshalJSON(value sql.NullString, v interface{}) error {
+	if !value.Valid || value.String == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(value.String), v)
+}
+
+// networkColumns are the columns read to hydrate a network, the table must be
+// aliased as net.
+const networkColumns = `net.id, net.org_id, net.name, net.aka, net.name_long, net.website, net.social_media, net.asn,
+	net.looking_glass, net.route_server, net.irr_as_set, net.info_type, net.info_types,
+	COALESCE(net.info_prefixes4, 0), COALESCE(net.info_prefixes6, 0), net.info_traffic, net.info_ratio,
+	net.info_scope, net.info_unicast, net.info_multicast, net.info_ipv6, net.info_never_via_route_servers,
+	net.notes, net.policy_url, net.policy_general, net.policy_locations, net.policy_ratio,
+	net.policy_contracts, net.allow_ixp_update, net.status_dashboard, COALESCE(net.rir_status, ''),
+	net.rir_status_updated, net.created, net.updated, net.status`
+
+func scanNetwork(row rowScanner) (*peeringdb.Network, error) {
+	var n peeringdb.Network
+	var socialMedia, infoTypes sql.NullString
+	var rirStatusUpdated sql.NullTime
+	err := row.Scan(&n.ID, &n.OrganizationID, &n.Name, &n.AKA, &n.NameLong, &n.Website, &socialMedia, &n.ASN,
+		&n.LookingGlass, &n.RouteServer, &n.IRRASSet, &n.InfoType, &infoTypes, &n.InfoPrefixes4,
+		&n.InfoPrefixes6, &n.InfoTraffic, &n.InfoRatio, &n.InfoScope, &n.InfoUnicast, &n.InfoMulticast,
+		&n.InfoIPv6, &n.InfoNeverViaRouteServers, &n.Notes, &n.PolicyURL, &n.PolicyGeneral,
+		&n.PolicyLocations, &n.PolicyRatio, &n.PolicyContracts, &n.AllowIXPUpdate, &n.StatusDashboard,
+		&n.RIRStatus, &rirStatusUpdated, &n.Created, &n.Updated, &n.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	n.RIRStatusUpdated = rirStatusUpdated.Time
+	if err = unmarshalJSON(socialMedia, &n.SocialMedia); err != nil {
+		return nil, err
+	}
+	if err = unmarshalJSON(infoTypes, &n.InfoTypes); err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+// exchangeColumns are the columns read to hydrate an Internet exchange, the
+// table must be aliased as ix.
+const exchangeColumns = `ix.id, ix.org_id, ix.name, ix.aka, ix.name_long, ix.city, ix.country, ix.region_continent,
+	ix.media, COALESCE(ix.notes, ''), ix.proto_unicast, ix.proto_multicast, ix.proto_ipv6, ix.website,
+	ix.social_media, ix.url_stats, ix.tech_email, ix.tech_phone, ix.policy_email, ix.policy_phone,
+	ix.sales_phone, ix.sales_email, ix.ixf_net_count, ix.ixf_last_import, ix.ixf_import_request,
+	COALESCE(ix.ixf_import_request_status, ''), ix.service_level, ix.terms, COALESCE(ix.status_dashboard, ''),
+	ix.created, ix.updated, ix.status`
+
+func scanExchange(row rowScanner) (*peeringdb.InternetExchange, error) {
+	var ix peeringdb.InternetExchange
+	var socialMedia sql.NullString
+	var lastImport, importRequest sql.NullTime
+	err := row.Scan(&ix.ID, &ix.OrganizationID, &ix.Name, &ix.AKA, &ix.NameLong, &ix.City, &ix.Country,
+		&ix.RegionContinent, &ix.Media, &ix.Notes, &ix.ProtoUnicast, &ix.ProtoMulticast, &ix.ProtoIPv6,
+		&ix.Website, &socialMedia, &ix.URLStats, &ix.TechEmail, &ix.TechPhone, &ix.PolicyEmail,
+		&ix.PolicyPhone, &ix.SalesPhone, &ix.SalesEmail, &ix.IxfNetCount, &lastImport, &importRequest,
+		&ix.IxfImportRequestStatus, &ix.ServiceLevel, &ix.Terms, &ix.StatusDashboard, &ix.Created,
+		&ix.Updated, &ix.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	ix.IxfLastImport = lastImport.Time
+	ix.IxfImportRequest = importRequest.Time
+	if err = unmarshalJSON(socialMedia, &ix.SocialMedia); err != nil {
+		return nil, err
+	}
+
+	return &ix, nil
+}
+
+// facilityColumns are the columns read to hydrate a facility, the table must
+// be aliased as fac.
+const facilityColumns = `fac.id, fac.org_id, COALESCE(org.name, ''), COALESCE(fac.campus_id, 0), fac.name, fac.aka,
+	fac.name_long, fac.website, fac.clli, fac.rencode, fac.npanxx, fac.notes, fac.sales_email, fac.sales_phone,
+	fac.tech_email, fac.tech_phone, fac.available_voltage_services,
+	COALESCE(fac.diverse_serving_substations, 0), COALESCE(fac.property, ''),
+	COALESCE(fac.region_continent, ''), COALESCE(fac.status_dashboard, ''), fac.created, fac.updated,
+	fac.status, fac.address1, fac.address2, fac.city, fac.country, fac.state, fac.zipcode, fac.floor,
+	fac.suite, COALESCE(fac.latitude, 0), COALESCE(fac.longitude, 0), fac.social_media`
+
+// facilityFrom is the FROM clause to use with facilityColumns.
+const facilityFrom = `peeringdb_facility fac LEFT JOIN peeringdb_organization org ON org.id = fac.org_id`
+
+func scanFacility(row rowScanner) (*peeringdb.Facility, error) {
+	var f peeringdb.Facility
+	var voltageServices, socialMedia sql.NullString
+	err := row.Scan(&f.ID, &f.OrganizationID, &f.OrganizationName, &f.CampusID, &f.Name, &f.AKA, &f.NameLong,
+		&f.Website, &f.CLLI, &f.Rencode, &f.Npanxx, &f.Notes, &f.SalesEmail, &f.SalesPhone, &f.TechEmail,
+		&f.TechPhone, &voltageServices, &f.DiverseServingSubstations, &f.Property, &f.RegionContinent,
+		&f.StatusDashboard, &f.Created, &f.Updated, &f.Status, &f.Address1, &f.Address2, &f.City, &f.Country,
+		&f.State, &f.Zipcode, &f.Floor, &f.Suite, &f.Latitude, &f.Longitude, &socialMedia)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = unmarshalJSON(voltageServices, &f.AvailableVoltageServices); err != nil {
+		return nil, err
+	}
+	if err = unmarshalJSON(socialMedia, &f.SocialMedia); err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// NetworkByASN returns the network using the given ASN. It returns
+// ErrNotFound if there is none.
+func (s *Store) NetworkByASN(asn int) (*peeringdb.Network, error) {
+	n, err := scanNetwork(s.db.QueryRow("SELECT "+networkColumns+" FROM peeringdb_network net WHERE net.asn = ?", asn))
+	if err != nil {
+		return nil, scanOne(err)
+	}
+	return n, nil
+}
+
+// InternetExchangeByID returns the Internet exchange with the given ID. It
+// returns ErrNotFound if there is none.
+func (s *Store) InternetExchangeByID(id int) (*peeringdb.InternetExchange, error) {
+	ix, err := scanExchange(s.db.QueryRow("SELECT "+exchangeColumns+" FROM peeringdb_ix ix WHERE ix.id = ?", id))
+	if err != nil {
+		return nil, scanOne(err)
+	}
+	return ix, nil
+}
+
+// FacilityByID returns the facility with the given ID. It returns
+// ErrNotFound if there is none.
+func (s *Store) FacilityByID(id int) (*peeringdb.Facility, error) {
+	f, err := scanFacility(s.db.QueryRow("SELECT "+facilityColumns+" FROM "+facilityFrom+" WHERE fac.id = ?", id))
+	if err != nil {
+		return nil, scanOne(err)
+	}
+	return f, nil
+}
+
+// InternetExchangesForASN returns the Internet exchanges where the network
+// using the given ASN is connected, sorted by name.
+func (s *Store) InternetExchangesForASN(asn int) ([]peeringdb.InternetExchange, error) {
+	exchanges := []peeringdb.InternetExchange{}
+	err := queryAll(s.db, func(rows *sql.Rows) error {
+		ix, err := scanExchange(rows)
+		if err != nil {
+			return err
+		}
+		exchanges = append(exchanges, *ix)
+		return nil
+	}, `SELECT `+exchangeColumns+` FROM peeringdb_ix ix
+		WHERE ix.id IN (SELECT ix_id FROM peeringdb_network_ixlan WHERE asn = ? AND status = 'ok')
+		ORDER BY ix.name`, asn)
+	if err != nil {
+		return nil, err
+	}
+	return exchanges, nil
+}
+
+// FacilitiesForIX returns the facilities where the Internet exchange with the
+// given ID is present, sorted by country, city and name.
+func (s *Store) FacilitiesForIX(ixID int) ([]peeringdb.Facility, error) {
+	facilities := []peeringdb.Facility{}
+	err := queryAll(s.db, func(rows *sql.Rows) error {
+		f, err := scanFacility(rows)
+		if err != nil {
+			return err
+		}
+		facilities = append(facilities, *f)
+		return nil
+	}, `SELECT `+facilityColumns+` FROM `+facilityFrom+`
+		WHERE fac.id IN (SELECT fac_id FROM peeringdb_ix_facility WHERE ix_id = ? AND status = 'ok')
+		ORDER BY fac.country, fac.city, fac.name`, ixID)
+	if err != nil {
+		return nil, err
+	}
+	return facilities, nil
+}
+
+// NetworksAtFacility returns the networks present in the facility with the
+// given ID, sorted by ASN.
+func (s *Store) NetworksAtFacility(facID int) ([]peeringdb.Network, error) {
+	networks := []peeringdb.Network{}
+	err := queryAll(s.db, func(rows *sql.Rows) error {
+		n, err := scanNetwork(rows)
+		if err != nil {
+			return err
+		}
+		networks = append(networks, *n)
+		return nil
+	}, `SELECT `+networkColumns+` FROM peeringdb_network net
+		WHERE net.id IN (SELECT net_id FROM peeringdb_network_facility WHERE fac_id = ? AND status = 'ok')
+		ORDER BY net.asn`, facID)
+	if err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// openFixture creates a database with the schema and the data set of
+// testdata/fixture.sql.
+func openFixture(t *testing.T) *Store {
+	t.Helper()
+
+	fixture, err := os.ReadFile(filepath.Join("testdata", "fixture.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := CreateDatabase(filepath.Join(t.TempDir(), "peeringdb.db"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(db)
+	t.Cleanup(func() { store.Close() })
+
+	if _, err = CreateDatabaseSchema(db, GetSchema()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec(string(fixture)); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func TestNetworkByASN(t *testing.T) {
+	store := openFixture(t)
+
+	n, err := store.NetworkByASN(64500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.ID != 10 || n.OrganizationID != 1 || n.Name != "Example Network" || n.AKA != "ExNet" {
+		t.Errorf("unexpected identity: %d %d %q %q", n.ID, n.OrganizationID, n.Name, n.AKA)
+	}
+	if n.IRRASSet != "AS-EXAMPLE" || n.PolicyGeneral != "Open" || n.InfoPrefixes4 != 100 || n.InfoPrefixes6 != 50 {
+		t.Errorf("unexpected policy: %q %q %d %d", n.IRRASSet, n.PolicyGeneral, n.InfoPrefixes4, n.InfoPrefixes6)
+	}
+	if !n.InfoUnicast || n.InfoMulticast || !n.InfoIPv6 || n.InfoNeverViaRouteServers || !n.AllowIXPUpdate {
+		t.Errorf("unexpected booleans: %+v", n)
+	}
+	if !slices.Equal(n.InfoTypes, []string{"NSP", "Content"}) {
+		t.Errorf("unexpected info types: %v", n.InfoTypes)
+	}
+	if len(n.SocialMedia) != 1 || n.SocialMedia[0].Service != "website" {
+		t.Errorf("unexpected social media: %v", n.SocialMedia)
+	}
+	if want := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC); !n.Created.Equal(want) {
+		t.Errorf("created is %s, want %s", n.Created, want)
+	}
+	if want := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); !n.RIRStatusUpdated.Equal(want) {
+		t.Errorf("rir_status_updated is %s, want %s", n.RIRStatusUpdated, want)
+	}
+}
+
+func TestNetworkByASNNullColumns(t *testing.T) {
+	store := openFixture(t)
+
+	n, err := store.NetworkByASN(64501)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.InfoTypes != nil || n.SocialMedia != nil || n.InfoPrefixes4 != 0 || n.RIRStatus != "" {
+		t.Errorf("NULL columns are not zero values: %+v", n)
+	}
+	if !n.RIRStatusUpdated.IsZero() {
+		t.Errorf("rir_status_updated is %s, want zero time", n.RIRStatusUpdated)
+	}
+}
+
+func TestNetworkByASNNotFound(t *testing.T) {
+	store := openFixture(t)
+
+	if _, err := store.NetworkByASN(64511); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestInternetExchangesForASN(t *testing.T) {
+	store := openFixture(t)
+
+	tests := []struct {
+		asn  int
+		want []int
+	}{
+		// Two connections to Example-IX and a deleted one to Unused-IX
+		{asn: 64500, want: []int{20, 21}},
+		{asn: 64501, want: []int{20}},
+		{asn: 64502, want: []int{}},
+	}
+	for _, test := range tests {
+		exchanges, err := store.InternetExchangesForASN(test.asn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []int{}
+		for _, ix := range exchanges {
+			ids = append(ids, ix.ID)
+		}
+		if !slices.Equal(ids, test.want) {
+			t.Errorf("AS%d: got exchanges %v, want %v", test.asn, ids, test.want)
+		}
+	}
+
+	exchanges, err := store.InternetExchangesForASN(64500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix := exchanges[0]
+	if ix.Name != "Example-IX" || ix.City != "Frankfurt" || ix.Country != "DE" || ix.OrganizationID != 1 {
+		t.Errorf("unexpected exchange: %+v", ix)
+	}
+	if !ix.ProtoUnicast || ix.ProtoMulticast || !ix.ProtoIPv6 || ix.IxfNetCount != 2 || ix.IxfImportRequestStatus != "queued" {
+		t.Errorf("unexpected exchange details: %+v", ix)
+	}
+	if len(ix.SocialMedia) != 1 || ix.SocialMedia[0].Identifier != "example_ix" {
+		t.Errorf("unexpected social media: %v", ix.SocialMedia)
+	}
+	if want := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC); !ix.IxfLastImport.Equal(want) || !ix.IxfImportRequest.IsZero() {
+		t.Errorf("unexpected IX-F dates: %s %s", ix.IxfLastImport, ix.IxfImportRequest)
+	}
+}
+
+func TestFacilitiesForIX(t *testing.T) {
+	store := openFixture(t)
+
+	facilities, err := store.FacilitiesForIX(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Berlin is ignored as the IX left it, the others are sorted by country
+	ids := []int{}
+	for _, f := range facilities {
+		ids = append(ids, f.ID)
+	}
+	if want := []int{50, 51}; !slices.Equal(ids, want) {
+		t.Fatalf("got facilities %v, want %v", ids, want)
+	}
+
+	f := facilities[0]
+	if f.Name != "Example DC Frankfurt" || f.OrganizationName != "Example Org" || f.City != "Frankfurt" || f.Zipcode != "60314" {
+		t.Errorf("unexpected facility: %+v", f)
+	}
+	if f.Floor != "2" || f.Suite != "A" || f.Latitude != 50.1 || f.Longitude != 8.7 || f.CLLI != "FRNKGE" {
+		t.Errorf("unexpected facility location: %+v", f)
+	}
+	if !f.DiverseServingSubstations || f.Property != "Owner" || !slices.Equal(f.AvailableVoltageServices, []string{"230 V"}) {
+		t.Errorf("unexpected facility details: %+v", f)
+	}
+
+	if f = facilities[1]; f.CampusID != 0 || f.Latitude != 0 || f.DiverseServingSubstations || f.AvailableVoltageServices != nil {
+		t.Errorf("NULL columns are not zero values: %+v", f)
+	}
+
+	facilities, err = store.FacilitiesForIX(22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facilities) != 0 {
+		t.Errorf("got %d facilities, want none", len(facilities))
+	}
+}
+
+func TestNetworksAtFacility(t *testing.T) {
+	store := openFixture(t)
+
+	tests := []struct {
+		fac  int
+		want []int
+	}{
+		// Third Network has left the facility
+		{fac: 50, want: []int{64500, 64501}},
+		{fac: 51, want: []int{64501}},
+		{fac: 52, want: []int{}},
+	}
+	for _, test := range tests {
+		networks, err := store.NetworksAtFacility(test.fac)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		asns := []int{}
+		for _, n := range networks {
+			asns = append(asns, n.ASN)
+		}
+		if !slices.Equal(asns, test.want) {
+			t.Errorf("facility %d: got networks %v, want %v", test.fac, asns, test.want)
+		}
+	}
+}
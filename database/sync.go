@@ -1,15 +1,20 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gmazoyer/peeringdb"
-	"github.com/vbauerster/mpb/v8"
+	"github.com/gmazoyer/peeringdb-sync/metrics"
+	"github.com/gmazoyer/peeringdb-sync/progress"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func marshalJSON(v interface{}) string {
@@ -20,38 +25,89 @@ func marshalJSON(v interface{}) string {
 	return string(m)
 }
 
+// nullableID returns the value to store for an optional reference to another
+// object, the API package giving 0 when there is none.
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
 // Synchronization is a structure holding pointers to the PeeringDB API and
 // database being used.
 type Synchronization struct {
 	API *peeringdb.API
 	DB  *sql.DB
+
+	// PageSize is the number of objects requested at once, DefaultPageSize
+	// if not set.
+	PageSize int
+
+	// OnChange, if set, is called for every row written or deleted once the
+	// transaction holding the change has been committed. It can be called
+	// from several goroutines at the same time.
+	OnChange func(Change)
+
+	// OnChangeTables, if not empty, restricts OnChange to the changes made
+	// to these tables. Rows of the other tables are then not read back to
+	// compute their changes, unless they are captured.
+	OnChangeTables []string
+
+	// CaptureChanges records every row written or deleted in the database,
+	// in the transaction writing it, to be delivered later on. See
+	// GetCapturedChanges.
+	CaptureChanges bool
+}
+
+// tracksChanges returns true if the changes made to the rows of the table
+// must be computed.
+func (s *Synchronization) tracksChanges(table string) bool {
+	return s.CaptureChanges || s.notifiesChanges(table)
+}
+
+// notifiesChanges returns true if the changes made to the rows of the table
+// must be handed to the change handler.
+func (s *Synchronization) notifiesChanges(table string) bool {
+	return s.OnChange != nil && (len(s.OnChangeTables) == 0 || slices.Contains(s.OnChangeTables, table))
 }
 
 // idExistsInTable returns true of the given ID exists in the given database
 // table. It returns false if the ID cannot be found.
-func (s *Synchronization) idExistsInTable(table string, id int) bool {
+func (s *Synchronization) idExistsInTable(table string, id int) (bool, error) {
 	// Look for the given ID in the given table
 	result, err := s.DB.Query(fmt.Sprintf("SELECT id FROM %s WHERE id = %d",
 		table, id))
 	if err != nil {
-		log.Fatal(err)
+		return false, err
 	}
 	defer result.Close()
 
 	// Return true if ID was found
-	return result.Next()
+	return result.Next(), result.Err()
 }
 
 // insertOrUpdateStatement returns a string corresponding to the SQL query to
 // be executed. It checks if the ID exists in the database table. If the ID is
 // present, the returned string will be an update query. If the ID cannot be
-// found, the returned string will be an insert query.
-func (s *Synchronization) insertOrUpdateStatement(forceInsert bool, table string, id int, columns []string) string {
+// found, the returned string will be an insert query. The returned boolean
+// tells if the query is an insert.
+func (s *Synchronization) insertOrUpdateStatement(forceInsert bool, table string, id int, columns []string) (string, bool, error) {
 	var statement string
 
-	if forceInsert || !s.idExistsInTable(table, id) {
-		// ID not found, insert it must be
-		statement = fmt.Sprintf("INSERT INTO %s VALUES (%d", table, id)
+	insert := forceInsert
+	if !insert {
+		exists, err := s.idExistsInTable(table, id)
+		if err != nil {
+			return "", false, err
+		}
+		insert = !exists
+	}
+
+	if insert {
+		// ID not found, insert it must be. Columns are named as migrations
+		// can leave them in another order than the schema one.
+		statement = fmt.Sprintf("INSERT INTO %s (id, %s) VALUES (%d", table, strings.Join(columns, ", "), id)
 		for i := 0; i < len(columns); i++ {
 			statement += ", ?"
 		}
@@ -69,26 +125,67 @@ func (s *Synchronization) insertOrUpdateStatement(forceInsert bool, table string
 		statement += fmt.Sprintf(" WHERE id = %d", id)
 	}
 
-	return statement
+	return statement, insert, nil
 }
 
 func (s *Synchronization) removeDeleted(tx *sql.Tx, table string) error {
-	// Prepare the statement.
-	result, err := tx.Query(fmt.Sprintf("DELETE FROM %s WHERE status == 'deleted'", table))
+	result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE status == 'deleted'", table))
 	if err != nil {
 		return err
 	}
-	return result.Close()
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	metrics.RecordsDeleted.WithLabelValues(table).Add(float64(deleted))
+
+	return nil
+}
+
+// fetchObjects calls the given API function with the search parameters. It
+// records the latency of the call and the number of objects received.
+//
+// The API package does not take a context, the call is therefore abandoned,
+// not interrupted, when the context is done: its result is ignored.
+func fetchObjects[T any](ctx context.Context, table string, get func(map[string]interface{}) (*[]T, error), search map[string]interface{}) (*[]T, error) {
+	type result struct {
+		objects *[]T
+		err     error
+	}
+
+	timer := prometheus.NewTimer(metrics.APIRequestDuration.WithLabelValues(table))
+	results := make(chan result, 1)
+	go func() {
+		objects, err := get(search)
+		results <- result{objects, err}
+	}()
+
+	var r result
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r = <-results:
+	}
+	timer.ObserveDuration()
+
+	if r.err != nil {
+		metrics.APIRequestErrors.WithLabelValues(table).Inc()
+		return nil, r.err
+	}
+	metrics.RecordsFetched.WithLabelValues(table).Add(float64(len(*r.objects)))
+
+	return r.objects, nil
 }
 
 // getLastSyncDate retrieves the timestamp at which the last synchronization
 // has occured. The returned value is an int64.
-func (s *Synchronization) getLastSyncDate(table string) int64 {
+func (s *Synchronization) getLastSyncDate(table string) (int64, error) {
 	switch {
 	case table == "":
-		log.Fatal("table not supplied")
+		return 0, errors.New("table not supplied")
 	case strings.Contains(table, " "):
-		log.Fatal("table malformed")
+		return 0, errors.New("table malformed")
 	}
 
 	updated := time.Unix(0, 0)
@@ -96,26 +193,42 @@ func (s *Synchronization) getLastSyncDate(table string) int64 {
 	// Query for last sync date
 	result, err := s.DB.Query(fmt.Sprintf("SELECT updated FROM %s ORDER BY updated DESC LIMIT 1", table))
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
 	defer result.Close()
 
 	// Get the value
 	if result.Next() {
-		result.Scan(&updated)
+		if err = result.Scan(&updated); err != nil {
+			return 0, err
+		}
 	}
 
-	return updated.Unix()
+	return updated.Unix(), result.Err()
 }
 
 // executeInsertOrUpdate will execute an update or insert query whether the
 // given ID is found in the database table. The given transaction must be
-// commited after calling this function. It returns a non-nil error if an issue
-// has occured.
-func (s *Synchronization) executeInsertOrUpdate(tx *sql.Tx, forceInsert bool, table string, id int, columns []string, values ...interface{}) error {
+// commited after calling this function. If a change handler is set, the
+// resulting change is appended to the given slice. It returns a non-nil error
+// if an issue has occured.
+func (s *Synchronization) executeInsertOrUpdate(tx *sql.Tx, changes *[]Change, forceInsert bool, table string, id int, columns []string, values ...interface{}) error {
+	var old map[string]interface{}
+	var err error
+
+	// Keep the current version of the row to be able to compare it
+	if s.tracksChanges(table) && !forceInsert {
+		if old, err = readRow(tx, table, id); err != nil {
+			return err
+		}
+	}
+
 	// Prepare the database insertion
-	statement, err := tx.Prepare(s.insertOrUpdateStatement(forceInsert, table,
-		id, columns))
+	query, insert, err := s.insertOrUpdateStatement(forceInsert, table, id, columns)
+	if err != nil {
+		return err
+	}
+	statement, err := tx.Prepare(query)
 	if err != nil {
 		return err
 	}
@@ -127,582 +240,352 @@ func (s *Synchronization) executeInsertOrUpdate(tx *sql.Tx, forceInsert bool, ta
 		return err
 	}
 
-	return nil
-}
-
-func (s *Synchronization) SynchronizeOrganizations(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_organization"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
-
-	// Get changed organizations objects since the given timestamp
-	organizations, err := s.API.GetOrganization(search)
-	if err != nil {
-		panic(err)
-	}
-
-	// Slice is empty, nothing to sync
-	if len(*organizations) < 1 {
-		fmt.Printf("No organizations to sync since %s.\n", time.Unix(since, 0))
-		return
+	if insert {
+		metrics.RecordsInserted.WithLabelValues(table).Inc()
+	} else {
+		metrics.RecordsUpdated.WithLabelValues(table).Inc()
 	}
 
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
-	if err != nil {
-		panic(err)
-	}
-
-	// Put values in the database
-	bar.SetTotal(int64(len(*organizations)), false)
-	for _, organization := range *organizations {
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, organization.ID, table.GetColumnsNames(), organization.Created,
-			organization.Updated, organization.Status, organization.Name, organization.NameLong, organization.AKA,
-			organization.Website, marshalJSON(organization.SocialMedia), organization.Notes, organization.Address1,
-			organization.Address2, organization.Country, organization.City, organization.State, organization.Zipcode,
-			organization.Suite, organization.Floor, organization.Latitude, organization.Longitude,
-		)
-		if err != nil {
-			panic(err)
-		}
-
-		bar.Increment()
+	if s.tracksChanges(table) {
+		return s.recordChange(tx, changes, table, id, old)
 	}
 
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, table.Name)
-
-	tx.Commit()
-	bar.SetTotal(-1, true)
+	return nil
 }
 
-func (s *Synchronization) SynchronizeCampuses(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_campus"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
-
-	// Get changed campuses objects since the given timestamp
-	campuses, err := s.API.GetCampus(search)
+// recordChange reads back the row that has just been written and appends the
+// corresponding change to the given slice. Updates that did not modify any
+// column are ignored.
+func (s *Synchronization) recordChange(tx *sql.Tx, changes *[]Change, table string, id int, old map[string]interface{}) error {
+	row, err := readRow(tx, table, id)
 	if err != nil {
-		panic(err)
-	}
-
-	// Slice is empty, nothing to sync
-	if len(*campuses) < 1 {
-		fmt.Printf("No campuses to sync since %s.\n", time.Unix(since, 0))
-		return
-	}
-
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
-	if err != nil {
-		panic(err)
+		return err
 	}
 
-	// Put values in the database
-	bar.SetTotal(int64(len(*campuses)), false)
-	for _, campus := range *campuses {
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, campus.ID, table.GetColumnsNames(), campus.Created, campus.Updated,
-			campus.Status, campus.Name, campus.NameLong, campus.AKA, campus.Website, marshalJSON(campus.SocialMedia),
-			campus.Notes, campus.Country, campus.City, campus.State, campus.Zipcode, campus.OrganizationID,
-		)
-		if err != nil {
-			panic(err)
+	change := Change{Table: table, ID: id, Old: old, New: row}
+	switch {
+	case row["status"] == "deleted":
+		// Row will be removed, nothing to report if we never knew it
+		if old == nil {
+			return nil
+		}
+		change.Operation = OperationDelete
+		change.New = nil
+	case old == nil:
+		change.Operation = OperationInsert
+	default:
+		change.Operation = OperationUpdate
+		if len(change.ChangedFields()) == 0 {
+			return nil
 		}
-
-		bar.Increment()
 	}
 
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, table.Name)
-
-	tx.Commit()
-	bar.SetTotal(-1, true)
+	*changes = append(*changes, change)
+	return nil
 }
 
-func (s *Synchronization) SynchronizeFacilities(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_facility"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
-
-	// Get changed facilities objects since the given timestamp
-	facilities, err := s.API.GetFacility(search)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Slice is empty, nothing to sync
-	if len(*facilities) < 1 {
-		fmt.Printf("No facilities to sync since %s.\n", time.Unix(since, 0))
-		return
-	}
-
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	bar.SetTotal(int64(len(*facilities)), false)
-	for _, facility := range *facilities {
-		// Put values in the database
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, facility.ID, table.GetColumnsNames(), facility.Created, facility.Updated,
-			facility.Status, facility.Name, facility.AKA, facility.NameLong, facility.Website,
-			marshalJSON(facility.SocialMedia), facility.CLLI, facility.Rencode, facility.Npanxx, facility.Notes,
-			facility.SalesEmail, facility.SalesPhone, facility.TechEmail, facility.TechPhone,
-			marshalJSON(facility.AvailableVoltageServices), facility.DiverseServingSubstations, facility.Property,
-			facility.RegionContinent, facility.StatusDashboard, facility.Address1, facility.Address2, facility.City,
-			facility.Country, facility.State, facility.Zipcode, facility.Floor, facility.Suite, facility.Latitude,
-			facility.Longitude, facility.OrganizationID, facility.CampusID,
-		)
-		if err != nil {
-			log.Fatal(err)
+// publishChanges hands the changes of a committed transaction to the change
+// handler, if any.
+func (s *Synchronization) publishChanges(changes []Change) {
+	for _, change := range changes {
+		if s.notifiesChanges(change.Table) {
+			s.OnChange(change)
 		}
-
-		bar.Increment()
 	}
-
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, table.Name)
-
-	tx.Commit()
-	bar.SetTotal(-1, true)
 }
 
-func (s *Synchronization) SynchronizeCarriers(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_carrier"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
+// DefaultPageSize is the number of objects requested at once when the page
+// size of a synchronization is not set.
+const DefaultPageSize = 1000
 
-	// Get changed carriers objects since the given timestamp
-	carriers, err := s.API.GetCarrier(search)
-	if err != nil {
-		log.Fatal(err)
-	}
+// pagesAhead is the number of fetched pages that can wait to be written.
+const pagesAhead = 2
 
-	// Slice is empty, nothing to sync
-	if len(*carriers) < 1 {
-		fmt.Printf("No carriers to sync since %s.\n", time.Unix(since, 0))
-		return
-	}
+// fetchPages requests the objects changed since the given timestamp page by
+// page, starting after the given number of objects, and sends the non-empty
+// pages to the channel, which is closed once all the pages have been fetched.
+// It stops early if the context is done.
+func fetchPages[T any](ctx context.Context, table string, get func(map[string]interface{}) (*[]T, error), since int64, skip int, size int, pages chan<- []T) error {
+	defer close(pages)
 
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	bar.SetTotal(int64(len(*carriers)), false)
-	for _, carrier := range *carriers {
-		// Put values in the database
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, carrier.ID, table.GetColumnsNames(), carrier.Created, carrier.Updated,
-			carrier.Status, carrier.Name, carrier.AKA, carrier.NameLong, carrier.Website,
-			marshalJSON(carrier.SocialMedia), carrier.Notes, carrier.OrganizationID,
-		)
+	for ; ; skip += size {
+		search := map[string]interface{}{"since": since, "limit": size, "skip": skip}
+		objects, err := fetchObjects(ctx, table, get, search)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
-		bar.Increment()
-	}
-
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, table.Name)
-
-	tx.Commit()
-	bar.SetTotal(-1, true)
-}
-func (s *Synchronization) SynchronizeNetworks(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_network"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
-
-	// Get changed networks objects since the given timestamp
-	networks, err := s.API.GetNetwork(search)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Slice is empty, nothing to sync
-	if len(*networks) < 1 {
-		fmt.Printf("No networks to sync since %s.\n", time.Unix(since, 0))
-		return
-	}
-
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	bar.SetTotal(int64(len(*networks)), false)
-	for _, network := range *networks {
-		// Put values in the database
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, network.ID, table.GetColumnsNames(), network.Created, network.Updated,
-			network.Status, network.Name, network.AKA, network.NameLong, network.Website,
-			marshalJSON(network.SocialMedia), network.ASN, network.LookingGlass, network.RouteServer,
-			network.IRRASSet, network.InfoType, marshalJSON(network.InfoTypes), network.InfoPrefixes4,
-			network.InfoPrefixes6, network.InfoTraffic, network.InfoRatio, network.InfoScope, network.InfoUnicast,
-			network.InfoMulticast, network.InfoIPv6, network.InfoNeverViaRouteServers, network.Notes,
-			network.PolicyURL, network.PolicyGeneral, network.PolicyLocations, network.PolicyRatio,
-			network.PolicyContracts, network.AllowIXPUpdate, network.StatusDashboard, network.RIRStatus,
-			network.RIRStatusUpdated, network.OrganizationID,
-		)
-		if err != nil {
-			log.Fatal(err)
+		if len(*objects) > 0 {
+			select {
+			case pages <- *objects:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
 
-		bar.Increment()
+		// A partial page is the last one
+		if len(*objects) < size {
+			return nil
+		}
 	}
-
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, "peeringdb_network")
-
-	tx.Commit()
-	bar.SetTotal(-1, true)
 }
 
-func (s *Synchronization) SynchronizeInternetExchanges(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_ix"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
-
-	// Get changed internet exchanges objects since the given timestamp
-	ixs, err := s.API.GetInternetExchange(search)
+// synchronize updates a table with the objects changed since its last
+// synchronization. Objects are fetched page by page while the previous pages
+// are written, only a few pages being held in memory at once whatever the
+// size of the table. The row function gives the ID of an object and the
+// values of the columns of the table, in order.
+//
+// Each page is committed along with a checkpoint, so that a synchronization
+// interrupted by an error or a restart is resumed at the first page that has
+// not been written instead of trusting the objects already stored.
+//
+// Cancelling the context stops the synchronization, the page being written
+// is rolled back and the error of the context is returned.
+func synchronize[T any](ctx context.Context, s *Synchronization, task progress.Task, name string, get func(map[string]interface{}) (*[]T, error), row func(T) (int, []interface{})) error {
+	table := GetSchema().Tables[name]
+	checkpoint, err := GetCheckpoint(s.DB, table.Name)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Slice is empty, nothing to sync
-	if len(*ixs) < 1 {
-		fmt.Printf("No internet exchanges to sync since %s.\n",
-			time.Unix(since, 0))
-		return
+		return err
 	}
 
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	bar.SetTotal(int64(len(*ixs)), false)
-	for _, ix := range *ixs {
-		// Put values in the database
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, ix.ID, table.GetColumnsNames(), ix.Created, ix.Updated, ix.Status, ix.Name,
-			ix.AKA, ix.NameLong, ix.City, ix.Country, ix.RegionContinent, ix.Media, ix.Notes, ix.ProtoUnicast,
-			ix.ProtoMulticast, ix.ProtoIPv6, ix.Website, marshalJSON(ix.SocialMedia), ix.URLStats, ix.TechEmail,
-			ix.TechPhone, ix.PolicyEmail, ix.PolicyPhone, ix.SalesEmail, ix.SalesPhone, ix.IxfNetCount,
-			ix.IxfLastImport, ix.IxfImportRequest, ix.IxfImportRequestStatus, ix.ServiceLevel, ix.Terms,
-			ix.StatusDashboard, ix.OrganizationID,
-		)
+	resumed := checkpoint != nil
+	if resumed {
+		slog.Info("resuming interrupted synchronization", "table", table.Name,
+			"since", time.Unix(checkpoint.Since, 0), "skip", checkpoint.Skip, "started", checkpoint.Started)
+	} else {
+		since, err := s.getLastSyncDate(table.Name)
 		if err != nil {
-			log.Fatal(err)
+			return err
+		}
+		checkpoint = &Checkpoint{Table: table.Name, Since: since, Started: time.Now().UTC()}
+	}
+	// Objects of a resumed initial synchronization may already be there
+	forceInsert := checkpoint.Since == 0 && !resumed
+
+	size := s.PageSize
+	if size < 1 {
+		size = DefaultPageSize
+	}
+
+	// Fetch the changed objects in the background
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pages := make(chan []T, pagesAhead)
+	fetched := make(chan error, 1)
+	go func() {
+		fetched <- fetchPages(fetchCtx, table.Name, get, checkpoint.Since, checkpoint.Skip, size, pages)
+	}()
+
+	// Write them as they arrive, one transaction per page
+	var total int64
+	for page := range pages {
+		// The total is only known once the last page has been fetched
+		total += int64(len(page))
+		task.SetTotal(total, false)
+
+		if err = writePage(ctx, s, table, page, row, forceInsert, checkpoint, task); err != nil {
+			return err
 		}
-
-		bar.Increment()
 	}
-
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, table.Name)
-
-	tx.Commit()
-	bar.SetTotal(-1, true)
-}
-
-func (s *Synchronization) SynchronizeInternetExchangeFacilities(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_ix_facility"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
-
-	// Get changed internet exchange facilities objects since the given
-	// timestamp
-	ixfacilities, err := s.API.GetInternetExchangeFacility(search)
-	if err != nil {
-		log.Fatal(err)
+	if err = <-fetched; err != nil {
+		return err
 	}
+	task.SetTotal(total, true)
 
-	// Slice is empty, nothing to sync
-	if len(*ixfacilities) < 1 {
-		fmt.Printf("No internet exchange facilities to sync since %s.\n",
-			time.Unix(since, 0))
-		return
+	// No page, nothing to sync
+	if total == 0 && !resumed {
+		slog.Info("nothing to synchronize", "table", table.Name, "since", time.Unix(checkpoint.Since, 0))
+		return nil
 	}
 
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
+	// Remove the entries marked as deleted and mark the synchronization as
+	// completed
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer tx.Rollback()
 
-	bar.SetTotal(int64(len(*ixfacilities)), false)
-	for _, ixfacility := range *ixfacilities {
-		// Put values in the database
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, ixfacility.ID, table.GetColumnsNames(), ixfacility.Created,
-			ixfacility.Updated, ixfacility.Status, ixfacility.Name, ixfacility.City, ixfacility.Country,
-			ixfacility.InternetExchangeID, ixfacility.FacilityID,
-		)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		bar.Increment()
+	if err = s.removeDeleted(tx, table.Name); err != nil {
+		return err
+	}
+	if err = removeCheckpoint(tx, table.Name); err != nil {
+		return err
 	}
 
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, table.Name)
-
-	tx.Commit()
-	bar.SetTotal(-1, true)
+	return tx.Commit()
 }
 
-func (s *Synchronization) SynchronizeInternetExchangeLANs(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_ixlan"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
-
-	// Get changed internet exchange LANs objects since the given timestamp
-	ixlans, err := s.API.GetInternetExchangeLAN(search)
+// writePage writes a page of objects to a table and records the progress of
+// the synchronization in the same transaction, which is rolled back if the
+// context is done before it is committed.
+func writePage[T any](ctx context.Context, s *Synchronization, table Table, page []T, row func(T) (int, []interface{}), forceInsert bool, checkpoint *Checkpoint, task progress.Task) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer tx.Rollback()
 
-	// Slice is empty, nothing to sync
-	if len(*ixlans) < 1 {
-		fmt.Printf("No internet exchange LANs to sync since %s.\n",
-			time.Unix(since, 0))
-		return
-	}
+	var changes []Change
+	for _, object := range page {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
 
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	bar.SetTotal(int64(len(*ixlans)), false)
-	for _, ixlan := range *ixlans {
-		// Put values in the database
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, ixlan.ID, table.GetColumnsNames(), ixlan.Created, ixlan.Updated,
-			ixlan.Status, ixlan.Name, ixlan.Description, ixlan.MTU, ixlan.Dot1QSupport, ixlan.RouteServerASN,
-			ixlan.ARPSponge, ixlan.IXFIXPMemberListURL, ixlan.IXFIXPMemberListURLVisible, ixlan.IXFIXPImportEnabled,
-			ixlan.InternetExchangeID,
-		)
+		id, values := row(object)
+		err = s.executeInsertOrUpdate(tx, &changes, forceInsert, table.Name, id, table.GetColumnsNames(), values...)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-
-		bar.Increment()
-	}
-
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, table.Name)
-
-	tx.Commit()
-	bar.SetTotal(-1, true)
-}
-
-func (s *Synchronization) SynchronizeInternetExchangePrefixes(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_ix_prefix"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
-
-	// Get changed internet exchange prefixes objects since the given timestamp
-	ixpfxs, err := s.API.GetInternetExchangePrefix(search)
-	if err != nil {
-		log.Fatal(err)
 	}
 
-	// Slice is empty, nothing to sync
-	if len(*ixpfxs) < 1 {
-		fmt.Printf("No internet exchange prefixes to sync since %s.\n",
-			time.Unix(since, 0))
-		return
-	}
-
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	bar.SetTotal(int64(len(*ixpfxs)), false)
-	for _, ixpfx := range *ixpfxs {
-		// Put values in the database
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, ixpfx.ID, table.GetColumnsNames(), ixpfx.Created, ixpfx.Updated,
-			ixpfx.Status, ixpfx.Protocol, ixpfx.Prefix, ixpfx.InDFZ, ixpfx.InternetExchangeLANID,
-		)
-		if err != nil {
-			log.Fatal(err)
+	if s.CaptureChanges {
+		if err = captureChanges(tx, changes); err != nil {
+			return err
 		}
-
-		bar.Increment()
 	}
 
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, table.Name)
-
-	tx.Commit()
-	bar.SetTotal(-1, true)
-}
-
-func (s *Synchronization) SynchronizeNetworkContacts(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_network_contact"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
-
-	// Get changed network contacts objects since the given timestamp
-	contacts, err := s.API.GetNetworkContact(search)
-	if err != nil {
-		log.Fatal(err)
+	checkpoint.Skip += len(page)
+	if err = saveCheckpoint(tx, checkpoint); err != nil {
+		return err
 	}
 
-	// Slice is empty, nothing to sync
-	if len(*contacts) < 1 {
-		fmt.Printf("No contacts to sync since %s.\n", time.Unix(since, 0))
-		return
+	if err = tx.Commit(); err != nil {
+		return err
 	}
+	s.publishChanges(changes)
+	task.Increment(len(page))
 
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
-	if err != nil {
-		log.Fatal(err)
-	}
+	return nil
+}
 
-	bar.SetTotal(int64(len(*contacts)), false)
-	for _, netcontact := range *contacts {
-		// Put values in the database
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, netcontact.ID, table.GetColumnsNames(), netcontact.Created,
-			netcontact.Updated, netcontact.Status, netcontact.Role, netcontact.Visible, netcontact.Name,
-			netcontact.Phone, netcontact.Email, netcontact.URL, netcontact.NetworkID,
-		)
-		if err != nil {
-			log.Fatal(err)
+func (s *Synchronization) SynchronizeOrganizations(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_organization", s.API.GetOrganization, func(organization peeringdb.Organization) (int, []interface{}) {
+		return organization.ID, []interface{}{
+			organization.Created, organization.Updated, organization.Status, organization.Name, organization.AKA,
+			organization.NameLong, organization.Website, marshalJSON(organization.SocialMedia), organization.Notes,
+			organization.Address1, organization.Address2, organization.City, organization.Country, organization.State,
+			organization.Zipcode, organization.Floor, organization.Suite, organization.Latitude, organization.Longitude,
 		}
-
-		bar.Increment()
-	}
-
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, table.Name)
-
-	tx.Commit()
-	bar.SetTotal(-1, true)
+	})
 }
 
-func (s *Synchronization) SynchronizeNetworkFacilities(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_network_facility"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
-
-	// Get changed network facilities objects since the given timestamp
-	netfacilities, err := s.API.GetNetworkFacility(search)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Slice is empty, nothing to sync
-	if len(*netfacilities) < 1 {
-		fmt.Printf("No network facilities to sync since %s.\n",
-			time.Unix(since, 0))
-		return
-	}
-
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	bar.SetTotal(int64(len(*netfacilities)), false)
-	for _, netfacility := range *netfacilities {
-		// Put values in the database
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, netfacility.ID, table.GetColumnsNames(), netfacility.Created,
-			netfacility.Updated, netfacility.Status, netfacility.Name, netfacility.City, netfacility.Country,
-			netfacility.LocalASN, netfacility.NetworkID, netfacility.FacilityID,
-		)
-		if err != nil {
-			log.Fatal(err)
+func (s *Synchronization) SynchronizeCampuses(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_campus", s.API.GetCampus, func(campus peeringdb.Campus) (int, []interface{}) {
+		return campus.ID, []interface{}{
+			campus.Created, campus.Updated, campus.Status, campus.Name, campus.NameLong, campus.AKA, campus.Website,
+			marshalJSON(campus.SocialMedia), campus.Notes, campus.City, campus.Country, campus.State, campus.Zipcode,
+			campus.OrganizationID,
 		}
+	})
+}
 
-		bar.Increment()
-	}
+func (s *Synchronization) SynchronizeFacilities(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_facility", s.API.GetFacility, func(facility peeringdb.Facility) (int, []interface{}) {
+		return facility.ID, []interface{}{
+			facility.Created, facility.Updated, facility.Status, facility.Name, facility.AKA, facility.NameLong,
+			facility.Website, marshalJSON(facility.SocialMedia), facility.CLLI, facility.Rencode, facility.Npanxx,
+			facility.Notes, facility.SalesEmail, facility.SalesPhone, facility.TechEmail, facility.TechPhone,
+			marshalJSON(facility.AvailableVoltageServices), facility.DiverseServingSubstations, facility.Property,
+			facility.RegionContinent, facility.StatusDashboard, facility.Address1, facility.Address2, facility.City,
+			facility.Country, facility.State, facility.Zipcode, facility.Floor, facility.Suite, facility.Latitude,
+			facility.Longitude, facility.OrganizationID, nullableID(facility.CampusID),
+		}
+	})
+}
 
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, table.Name)
+func (s *Synchronization) SynchronizeCarriers(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_carrier", s.API.GetCarrier, func(carrier peeringdb.Carrier) (int, []interface{}) {
+		return carrier.ID, []interface{}{
+			carrier.Created, carrier.Updated, carrier.Status, carrier.Name, carrier.AKA, carrier.NameLong,
+			carrier.Website, marshalJSON(carrier.SocialMedia), carrier.Notes, carrier.OrganizationID,
+		}
+	})
+}
 
-	tx.Commit()
-	bar.SetTotal(-1, true)
+func (s *Synchronization) SynchronizeNetworks(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_network", s.API.GetNetwork, func(network peeringdb.Network) (int, []interface{}) {
+		return network.ID, []interface{}{
+			network.Created, network.Updated, network.Status, network.Name, network.AKA, network.NameLong,
+			network.Website, marshalJSON(network.SocialMedia), network.ASN, network.LookingGlass, network.RouteServer,
+			network.IRRASSet, network.InfoType, marshalJSON(network.InfoTypes), network.InfoPrefixes4,
+			network.InfoPrefixes6, network.InfoTraffic, network.InfoRatio, network.InfoScope, network.InfoUnicast,
+			network.InfoMulticast, network.InfoIPv6, network.InfoNeverViaRouteServers, network.Notes, network.PolicyURL,
+			network.PolicyGeneral, network.PolicyLocations, network.PolicyRatio, network.PolicyContracts,
+			network.AllowIXPUpdate, network.StatusDashboard, network.RIRStatus, network.RIRStatusUpdated,
+			network.OrganizationID,
+		}
+	})
 }
 
-func (s *Synchronization) SynchronizeNetworkInternetExchangeLANs(bar *mpb.Bar) {
-	table := GetSchema().Tables["peeringdb_network_ixlan"]
-	since := s.getLastSyncDate(table.Name)
-	search := make(map[string]interface{})
-	search["since"] = since
+func (s *Synchronization) SynchronizeInternetExchanges(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_ix", s.API.GetInternetExchange, func(ix peeringdb.InternetExchange) (int, []interface{}) {
+		return ix.ID, []interface{}{
+			ix.Created, ix.Updated, ix.Status, ix.Name, ix.AKA, ix.NameLong, ix.City, ix.Country, ix.RegionContinent,
+			ix.Media, ix.Notes, ix.ProtoUnicast, ix.ProtoMulticast, ix.ProtoIPv6, ix.Website, marshalJSON(ix.SocialMedia),
+			ix.URLStats, ix.TechEmail, ix.TechPhone, ix.PolicyEmail, ix.PolicyPhone, ix.SalesEmail, ix.SalesPhone,
+			ix.IxfNetCount, ix.IxfLastImport, ix.IxfImportRequest, ix.IxfImportRequestStatus, ix.ServiceLevel, ix.Terms,
+			ix.StatusDashboard, ix.OrganizationID,
+		}
+	})
+}
 
-	// Get changed network internet exchange LANs objects since the given
-	// timestamp
-	netixlans, err := s.API.GetNetworkInternetExchangeLAN(search)
-	if err != nil {
-		log.Fatal(err)
-	}
+func (s *Synchronization) SynchronizeInternetExchangeFacilities(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_ix_facility", s.API.GetInternetExchangeFacility, func(ixfacility peeringdb.InternetExchangeFacility) (int, []interface{}) {
+		return ixfacility.ID, []interface{}{
+			ixfacility.Created, ixfacility.Updated, ixfacility.Status, ixfacility.Name, ixfacility.City,
+			ixfacility.Country, ixfacility.InternetExchangeID, ixfacility.FacilityID,
+		}
+	})
+}
 
-	// Slice is empty, nothing to sync
-	if len(*netixlans) < 1 {
-		fmt.Printf("No network internet exchange LANs to sync since %s.\n",
-			time.Unix(since, 0))
-		return
-	}
+func (s *Synchronization) SynchronizeInternetExchangeLANs(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_ixlan", s.API.GetInternetExchangeLAN, func(ixlan peeringdb.InternetExchangeLAN) (int, []interface{}) {
+		return ixlan.ID, []interface{}{
+			ixlan.Created, ixlan.Updated, ixlan.Status, ixlan.Name, ixlan.Description, ixlan.MTU, ixlan.Dot1QSupport,
+			ixlan.RouteServerASN, ixlan.ARPSponge, ixlan.IXFIXPMemberListURL, ixlan.IXFIXPMemberListURLVisible,
+			ixlan.IXFIXPImportEnabled, ixlan.InternetExchangeID,
+		}
+	})
+}
 
-	// Start to work on the local database
-	tx, err := s.DB.Begin()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	bar.SetTotal(int64(len(*netixlans)), false)
-	for _, netixlan := range *netixlans {
-		// Put values in the database
-		err = s.executeInsertOrUpdate(
-			tx, (since == 0), table.Name, netixlan.ID, table.GetColumnsNames(), netixlan.Created, netixlan.Updated,
-			netixlan.Status, netixlan.Name, netixlan.Notes, netixlan.Speed, netixlan.ASN, netixlan.IPAddr4,
-			netixlan.IPAddr6, netixlan.IsRSPeer, netixlan.BFDSupport, netixlan.Operational, netixlan.NetworkID,
-			netixlan.InternetExchangeID, netixlan.InternetExchangeLANID, netixlan.NetworkSideID,
-			netixlan.InternetExchangeSideID,
-		)
-		if err != nil {
-			log.Fatal(err)
+func (s *Synchronization) SynchronizeInternetExchangePrefixes(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_ix_prefix", s.API.GetInternetExchangePrefix, func(ixpfx peeringdb.InternetExchangePrefix) (int, []interface{}) {
+		family, start, end, length := prefixColumns(ixpfx.Prefix)
+		return ixpfx.ID, []interface{}{
+			ixpfx.Created, ixpfx.Updated, ixpfx.Status, ixpfx.Protocol, ixpfx.Prefix, ixpfx.InDFZ,
+			ixpfx.InternetExchangeLANID, family, start, end, length,
 		}
+	})
+}
 
-		bar.Increment()
-	}
+func (s *Synchronization) SynchronizeNetworkContacts(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_network_contact", s.API.GetNetworkContact, func(netcontact peeringdb.NetworkContact) (int, []interface{}) {
+		return netcontact.ID, []interface{}{
+			netcontact.Created, netcontact.Updated, netcontact.Status, netcontact.Role, netcontact.Visible,
+			netcontact.Name, netcontact.Phone, netcontact.Email, netcontact.URL, netcontact.NetworkID,
+		}
+	})
+}
 
-	// Remove the entries marked as deleted.
-	s.removeDeleted(tx, table.Name)
+func (s *Synchronization) SynchronizeNetworkFacilities(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_network_facility", s.API.GetNetworkFacility, func(netfacility peeringdb.NetworkFacility) (int, []interface{}) {
+		return netfacility.ID, []interface{}{
+			netfacility.Created, netfacility.Updated, netfacility.Status, netfacility.Name, netfacility.City,
+			netfacility.Country, netfacility.LocalASN, netfacility.NetworkID, netfacility.FacilityID,
+		}
+	})
+}
 
-	tx.Commit()
-	bar.SetTotal(-1, true)
+func (s *Synchronization) SynchronizeNetworkInternetExchangeLANs(ctx context.Context, task progress.Task) error {
+	return synchronize(ctx, s, task, "peeringdb_network_ixlan", s.API.GetNetworkInternetExchangeLAN, func(netixlan peeringdb.NetworkInternetExchangeLAN) (int, []interface{}) {
+		return netixlan.ID, []interface{}{
+			netixlan.Created, netixlan.Updated, netixlan.Status, netixlan.Name, netixlan.Notes, netixlan.Speed,
+			netixlan.ASN, netixlan.IPAddr4, netixlan.IPAddr6, netixlan.IsRSPeer, netixlan.BFDSupport,
+			netixlan.Operational, netixlan.NetworkID, netixlan.InternetExchangeID, netixlan.InternetExchangeLANID,
+			nullableID(netixlan.NetworkSideID), nullableID(netixlan.InternetExchangeSideID),
+			addressColumn(netixlan.IPAddr4), addressColumn(netixlan.IPAddr6),
+		}
+	})
 }
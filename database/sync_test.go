@@ -0,0 +1,644 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/netip"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gmazoyer/peeringdb"
+	"github.com/gmazoyer/peeringdb-sync/peeringdbtest"
+	"github.com/gmazoyer/peeringdb-sync/progress"
+)
+
+// syncStep is a synchronized table with the type of the objects it holds and
+// the function synchronizing it.
+type syncStep struct {
+	table     string
+	namespace string
+	run       func(*Synchronization, context.Context, progress.Task) error
+}
+
+// syncSteps lists the synchronized tables in an order satisfying their
+// dependencies.
+var syncSteps = []syncStep{
+	{"peeringdb_organization", "org", (*Synchronization).SynchronizeOrganizations},
+	{"peeringdb_campus", "campus", (*Synchronization).SynchronizeCampuses},
+	{"peeringdb_facility", "fac", (*Synchronization).SynchronizeFacilities},
+	{"peeringdb_carrier", "carrier", (*Synchronization).SynchronizeCarriers},
+	{"peeringdb_network", "net", (*Synchronization).SynchronizeNetworks},
+	{"peeringdb_ix", "ix", (*Synchronization).SynchronizeInternetExchanges},
+	{"peeringdb_ix_facility", "ixfac", (*Synchronization).SynchronizeInternetExchangeFacilities},
+	{"peeringdb_ixlan", "ixlan", (*Synchronization).SynchronizeInternetExchangeLANs},
+	{"peeringdb_ix_prefix", "ixpfx", (*Synchronization).SynchronizeInternetExchangePrefixes},
+	{"peeringdb_network_contact", "poc", (*Synchronization).SynchronizeNetworkContacts},
+	{"peeringdb_network_facility", "netfac", (*Synchronization).SynchronizeNetworkFacilities},
+	{"peeringdb_network_ixlan", "netixlan", (*Synchronization).SynchronizeNetworkInternetExchangeLANs},
+}
+
+// jsonFields gives the API field of the columns not named after it.
+var jsonFields = map[string]string{
+	"peeringdb_network_ixlan.net_side": "net_side_id",
+	"peeringdb_network_ixlan.ix_side":  "ix_side_id",
+}
+
+// syncTest is a migrated database to synchronize from a fake PeeringDB
+// holding the fixtures.
+type syncTest struct {
+	t      *testing.T
+	server *peeringdbtest.Server
+	sync   *Synchronization
+
+	mu      sync.Mutex
+	changes []Change
+}
+
+// newSyncTest prepares a synchronization test, foreign keys are enforced if
+// requested.
+func newSyncTest(t *testing.T, foreignKeys bool) *syncTest {
+	t.Helper()
+
+	server, err := peeringdbtest.NewServerWithFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "peeringdb.db")
+	if foreignKeys {
+		dsn += "?_foreign_keys=on"
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err = Migrate(db, GetSchema()); err != nil {
+		t.Fatal(err)
+	}
+
+	st := &syncTest{t: t, server: server, sync: &Synchronization{API: server.API(), DB: db}}
+	st.sync.OnChange = func(c Change) {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		st.changes = append(st.changes, c)
+	}
+
+	return st
+}
+
+// run synchronizes a single table.
+func (st *syncTest) run(step syncStep) error {
+	return st.runContext(context.Background(), step)
+}
+
+// runContext synchronizes a single table until the context is done.
+func (st *syncTest) runContext(ctx context.Context, step syncStep) error {
+	return step.run(st.sync, ctx, progress.Discard.Task(step.table))
+}
+
+// runAll synchronizes all the tables and returns the changes reported.
+func (st *syncTest) runAll() []Change {
+	st.t.Helper()
+
+	st.mu.Lock()
+	st.changes = nil
+	st.mu.Unlock()
+
+	for _, step := range syncSteps {
+		if err := st.run(step); err != nil {
+			st.t.Fatalf("synchronizing %s: %v", step.table, err)
+		}
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.changes
+}
+
+// row returns a row of the table, nil if it does not exist.
+func (st *syncTest) row(table string, id int) map[string]interface{} {
+	st.t.Helper()
+
+	tx, err := st.sync.DB.Begin()
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	row, err := readRow(tx, table, id)
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	return row
+}
+
+// count returns the number of rows of the table.
+func (st *syncTest) count(table string) int64 {
+	st.t.Helper()
+
+	count, err := CountRows(st.sync.DB, table)
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	return count
+}
+
+// normalize turns a value read from the database or decoded from the API
+// into a comparable string. Missing values, zero times and JSON nulls are
+// all empty strings, as the API package does not tell them apart.
+func normalize(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return normalize(t)
+		}
+		if strings.HasPrefix(v, "[") || strings.HasPrefix(v, "{") || v == "null" {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(v), &decoded); err == nil {
+				return normalize(decoded)
+			}
+		}
+		return v
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+func TestSynchronizeInitial(t *testing.T) {
+	st := newSyncTest(t, false)
+	changes := st.runAll()
+
+	inserted := 0
+	for _, step := range syncSteps {
+		if count, want := st.count(step.table), len(st.server.Objects(step.namespace)); count != int64(want) {
+			t.Errorf("%s has %d rows, want %d", step.table, count, want)
+		}
+		inserted += len(st.server.Objects(step.namespace))
+	}
+
+	for _, request := range st.server.Requests() {
+		if request.Since() != 0 {
+			t.Errorf("initial request for %s made since %d", request.Namespace, request.Since())
+		}
+	}
+
+	if len(changes) != inserted {
+		t.Errorf("got %d changes, want %d inserts", len(changes), inserted)
+	}
+	for _, change := range changes {
+		if change.Operation != OperationInsert {
+			t.Errorf("got %s of %s %d, want an insert", change.Operation, change.Table, change.ID)
+		}
+	}
+}
+
+func TestSynchronizeColumnMapping(t *testing.T) {
+	st := newSyncTest(t, false)
+	st.runAll()
+
+	for _, step := range syncSteps {
+		table := GetSchema().Tables[step.table]
+		for _, object := range st.server.Objects(step.namespace) {
+			row := st.row(step.table, object.ID())
+			if row == nil {
+				t.Errorf("%s %d is missing", step.table, object.ID())
+				continue
+			}
+
+			for _, column := range table.Columns {
+				if column.Derived {
+					continue
+				}
+
+				field, ok := jsonFields[step.table+"."+column.Name]
+				if !ok {
+					field = column.Name
+				}
+				value, ok := object[field]
+				if !ok {
+					t.Errorf("%s.%s has no %s field to be read from", step.table, column.Name, step.namespace)
+					continue
+				}
+
+				if got, want := normalize(row[column.Name]), normalize(value); got != want {
+					t.Errorf("%s %d: %s is %q, want %q", step.table, object.ID(), column.Name, got, want)
+				}
+			}
+		}
+	}
+
+	// Derived columns are computed from the synchronized ones
+	var family int
+	var bin []byte
+	if err := st.sync.DB.QueryRow("SELECT family FROM peeringdb_ix_prefix WHERE id = 100").Scan(&family); err != nil {
+		t.Fatal(err)
+	}
+	if family != 4 {
+		t.Errorf("family of 192.0.2.0/24 is %d, want 4", family)
+	}
+	if err := st.sync.DB.QueryRow("SELECT ipaddr4_bin FROM peeringdb_network_ixlan WHERE id = 40").Scan(&bin); err != nil {
+		t.Fatal(err)
+	}
+	if want := addressBytes(netip.MustParseAddr("192.0.2.10")); !slices.Equal(bin, want) {
+		t.Errorf("ipaddr4_bin is %x, want %x", bin, want)
+	}
+}
+
+func TestSynchronizeIncremental(t *testing.T) {
+	st := newSyncTest(t, false)
+	st.runAll()
+
+	// Newest object of each type known locally
+	latest := make(map[string]int64)
+	for _, step := range syncSteps {
+		for _, object := range st.server.Objects(step.namespace) {
+			latest[step.namespace] = max(latest[step.namespace], object.Updated().Unix())
+		}
+	}
+
+	at := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	if err := st.server.Update("net", 10, at, peeringdbtest.Object{"name": "Renamed Network", "info_prefixes4": 200}); err != nil {
+		t.Fatal(err)
+	}
+	added := st.server.Object("net", 11)
+	added["id"], added["asn"], added["name"], added["updated"] = 12, 64502, "Third Network", at.Format(time.RFC3339)
+	if err := st.server.Put("net", added); err != nil {
+		t.Fatal(err)
+	}
+
+	st.server.ResetRequests()
+	changes := st.runAll()
+
+	for _, request := range st.server.Requests() {
+		if want := latest[request.Namespace]; request.Since() != want {
+			t.Errorf("request for %s made since %d, want %d", request.Namespace, request.Since(), want)
+		}
+	}
+
+	if row := st.row("peeringdb_network", 10); row["name"] != "Renamed Network" || row["info_prefixes4"] != int64(200) {
+		t.Errorf("network 10 has not been updated: %v", row)
+	}
+	if row := st.row("peeringdb_network", 12); row == nil || row["asn"] != int64(64502) {
+		t.Errorf("network 12 has not been inserted: %v", row)
+	}
+	if count := st.count("peeringdb_network"); count != 3 {
+		t.Errorf("got %d networks, want 3", count)
+	}
+
+	// Objects fetched again without any modification are not reported
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2: %v", len(changes), changes)
+	}
+	slices.SortFunc(changes, func(a, b Change) int { return a.ID - b.ID })
+	if c := changes[0]; c.Operation != OperationUpdate || c.ID != 10 ||
+		!slices.Equal(c.ChangedFields(), []string{"info_prefixes4", "name", "updated"}) {
+		t.Errorf("unexpected change: %s of %d changing %v", c.Operation, c.ID, c.ChangedFields())
+	}
+	if c := changes[1]; c.Operation != OperationInsert || c.ID != 12 {
+		t.Errorf("unexpected change: %s of %d", c.Operation, c.ID)
+	}
+}
+
+func TestSynchronizeDeletions(t *testing.T) {
+	st := newSyncTest(t, false)
+	st.runAll()
+
+	at := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	for namespace, id := range map[string]int{"netixlan": 41, "poc": 110, "ixpfx": 101} {
+		if err := st.server.Delete(namespace, id, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes := st.runAll()
+
+	for table, id := range map[string]int{"peeringdb_network_ixlan": 41, "peeringdb_network_contact": 110, "peeringdb_ix_prefix": 101} {
+		if row := st.row(table, id); row != nil {
+			t.Errorf("%s %d has not been removed", table, id)
+		}
+	}
+	if row := st.row("peeringdb_network_ixlan", 40); row == nil {
+		t.Error("peeringdb_network_ixlan 40 has been removed")
+	}
+
+	if len(changes) != 3 {
+		t.Fatalf("got %d changes, want 3: %v", len(changes), changes)
+	}
+	for _, c := range changes {
+		if c.Operation != OperationDelete || c.Old == nil || c.New != nil {
+			t.Errorf("unexpected change: %s of %s %d", c.Operation, c.Table, c.ID)
+		}
+	}
+
+	// Deleted objects are not given to a new database
+	st = newSyncTest(t, false)
+	st.server.Delete("netixlan", 41, at)
+	st.runAll()
+	if count := st.count("peeringdb_network_ixlan"); count != 1 {
+		t.Errorf("got %d rows in a new database, want 1", count)
+	}
+}
+
+func TestSynchronizeChangeTables(t *testing.T) {
+	st := newSyncTest(t, false)
+	st.sync.OnChangeTables = []string{"peeringdb_network", "peeringdb_network_ixlan"}
+	st.sync.CaptureChanges = true
+
+	changes := st.runAll()
+	if len(changes) != 4 {
+		t.Fatalf("got %d changes, want 4: %v", len(changes), changes)
+	}
+	for _, c := range changes {
+		if !slices.Contains(st.sync.OnChangeTables, c.Table) {
+			t.Errorf("unexpected change to %s %d", c.Table, c.ID)
+		}
+	}
+
+	// Captured changes are not restricted
+	captured, err := GetCapturedChanges(st.sync.DB, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := make(map[string]bool)
+	for _, c := range captured {
+		tables[c.Table] = true
+	}
+	if len(tables) != len(syncSteps) {
+		t.Errorf("got changes captured for %d tables, want %d", len(tables), len(syncSteps))
+	}
+}
+
+func TestSynchronizeDependencyOrdering(t *testing.T) {
+	// Each table must be synchronized after the ones it references
+	references := regexp.MustCompile(`REFERENCES (\w+)`)
+	done := make(map[string]bool)
+	for _, step := range syncSteps {
+		for _, column := range GetSchema().Tables[step.table].Columns {
+			for _, match := range references.FindAllStringSubmatch(column.Constraints, -1) {
+				if !done[match[1]] {
+					t.Errorf("%s.%s references %s which is synchronized later", step.table, column.Name, match[1])
+				}
+			}
+		}
+		done[step.table] = true
+	}
+
+	// Following that order satisfies the foreign keys
+	st := newSyncTest(t, true)
+	st.runAll()
+
+	rows, err := st.sync.DB.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, parent string
+		var id, index sql.NullInt64
+		if err = rows.Scan(&table, &id, &parent, &index); err != nil {
+			t.Fatal(err)
+		}
+		t.Errorf("%s %d references a missing %s", table, id.Int64, parent)
+	}
+
+	// Not following it does not
+	st = newSyncTest(t, true)
+	if err := st.run(syncSteps[len(syncSteps)-1]); err == nil {
+		t.Error("synchronizing connections to IXs before the IXs succeeded")
+	}
+}
+
+func TestSynchronizeErrors(t *testing.T) {
+	st := newSyncTest(t, false)
+	organizations, networks := syncSteps[0], syncSteps[4]
+	if err := st.run(organizations); err != nil {
+		t.Fatal(err)
+	}
+
+	st.server.Fail("net", http.StatusInternalServerError, 1)
+	if err := st.run(networks); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("got error %v, want an internal server error", err)
+	}
+	if count := st.count("peeringdb_network"); count != 0 {
+		t.Errorf("got %d networks after a failure, want none", count)
+	}
+
+	st.server.Fail("net", http.StatusTooManyRequests, 1)
+	if err := st.run(networks); !errors.Is(err, peeringdb.ErrRateLimitExceeded) {
+		t.Errorf("got error %v, want %v", err, peeringdb.ErrRateLimitExceeded)
+	}
+
+	// Failures are temporary
+	if err := st.run(networks); err != nil {
+		t.Fatal(err)
+	}
+	if count := st.count("peeringdb_network"); count != 2 {
+		t.Errorf("got %d networks, want 2", count)
+	}
+}
+
+func TestSynchronizePages(t *testing.T) {
+	st := newSyncTest(t, false)
+	st.sync.PageSize = 1
+	st.runAll()
+
+	for _, step := range syncSteps {
+		if count, want := st.count(step.table), len(st.server.Objects(step.namespace)); count != int64(want) {
+			t.Errorf("%s has %d rows, want %d", step.table, count, want)
+		}
+	}
+
+	// One page per object and a last empty one, unless the last page is partial
+	var skips []string
+	for _, request := range st.server.Requests() {
+		if request.Namespace == "netixlan" {
+			if limit := request.Query.Get("limit"); limit != "1" {
+				t.Errorf("netixlan requested with limit %s, want 1", limit)
+			}
+			skips = append(skips, request.Query.Get("skip"))
+		}
+	}
+	if want := []string{"0", "1", "2"}; !slices.Equal(skips, want) {
+		t.Errorf("netixlan requested with skips %v, want %v", skips, want)
+	}
+}
+
+func TestSynchronizeResume(t *testing.T) {
+	st := newSyncTest(t, false)
+	st.sync.PageSize = 1
+	netixlans := syncSteps[len(syncSteps)-1]
+
+	// Pages already written are kept along with a checkpoint
+	st.server.FailAfter("netixlan", 1, http.StatusBadGateway, 1)
+	if err := st.run(netixlans); err == nil {
+		t.Fatal("synchronization succeeded although a page could not be fetched")
+	}
+	if count := st.count("peeringdb_network_ixlan"); count != 1 {
+		t.Errorf("got %d rows after a failure, want 1", count)
+	}
+	checkpoint, err := GetCheckpoint(st.sync.DB, "peeringdb_network_ixlan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint == nil || checkpoint.Since != 0 || checkpoint.Skip != 1 {
+		t.Fatalf("unexpected checkpoint: %+v", checkpoint)
+	}
+
+	// The next run starts at the first missing page
+	st.server.ResetRequests()
+	if err = st.run(netixlans); err != nil {
+		t.Fatal(err)
+	}
+	if count := st.count("peeringdb_network_ixlan"); count != 2 {
+		t.Errorf("got %d rows, want 2", count)
+	}
+	if requests := st.server.Requests(); len(requests) == 0 || requests[0].Query.Get("skip") != "1" || requests[0].Since() != 0 {
+		t.Errorf("unexpected requests: %+v", requests)
+	}
+	if checkpoints, err := GetCheckpoints(st.sync.DB); err != nil || len(checkpoints) != 0 {
+		t.Errorf("got checkpoints %+v (%v) once completed, want none", checkpoints, err)
+	}
+}
+
+func TestSynchronizeResumeIncremental(t *testing.T) {
+	st := newSyncTest(t, false)
+	st.runAll()
+	st.sync.PageSize = 1
+	networks := syncSteps[4]
+
+	var since int64
+	for _, object := range st.server.Objects("net") {
+		since = max(since, object.Updated().Unix())
+	}
+
+	// The first network written is the most recently updated, the newest
+	// stored object must not be trusted to resume
+	at := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	if err := st.server.Update("net", 10, at.Add(time.Hour), peeringdbtest.Object{"name": "Renamed Network"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.server.Update("net", 11, at, peeringdbtest.Object{"name": "Renamed Other Network"}); err != nil {
+		t.Fatal(err)
+	}
+
+	st.server.FailAfter("net", 1, http.StatusBadGateway, 1)
+	if err := st.run(networks); err == nil {
+		t.Fatal("synchronization succeeded although a page could not be fetched")
+	}
+
+	st.server.ResetRequests()
+	if err := st.run(networks); err != nil {
+		t.Fatal(err)
+	}
+	if requests := st.server.Requests(); len(requests) == 0 || requests[0].Query.Get("skip") != "1" || requests[0].Since() != since {
+		t.Errorf("unexpected requests: %+v", requests)
+	}
+	if row := st.row("peeringdb_network", 11); row["name"] != "Renamed Other Network" {
+		t.Errorf("network 11 has not been updated: %v", row)
+	}
+}
+
+func TestSynchronizeCancel(t *testing.T) {
+	st := newSyncTest(t, false)
+	st.sync.PageSize = 1
+	netixlans := syncSteps[len(syncSteps)-1]
+
+	// Nothing is requested once cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := st.runContext(ctx, netixlans); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if requests := st.server.Requests(); len(requests) != 0 {
+		t.Errorf("unexpected requests: %+v", requests)
+	}
+
+	// Cancelling once the first page is committed keeps it and stops there
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	st.sync.OnChange = func(Change) { cancel() }
+	if err := st.runContext(ctx, netixlans); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if count := st.count("peeringdb_network_ixlan"); count != 1 {
+		t.Errorf("got %d rows after cancellation, want 1", count)
+	}
+	checkpoint, err := GetCheckpoint(st.sync.DB, "peeringdb_network_ixlan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint == nil || checkpoint.Skip != 1 {
+		t.Errorf("unexpected checkpoint: %+v", checkpoint)
+	}
+}
+
+func TestInsertColumnOrder(t *testing.T) {
+	st := newSyncTest(t, true)
+	st.runAll()
+
+	// Columns added by migrations are at the end of the tables, whatever
+	// their place in the schema
+	for _, query := range []string{
+		"ALTER TABLE peeringdb_ixlan ADD COLUMN moved bool NULL",
+		"UPDATE peeringdb_ixlan SET moved = ixf_ixp_member_list_url_visible",
+		"ALTER TABLE peeringdb_ixlan DROP COLUMN ixf_ixp_member_list_url_visible",
+		"ALTER TABLE peeringdb_ixlan RENAME COLUMN moved TO ixf_ixp_member_list_url_visible",
+	} {
+		if _, err := st.sync.DB.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rows inserted afterwards have their values in the right columns
+	added := st.server.Object("ixlan", 30)
+	added["id"], added["ixf_ixp_member_list_url_visible"] = 31, "Private"
+	if err := st.server.Put("ixlan", added); err != nil {
+		t.Fatal(err)
+	}
+	st.runAll()
+	existing, inserted := st.row("peeringdb_ixlan", 30), st.row("peeringdb_ixlan", 31)
+	if inserted == nil {
+		t.Fatal("IX LAN 31 not inserted")
+	}
+	for column, value := range existing {
+		want := value
+		switch column {
+		case "id":
+			want = int64(31)
+		case "ixf_ixp_member_list_url_visible":
+			want = "Private"
+		}
+		if !valuesEqual(inserted[column], want) {
+			t.Errorf("got %s = %v, want %v", column, inserted[column], want)
+		}
+	}
+
+}
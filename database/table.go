@@ -10,6 +10,7 @@ type Column struct {
 	Name        string // Column name
 	Type        string // Data type (e.g., INTEGER, VARCHAR, etc.)
 	Constraints string // Constraints (e.g., NOT NULL, PRIMARY KEY, etc.)
+	Derived     bool   // Computed from other columns instead of coming from the API
 }
 
 // Table represents a schema of a table.
@@ -19,10 +20,18 @@ type Table struct {
 	UniquenessConstraints []string // List of uniqueness constraints
 }
 
+// View represents a view of the database.
+type View struct {
+	Name  string // View name
+	Query string // SELECT statement defining the view
+}
+
 // Schema represents a schema of a database.
 type Schema struct {
-	Tables  map[string]Table // List of tables
-	Indexes []string         // List of indexes
+	Tables         map[string]Table // List of tables
+	Indexes        []string         // List of indexes
+	SpatialIndexes []SpatialIndex   // List of R*Tree indexes on coordinates
+	Views          []View           // List of views
 }
 
 // GetColumnsNames returns the list of column names in the table without the "id" one.
@@ -36,6 +45,16 @@ func (t *Table) GetColumnsNames() []string {
 	return names
 }
 
+// isDerived returns true if the column with the given name is a derived one.
+func (t *Table) isDerived(name string) bool {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column.Derived
+		}
+	}
+	return false
+}
+
 // generateCreateTableQuery generates the SQL CREATE TABLE statement from the Table struct.
 func (t *Table) generateCreateTableQuery() string {
 	query := fmt.Sprintf("CREATE TABLE %s (\n", t.Name)
@@ -54,6 +73,11 @@ func (t *Table) generateCreateTableQuery() string {
 	return query
 }
 
+// generateCreateViewQuery generates the SQL CREATE VIEW statement from the View struct.
+func (v *View) generateCreateViewQuery() string {
+	return fmt.Sprintf("CREATE VIEW %s AS\n%s;", v.Name, v.Query)
+}
+
 // GenerateSchemaQuery generates the SQL CREATE TABLE statements from the Schema struct.
 func (s *Schema) GenerateSchemaQuery() string {
 	query := ""
@@ -65,6 +89,14 @@ func (s *Schema) GenerateSchemaQuery() string {
 		query += index + "\n"
 	}
 
+	for _, index := range s.SpatialIndexes {
+		query += strings.Join(index.generateQueries(), "\n") + "\n"
+	}
+
+	for _, view := range s.Views {
+		query += "\n" + view.generateCreateViewQuery() + "\n"
+	}
+
 	return query
 }
 
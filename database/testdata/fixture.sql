@@ -0,0 +1,92 @@
+-- Small data set used by the tests of the database package.
+
+INSERT INTO peeringdb_organization (id, created, updated, status, name, aka, name_long, website, social_media,
+  notes, address1, address2, city, country, state, zipcode, floor, suite, latitude, longitude)
+VALUES
+  (1, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example Org', 'ExOrg', 'Example Organization',
+   'https://example.com', '[]', '', '1 Example Street', '', 'Frankfurt', 'DE', '', '60311', '', '', 50.11, 8.68);
+
+INSERT INTO peeringdb_network (id, created, updated, status, name, aka, name_long, website, social_media, asn,
+  looking_glass, route_server, irr_as_set, info_type, info_types, info_prefixes4, info_prefixes6, info_traffic,
+  info_ratio, info_scope, info_unicast, info_multicast, info_ipv6, info_never_via_route_servers, notes, policy_url,
+  policy_general, policy_locations, policy_ratio, policy_contracts, allow_ixp_update, status_dashboard, rir_status,
+  rir_status_updated, org_id)
+VALUES
+  (10, '2020-01-02 03:04:05+00:00', '2024-05-06 07:08:09+00:00', 'ok', 'Example Network', 'ExNet',
+   'Example Network Ltd', 'https://net.example.com',
+   '[{"service":"website","identifier":"https://net.example.com"}]', 64500, 'https://lg.example.com', '',
+   'AS-EXAMPLE', 'NSP', '["NSP","Content"]', 100, 50, '1-5Tbps', 'Balanced', 'Europe', 1, 0, 1, 0,
+   'Example notes', 'https://net.example.com/peering', 'Open', 'Not Required', 0, 'Not Required', 1, '', 'ok',
+   '2024-01-01 00:00:00+00:00', 1),
+  (11, '2020-01-02 03:04:05+00:00', '2024-05-06 07:08:09+00:00', 'ok', 'Other Network', '', '', '', NULL, 64501, '',
+   '', 'AS-OTHER', 'Content', NULL, NULL, NULL, '', '', '', 1, 0, 1, 1, '', '', 'Selective', '', 0, '', 0, '',
+   NULL, NULL, 1),
+  (12, '2020-01-02 03:04:05+00:00', '2024-05-06 07:08:09+00:00', 'ok', 'Third Network', '', '', '', NULL, 64502, '',
+   '', '', 'Enterprise', NULL, 10, 10, '', '', '', 1, 0, 0, 0, '', '', 'Restrictive', '', 0, '', 0, '', NULL, NULL,
+   1);
+
+INSERT INTO peeringdb_ix (id, created, updated, status, name, aka, name_long, city, country, region_continent,
+  media, notes, proto_unicast, proto_multicast, proto_ipv6, website, social_media, url_stats, tech_email, tech_phone,
+  policy_email, policy_phone, sales_email, sales_phone, ixf_net_count, ixf_last_import, ixf_import_request,
+  ixf_import_request_status, service_level, terms, status_dashboard, org_id)
+VALUES
+  (20, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example-IX', 'EXIX',
+   'Example Internet Exchange', 'Frankfurt', 'DE', 'Europe', 'Ethernet', NULL, 1, 0, 1, 'https://ix.example.com',
+   '[{"service":"x","identifier":"example_ix"}]', '', 'noc@ix.example.com', '', '', '', '', '', 2,
+   '2024-03-04 05:06:07+00:00', NULL, 'queued', 'Not Disclosed', 'Not Disclosed', NULL, 1),
+  (21, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Second-IX', '', '', 'Amsterdam', 'NL',
+   'Europe', 'Ethernet', 'Second notes', 1, 0, 1, '', NULL, '', '', '', '', '', '', '', 0, NULL, NULL, NULL,
+   'Not Disclosed', 'Not Disclosed', '', 1),
+  (22, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Unused-IX', '', '', 'Paris', 'FR',
+   'Europe', 'Ethernet', NULL, 1, 0, 0, '', NULL, '', '', '', '', '', '', '', 0, NULL, NULL, NULL,
+   'Not Disclosed', 'Not Disclosed', NULL, 1);
+
+INSERT INTO peeringdb_ixlan (id, created, updated, status, name, descr, mtu, dot1q_support, rs_asn, arp_sponge,
+  ixf_ixp_member_list_url, ixf_ixp_member_list_url_visible, ixf_ixp_import_enabled, ix_id)
+VALUES
+  (30, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', '', '', 1500, 0, 64999, NULL, '', 'Public', 0, 20),
+  (31, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', '', '', 1500, 0, NULL, NULL, '', 'Public', 0, 21),
+  (32, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', '', '', 1500, 0, NULL, NULL, '', 'Public', 0, 22);
+
+INSERT INTO peeringdb_network_ixlan (id, created, updated, status, name, notes, speed, asn, ipaddr4, ipaddr6,
+  is_rs_peer, bfd_support, operational, net_id, ix_id, ixlan_id, net_side, ix_side)
+VALUES
+  (40, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example-IX', '', 10000, 64500,
+   '192.0.2.10', '2001:db8::10', 1, 0, 1, 10, 20, 30, NULL, NULL),
+  (41, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example-IX', '', 10000, 64500,
+   '192.0.2.11', '2001:db8::11', 0, 0, 1, 10, 20, 30, NULL, NULL),
+  (42, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Second-IX', '', 1000, 64500,
+   '198.51.100.10', NULL, 0, 0, 1, 10, 21, 31, NULL, NULL),
+  (43, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example-IX', '', 1000, 64501,
+   '192.0.2.20', NULL, 1, 0, 1, 11, 20, 30, NULL, NULL),
+  (44, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'deleted', 'Unused-IX', '', 1000, 64500,
+   '203.0.113.10', NULL, 0, 0, 1, 10, 22, 32, NULL, NULL);
+
+INSERT INTO peeringdb_facility (id, created, updated, status, name, aka, name_long, website, social_media, clli,
+  rencode, npanxx, notes, sales_email, sales_phone, tech_email, tech_phone, available_voltage_services,
+  diverse_serving_substations, property, region_continent, status_dashboard, address1, address2, city, country,
+  state, zipcode, floor, suite, latitude, longitude, org_id, campus_id)
+VALUES
+  (50, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example DC Frankfurt', 'EXDC1', '',
+   'https://dc.example.com', '[]', 'FRNKGE', '', '', '', '', '', '', '', '["230 V"]', 1, 'Owner', 'Europe', NULL,
+   '1 Data Street', '', 'Frankfurt', 'DE', '', '60314', '2', 'A', 50.1, 8.7, 1, NULL),
+  (51, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example DC Amsterdam', '', '', '', '', '',
+   '', '', '', '', '', '', '', NULL, NULL, NULL, NULL, NULL, '2 Data Street', '', 'Amsterdam', 'NL', '', '1000',
+   '', '', NULL, NULL, 1, NULL),
+  (52, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example DC Berlin', '', '', '', '', '',
+   '', '', '', '', '', '', '', NULL, NULL, NULL, NULL, NULL, '3 Data Street', '', 'Berlin', 'DE', '', '10115', '',
+   '', 52.5, 13.4, 1, NULL);
+
+INSERT INTO peeringdb_ix_facility (id, created, updated, status, name, city, country, ix_id, fac_id)
+VALUES
+  (70, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example DC Frankfurt', 'Frankfurt', 'DE', 20, 50),
+  (71, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example DC Amsterdam', 'Amsterdam', 'NL', 20, 51),
+  (72, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'deleted', 'Example DC Berlin', 'Berlin', 'DE', 20, 52),
+  (73, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example DC Amsterdam', 'Amsterdam', 'NL', 21, 51);
+
+INSERT INTO peeringdb_network_facility (id, created, updated, status, name, city, country, local_asn, net_id, fac_id)
+VALUES
+  (60, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example DC Frankfurt', 'Frankfurt', 'DE', 64500, 10, 50),
+  (61, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example DC Frankfurt', 'Frankfurt', 'DE', 64501, 11, 50),
+  (62, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'ok', 'Example DC Amsterdam', 'Amsterdam', 'NL', 64501, 11, 51),
+  (63, '2020-01-01 00:00:00+00:00', '2024-01-01 00:00:00+00:00', 'deleted', 'Example DC Frankfurt', 'Frankfurt', 'DE', 64502, 12, 50);
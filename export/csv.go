@@ -0,0 +1,66 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvWriter writes rows as CSV lines after a header line naming the columns.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []Column
+	record  []string
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	c := &csvWriter{w: csv.NewWriter(w), columns: columns, record: make([]string, len(columns))}
+	for i, column := range columns {
+		c.record[i] = column.Name
+	}
+	if err := c.w.Write(c.record); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// csvValue formats a value: NULL is an empty field, times are in RFC 3339
+// format and binary values in hexadecimal.
+func csvValue(value interface{}, t Type) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		if t == TypeBytes {
+			return hex.EncodeToString(v)
+		}
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (c *csvWriter) Write(values []interface{}) error {
+	for i, value := range values {
+		c.record[i] = csvValue(value, c.columns[i].Type)
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
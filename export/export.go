@@ -0,0 +1,252 @@
+// Package export writes the tables and views of the database to files that
+// analytical tools can load, as CSV or Parquet.
+//
+// Columns are typed after their declared SQL type: integers, floating point
+// numbers, booleans, times, binary values and text. Rows are written as they
+// are read, so exporting a table does not require holding it in memory.
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+)
+
+// Formats of the exported files.
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{FormatCSV, FormatParquet}
+
+// Type is the type of an exported column.
+type Type int
+
+const (
+	TypeString Type = iota
+	TypeInteger
+	TypeFloat
+	TypeBoolean
+	TypeTime
+	TypeBytes
+)
+
+// ColumnType returns the type of a column declared with the given SQL type,
+// following the SQLite affinity rules. Unknown types, and columns of views
+// computed by expressions which have none, are exported as text.
+func ColumnType(declared string) Type {
+	declared = strings.ToLower(declared)
+	switch {
+	case strings.Contains(declared, "int"):
+		return TypeInteger
+	case strings.Contains(declared, "float"), strings.Contains(declared, "real"),
+		strings.Contains(declared, "double"), strings.Contains(declared, "decimal"), strings.Contains(declared, "numeric"):
+		return TypeFloat
+	case strings.HasPrefix(declared, "bool"):
+		return TypeBoolean
+	case strings.HasPrefix(declared, "datetime"), strings.HasPrefix(declared, "timestamp"), declared == "date":
+		return TypeTime
+	case strings.Contains(declared, "blob"):
+		return TypeBytes
+	default:
+		return TypeString
+	}
+}
+
+// textColumns lists the columns, as table.column, whose declared type does not
+// match the values PeeringDB sends. They are exported as text.
+var textColumns = map[string]bool{
+	// Declared as a boolean but holding a visibility such as Public
+	"peeringdb_ixlan.ixf_ixp_member_list_url_visible": true,
+}
+
+// Column is an exported column.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Source is a table or a view to export.
+type Source struct {
+	Name    string
+	Columns []Column
+	View    bool
+}
+
+// Sources returns the tables and views of the schema with the given names,
+// all of them if none is given, tables first.
+func Sources(db *sql.DB, schema *database.Schema, names []string) ([]Source, error) {
+	var views []string
+	for _, view := range schema.Views {
+		views = append(views, view.Name)
+	}
+	tables := schema.GetTableNames()
+	sort.Strings(tables)
+
+	if len(names) == 0 {
+		names = append(tables, views...)
+	}
+
+	sources := make([]Source, 0, len(names))
+	for _, name := range names {
+		switch {
+		case slices.Contains(tables, name):
+			table := schema.Tables[name]
+			source := Source{Name: name}
+			for _, column := range table.Columns {
+				columnType := ColumnType(column.Type)
+				if textColumns[name+"."+column.Name] {
+					columnType = TypeString
+				}
+				source.Columns = append(source.Columns, Column{Name: column.Name, Type: columnType})
+			}
+			sources = append(sources, source)
+		case slices.Contains(views, name):
+			source, err := viewSource(db, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the columns of %s: %w", name, err)
+			}
+			sources = append(sources, source)
+		default:
+			return nil, fmt.Errorf("unknown table or view %s", name)
+		}
+	}
+
+	return sources, nil
+}
+
+// viewSource returns the columns of a view, typed after the columns they are
+// read from.
+func viewSource(db *sql.DB, name string) (Source, error) {
+	rows, err := db.Query("SELECT * FROM " + name + " LIMIT 0")
+	if err != nil {
+		return Source{}, err
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return Source{}, err
+	}
+
+	source := Source{Name: name, View: true}
+	for _, t := range types {
+		source.Columns = append(source.Columns, Column{Name: t.Name(), Type: ColumnType(t.DatabaseTypeName())})
+	}
+	return source, nil
+}
+
+// rowWriter writes rows in an export format.
+type rowWriter interface {
+	Write(values []interface{}) error
+	Close() error
+}
+
+// Options tunes the exported files.
+type Options struct {
+	Format string
+	// Gzip compresses CSV files, Parquet files being compressed anyway.
+	Gzip bool
+}
+
+// Extension returns the extension of the files written with the options.
+func (o Options) Extension() string {
+	if o.Format == FormatCSV && o.Gzip {
+		return ".csv.gz"
+	}
+	return "." + o.Format
+}
+
+// Write writes the rows of the source to the writer and returns how many
+// have been.
+func Write(ctx context.Context, db *sql.DB, source Source, options Options, w io.Writer) (int64, error) {
+	if options.Gzip && options.Format == FormatCSV {
+		compressed := gzip.NewWriter(w)
+		count, err := Write(ctx, db, source, Options{Format: FormatCSV}, compressed)
+		if closeErr := compressed.Close(); err == nil {
+			err = closeErr
+		}
+		return count, err
+	}
+
+	var writer rowWriter
+	var err error
+	switch options.Format {
+	case FormatCSV:
+		writer, err = newCSVWriter(w, source.Columns)
+	case FormatParquet:
+		writer = newParquetWriter(w, source)
+	default:
+		return 0, fmt.Errorf("unknown format %q, expected one of %s", options.Format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	names := make([]string, len(source.Columns))
+	for i, column := range source.Columns {
+		names[i] = column.Name
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(names, ", "), source.Name))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	values := make([]interface{}, len(source.Columns))
+	pointers := make([]interface{}, len(source.Columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(pointers...); err != nil {
+			return count, err
+		}
+		if err = writer.Write(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err = rows.Err(); err != nil {
+		return count, err
+	}
+
+	return count, writer.Close()
+}
+
+// WriteFile writes the rows of the source to a file of the directory named
+// after it and returns its path and the number of rows written. The file
+// only appears once complete.
+func WriteFile(ctx context.Context, db *sql.DB, source Source, options Options, dir string) (string, int64, error) {
+	path := filepath.Join(dir, source.Name+options.Extension())
+	f, err := os.CreateTemp(dir, "."+source.Name+"-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(f.Name())
+
+	count, err := Write(ctx, db, source, options, f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", count, err
+	}
+
+	return path, count, os.Rename(f.Name(), path)
+}
@@ -0,0 +1,194 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/gmazoyer/peeringdb-sync/internal/testutil"
+	"github.com/gmazoyer/peeringdb-sync/progress"
+	"github.com/parquet-go/parquet-go"
+)
+
+// newDatabase returns a database holding the organizations and networks of
+// the fixtures.
+func newDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+
+	server, db := testutil.NewDatabase(t)
+	testutil.SynchronizeNetworks(t, &database.Synchronization{API: server.API(), DB: db})
+	return db
+}
+
+// source returns the source with the given name.
+func source(t *testing.T, db *sql.DB, name string) Source {
+	t.Helper()
+
+	sources, err := Sources(db, database.GetSchema(), []string{name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sources[0]
+}
+
+func TestColumnType(t *testing.T) {
+	for declared, want := range map[string]Type{
+		"integer":          TypeInteger,
+		"integer unsigned": TypeInteger,
+		"INTEGER":          TypeInteger,
+		"float":            TypeFloat,
+		"bool":             TypeBoolean,
+		"datetime":         TypeTime,
+		"blob":             TypeBytes,
+		"varchar(255)":     TypeString,
+		"":                 TypeString,
+	} {
+		if got := ColumnType(declared); got != want {
+			t.Errorf("%q: got type %d, want %d", declared, got, want)
+		}
+	}
+}
+
+func TestSources(t *testing.T) {
+	db := newDatabase(t)
+
+	sources, err := Sources(db, database.GetSchema(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := database.GetSchema()
+	if len(sources) != len(schema.Tables)+len(schema.Views) {
+		t.Errorf("got %d sources, want every table and view", len(sources))
+	}
+	last := sources[len(sources)-1]
+	if !last.View || len(last.Columns) == 0 {
+		t.Errorf("unexpected view %+v", last)
+	}
+
+	if _, err = Sources(db, schema, []string{"peeringdb_unknown"}); err == nil {
+		t.Error("unknown table exported")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	db := newDatabase(t)
+	dir := t.TempDir()
+
+	path, count, err := WriteFile(context.Background(), db, source(t, db, "peeringdb_network"), Options{Format: FormatCSV, Gzip: true}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "peeringdb_network.csv.gz" || count != 2 {
+		t.Errorf("wrote %d rows to %s", count, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d lines, want a header and 2 rows", len(records))
+	}
+
+	fields := make(map[string]string)
+	for i, name := range records[0] {
+		fields[name] = records[1][i]
+	}
+	if fields["asn"] != "64500" || fields["name"] == "" {
+		t.Errorf("unexpected row %v", fields)
+	}
+	if _, err = time.Parse(time.RFC3339, fields["created"]); err != nil {
+		t.Errorf("unexpected time %q", fields["created"])
+	}
+
+	// Only the exported file is left in the directory
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d files, want 1", len(entries))
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	db := newDatabase(t)
+
+	var buffer bytes.Buffer
+	count, err := Write(context.Background(), db, source(t, db, "peeringdb_network"), Options{Format: FormatParquet}, &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("wrote %d rows, want 2", count)
+	}
+
+	reader := parquet.NewReader(bytes.NewReader(buffer.Bytes()))
+	defer reader.Close()
+	if reader.NumRows() != 2 {
+		t.Fatalf("got %d rows, want 2", reader.NumRows())
+	}
+
+	schema := reader.Schema()
+	asn, _ := schema.Lookup("asn")
+	name, _ := schema.Lookup("name")
+	created, _ := schema.Lookup("created")
+	unicast, _ := schema.Lookup("info_unicast")
+
+	rows := make([]parquet.Row, 2)
+	if n, err := reader.ReadRows(rows); n != 2 {
+		t.Fatalf("read %d rows: %v", n, err)
+	}
+	row := rows[0]
+	if row[asn.ColumnIndex].Int64() != 64500 || row[name.ColumnIndex].String() == "" || !row[unicast.ColumnIndex].Boolean() {
+		t.Errorf("unexpected row %v", row)
+	}
+	if at := time.UnixMicro(row[created.ColumnIndex].Int64()); at.Year() < 2000 {
+		t.Errorf("unexpected time %s", at)
+	}
+}
+
+func TestWriteTextColumn(t *testing.T) {
+	server, db := testutil.NewDatabase(t)
+	s := &database.Synchronization{API: server.API(), DB: db}
+	testutil.SynchronizeNetworks(t, s)
+	if err := s.SynchronizeInternetExchanges(context.Background(), progress.Discard.Task("ix")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SynchronizeInternetExchangeLANs(context.Background(), progress.Discard.Task("ixlan")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The visibility is text although the column is declared as a boolean
+	var buffer bytes.Buffer
+	if _, err := Write(context.Background(), db, source(t, db, "peeringdb_ixlan"), Options{Format: FormatParquet}, &buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := parquet.NewReader(bytes.NewReader(buffer.Bytes()))
+	defer reader.Close()
+	visible, _ := reader.Schema().Lookup("ixf_ixp_member_list_url_visible")
+	enabled, _ := reader.Schema().Lookup("ixf_ixp_import_enabled")
+	rows := make([]parquet.Row, 1)
+	if n, _ := reader.ReadRows(rows); n != 1 {
+		t.Fatalf("read %d rows, want 1", n)
+	}
+	if value := rows[0][visible.ColumnIndex].String(); value != "Public" {
+		t.Errorf("got visibility %q, want Public", value)
+	}
+	if value := rows[0][enabled.ColumnIndex]; value.Kind() != parquet.Boolean || !value.Boolean() {
+		t.Errorf("got import enabled %v, want true", value)
+	}
+}
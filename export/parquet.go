@@ -0,0 +1,176 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	// parquetRowGroupSize is the maximum number of rows of a row group, the
+	// rows of a group being held in memory until it is written.
+	parquetRowGroupSize = 100000
+	// parquetBatchSize is the number of rows handed to the writer at once.
+	parquetBatchSize = 1024
+)
+
+// parquetNode returns the Parquet type of a column, all columns being
+// nullable.
+func parquetNode(t Type) parquet.Node {
+	switch t {
+	case TypeInteger:
+		return parquet.Optional(parquet.Int(64))
+	case TypeFloat:
+		return parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	case TypeBoolean:
+		return parquet.Optional(parquet.Leaf(parquet.BooleanType))
+	case TypeTime:
+		return parquet.Optional(parquet.Timestamp(parquet.Microsecond))
+	case TypeBytes:
+		return parquet.Optional(parquet.Leaf(parquet.ByteArrayType))
+	default:
+		return parquet.Optional(parquet.String())
+	}
+}
+
+// parquetWriter writes rows to a Snappy compressed Parquet file.
+type parquetWriter struct {
+	w       *parquet.Writer
+	columns []Column
+	// indexes gives the Parquet column index of each column, Parquet
+	// ordering them by name
+	indexes []int
+	rows    []parquet.Row
+}
+
+func newParquetWriter(w io.Writer, source Source) *parquetWriter {
+	group := make(parquet.Group, len(source.Columns))
+	for _, column := range source.Columns {
+		group[column.Name] = parquetNode(column.Type)
+	}
+	schema := parquet.NewSchema(source.Name, group)
+
+	p := &parquetWriter{
+		w:       parquet.NewWriter(w, schema, parquet.Compression(&parquet.Snappy), parquet.MaxRowsPerRowGroup(parquetRowGroupSize)),
+		columns: source.Columns,
+		indexes: make([]int, len(source.Columns)),
+	}
+	for i, column := range source.Columns {
+		for j, field := range schema.Fields() {
+			if field.Name() == column.Name {
+				p.indexes[i] = j
+			}
+		}
+	}
+
+	return p
+}
+
+// parquetValue converts a value read from the database to the Parquet type
+// of its column.
+func parquetValue(value interface{}, t Type) (parquet.Value, error) {
+	if value == nil {
+		return parquet.NullValue(), nil
+	}
+	if b, ok := value.([]byte); ok && t != TypeBytes {
+		value = string(b)
+	}
+
+	switch t {
+	case TypeInteger:
+		switch v := value.(type) {
+		case int64:
+			return parquet.Int64Value(v), nil
+		case float64:
+			return parquet.Int64Value(int64(v)), nil
+		case bool:
+			if v {
+				return parquet.Int64Value(1), nil
+			}
+			return parquet.Int64Value(0), nil
+		case string:
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return parquet.Int64Value(i), nil
+			}
+		}
+	case TypeFloat:
+		switch v := value.(type) {
+		case float64:
+			return parquet.DoubleValue(v), nil
+		case int64:
+			return parquet.DoubleValue(float64(v)), nil
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return parquet.DoubleValue(f), nil
+			}
+		}
+	case TypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return parquet.BooleanValue(v), nil
+		case int64:
+			return parquet.BooleanValue(v != 0), nil
+		}
+	case TypeTime:
+		switch v := value.(type) {
+		case time.Time:
+			return parquet.Int64Value(v.UnixMicro()), nil
+		case string:
+			if v == "" {
+				return parquet.NullValue(), nil
+			}
+			if at, err := time.Parse(time.RFC3339, v); err == nil {
+				return parquet.Int64Value(at.UnixMicro()), nil
+			}
+		}
+	case TypeBytes:
+		switch v := value.(type) {
+		case []byte:
+			return parquet.ByteArrayValue(v), nil
+		case string:
+			return parquet.ByteArrayValue([]byte(v)), nil
+		}
+	default:
+		return parquet.ByteArrayValue([]byte(csvValue(value, t))), nil
+	}
+
+	return parquet.Value{}, fmt.Errorf("unexpected value %v (%T)", value, value)
+}
+
+func (p *parquetWriter) Write(values []interface{}) error {
+	row := make(parquet.Row, len(values))
+	for i, value := range values {
+		v, err := parquetValue(value, p.columns[i].Type)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", p.columns[i].Name, err)
+		}
+		definition := 1
+		if v.IsNull() {
+			definition = 0
+		}
+		row[p.indexes[i]] = v.Level(0, definition, p.indexes[i])
+	}
+
+	p.rows = append(p.rows, row)
+	if len(p.rows) == parquetBatchSize {
+		return p.flush()
+	}
+	return nil
+}
+
+// flush hands the pending rows to the writer.
+func (p *parquetWriter) flush() error {
+	_, err := p.w.WriteRows(p.rows)
+	p.rows = p.rows[:0]
+	return err
+}
+
+func (p *parquetWriter) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	return p.w.Close()
+}
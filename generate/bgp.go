@@ -0,0 +1,152 @@
+package generate
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// BGPTemplates lists the names of the built-in BGP configuration templates.
+var BGPTemplates = []string{"bird2", "frr", "openbgpd"}
+
+// nonIdentifier matches characters not allowed in configuration identifiers.
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// Session is a BGP session with a peer over one address family.
+type Session struct {
+	database.BGPPeer
+
+	// Identifier names the session uniquely, it is safe to use as a
+	// protocol or neighbor name
+	Identifier string
+	// Address is the IP address of the peer for this session
+	Address string
+	// Family is the IP version of the session, 4 or 6
+	Family int
+	// MaxPrefix is the number of prefixes announced by the peer for the
+	// family according to PeeringDB, 0 if unknown
+	MaxPrefix int
+}
+
+// BGPData is the data given to BGP configuration templates.
+type BGPData struct {
+	LocalASN int
+	Sessions []Session
+}
+
+// NewBGPData returns the template data with one session per address of each
+// peer.
+func NewBGPData(localASN int, peers []database.BGPPeer) *BGPData {
+	data := &BGPData{LocalASN: localASN, Sessions: []Session{}}
+	used := make(map[string]bool)
+
+	for _, peer := range peers {
+		for _, s := range []Session{
+			{Address: peer.IPAddr4, Family: 4, MaxPrefix: peer.MaxPrefix4},
+			{Address: peer.IPAddr6, Family: 6, MaxPrefix: peer.MaxPrefix6},
+		} {
+			// Addresses are written as is in the configuration
+			addr, err := netip.ParseAddr(s.Address)
+			if err != nil {
+				continue
+			}
+
+			s.BGPPeer = peer
+			s.Address = addr.String()
+
+			// A suffixed identifier can be the one of another session
+			base := fmt.Sprintf("AS%d_%s_v%d", peer.ASN, identifier(peer.IXName), s.Family)
+			s.Identifier = base
+			for count := 2; used[s.Identifier]; count++ {
+				s.Identifier = fmt.Sprintf("%s_%d", base, count)
+			}
+			used[s.Identifier] = true
+
+			data.Sessions = append(data.Sessions, s)
+		}
+	}
+
+	return data
+}
+
+// identifier turns the value into a string usable as an identifier.
+func identifier(value string) string {
+	return strings.Trim(nonIdentifier.ReplaceAllString(value, "_"), "_")
+}
+
+// controlCharacters matches the characters ending a line of configuration or
+// not meant to be part of it.
+var controlCharacters = regexp.MustCompile(`[\x00-\x1f\x7f]+`)
+
+// frrDescriptionLength is the maximum length of FRRouting descriptions.
+const frrDescriptionLength = 80
+
+// line returns the value on a single line.
+func line(value string) string {
+	return strings.TrimSpace(controlCharacters.ReplaceAllString(value, " "))
+}
+
+// birdString returns the value as a BIRD string. Escape sequences are not
+// supported by all versions, double quotes are replaced by single ones and
+// backslashes are removed.
+func birdString(value string) string {
+	value = strings.ReplaceAll(line(value), `\`, "")
+	return `"` + strings.ReplaceAll(value, `"`, "'") + `"`
+}
+
+// frrText returns the value as the text ending an FRRouting command, on a
+// single line and limited to the length of descriptions.
+func frrText(value string) string {
+	value = line(value)
+	if runes := []rune(value); len(runes) > frrDescriptionLength {
+		value = strings.TrimSpace(string(runes[:frrDescriptionLength]))
+	}
+	return value
+}
+
+// openbgpdString returns the value as an OpenBGPD string. Double quotes are
+// escaped, backslashes are removed as they would escape the closing quote.
+func openbgpdString(value string) string {
+	value = strings.ReplaceAll(line(value), `\`, "")
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// LoadBGPTemplate returns the built-in template with the given name or, if
+// there is none, the template read from the file at the given path.
+func LoadBGPTemplate(nameOrPath string) (*template.Template, error) {
+	functions := template.FuncMap{
+		"identifier":     identifier,
+		"line":           line,
+		"list":           func(values ...interface{}) []interface{} { return values },
+		"birdString":     birdString,
+		"frrText":        frrText,
+		"openbgpdString": openbgpdString,
+	}
+
+	if slices.Contains(BGPTemplates, nameOrPath) {
+		return template.New(nameOrPath+".tmpl").Funcs(functions).ParseFS(builtinTemplates, "templates/"+nameOrPath+".tmpl")
+	}
+
+	if _, err := os.Stat(nameOrPath); err != nil {
+		return nil, fmt.Errorf("unknown template %q, expected one of %s or a template file: %w",
+			nameOrPath, strings.Join(BGPTemplates, ", "), err)
+	}
+	return template.New(filepath.Base(nameOrPath)).Funcs(functions).ParseFiles(nameOrPath)
+}
+
+// BGP renders the BGP configuration for the given data with the template.
+func BGP(w io.Writer, t *template.Template, data *BGPData) error {
+	return t.Execute(w, data)
+}
@@ -0,0 +1,91 @@
+package generate
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// bgpPeers returns peers with names and sets needing to be escaped.
+func bgpPeers() []database.BGPPeer {
+	return []database.BGPPeer{
+		{ASN: 64500, Name: "Example Network", IRRASSet: "AS-EXAMPLE", MaxPrefix4: 100, MaxPrefix6: 50, IPAddr4: "192.0.2.10", IPAddr6: "2001:0db8::10", IXName: "Example-IX"},
+		{ASN: 64500, Name: "Example Network", IRRASSet: "AS-EXAMPLE", MaxPrefix4: 100, MaxPrefix6: 50, IPAddr4: "192.0.2.11", IXName: "Example-IX"},
+		{ASN: 64501, Name: "Evil \"Net\\\"\nwork", IRRASSet: "AS-EVIL\nprotocol bgp injected {}", IPAddr4: "192.0.2.20", IPAddr6: "not an address", IXName: "Example-IX"},
+		{ASN: 64502, Name: "A network with a name much longer than what FRRouting accepts as the description of a neighbor", IPAddr6: "2001:db8::30", MaxPrefix6: 10, IXName: "Example-IX"},
+	}
+}
+
+func TestNewBGPData(t *testing.T) {
+	peers := bgpPeers()
+	peers = append(peers, database.BGPPeer{ASN: 64500, IPAddr4: "192.0.2.12", IXName: "Example-IX"})
+	data := NewBGPData(64496, peers)
+
+	var identifiers, addresses []string
+	for _, s := range data.Sessions {
+		identifiers = append(identifiers, s.Identifier)
+		addresses = append(addresses, s.Address)
+	}
+	want := []string{
+		"AS64500_Example_IX_v4", "AS64500_Example_IX_v6", "AS64500_Example_IX_v4_2", "AS64501_Example_IX_v4",
+		"AS64502_Example_IX_v6", "AS64500_Example_IX_v4_3",
+	}
+	if !slices.Equal(identifiers, want) {
+		t.Errorf("got identifiers %v, want %v", identifiers, want)
+	}
+	// Invalid addresses are skipped, the others are normalized
+	if want := []string{"192.0.2.10", "2001:db8::10", "192.0.2.11", "192.0.2.20", "2001:db8::30", "192.0.2.12"}; !slices.Equal(addresses, want) {
+		t.Errorf("got addresses %v, want %v", addresses, want)
+	}
+}
+
+func TestBGPTemplates(t *testing.T) {
+	data := NewBGPData(64496, bgpPeers())
+
+	for _, name := range BGPTemplates {
+		t.Run(name, func(t *testing.T) {
+			tmpl, err := LoadBGPTemplate(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buffer bytes.Buffer
+			if err = BGP(&buffer, tmpl, data); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err = os.WriteFile(golden, buffer.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buffer.String(); got != string(want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	value := "Evil \"Net\\\"\nwork\t"
+	if got, want := birdString(value), `"Evil 'Net' work"`; got != want {
+		t.Errorf("birdString: got %s, want %s", got, want)
+	}
+	if got, want := openbgpdString(value), `"Evil \"Net\" work"`; got != want {
+		t.Errorf("openbgpdString: got %s, want %s", got, want)
+	}
+	if got, want := frrText(value), `Evil "Net\" work`; got != want {
+		t.Errorf("frrText: got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,157 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"gopkg.in/yaml.v3"
+)
+
+// RSClientFormats lists the supported route server client list formats.
+var RSClientFormats = []string{"arouteserver", "json"}
+
+// RSClientLAN is an IX LAN of the route server client list.
+type RSClientLAN struct {
+	database.ExchangeLAN `yaml:",inline"`
+	// RouteServer is true if the IX LAN has a route server ASN set
+	RouteServer bool `json:"route_server" yaml:"route_server"`
+}
+
+// RSClient is a network to configure as route server client, with all its
+// addresses on the IX.
+type RSClient struct {
+	ASN        int      `json:"asn" yaml:"asn"`
+	Name       string   `json:"name" yaml:"name"`
+	IRRASSets  []string `json:"irr_as_sets" yaml:"irr_as_sets"`
+	MaxPrefix4 int      `json:"max_prefix4,omitempty" yaml:"max_prefix4,omitempty"`
+	MaxPrefix6 int      `json:"max_prefix6,omitempty" yaml:"max_prefix6,omitempty"`
+	IPAddr4    []string `json:"ipaddr4" yaml:"ipaddr4"`
+	IPAddr6    []string `json:"ipaddr6" yaml:"ipaddr6"`
+	IXLANIDs   []int    `json:"ixlan_ids" yaml:"ixlan_ids"`
+}
+
+// RSClientList is the list of route server clients of an Internet exchange.
+type RSClientList struct {
+	IXID    int           `json:"ix_id" yaml:"ix_id"`
+	IXName  string        `json:"ix_name" yaml:"ix_name"`
+	IXLANs  []RSClientLAN `json:"ixlans" yaml:"ixlans"`
+	Clients []RSClient    `json:"clients" yaml:"clients"`
+}
+
+// NewRSClientList groups the peers of the IX by ASN. Networks using the ASN
+// of one of the route servers are left out.
+func NewRSClientList(ixID int, ixName string, lans []database.ExchangeLAN, peers []database.BGPPeer) *RSClientList {
+	list := &RSClientList{IXID: ixID, IXName: ixName, IXLANs: []RSClientLAN{}, Clients: []RSClient{}}
+
+	var rsASNs []int
+	for _, lan := range lans {
+		list.IXLANs = append(list.IXLANs, RSClientLAN{ExchangeLAN: lan, RouteServer: lan.RSASN != 0})
+		if lan.RSASN != 0 {
+			rsASNs = append(rsASNs, lan.RSASN)
+		}
+	}
+
+	for _, peer := range peers {
+		if slices.Contains(rsASNs, peer.ASN) {
+			continue
+		}
+
+		// Peers are ordered by ASN, connections of the same network follow
+		// each other
+		if len(list.Clients) == 0 || list.Clients[len(list.Clients)-1].ASN != peer.ASN {
+			list.Clients = append(list.Clients, RSClient{
+				ASN:        peer.ASN,
+				Name:       peer.Name,
+				IRRASSets:  strings.FieldsFunc(peer.IRRASSet, func(r rune) bool { return r == ',' || r == ' ' }),
+				MaxPrefix4: peer.MaxPrefix4,
+				MaxPrefix6: peer.MaxPrefix6,
+				IPAddr4:    []string{},
+				IPAddr6:    []string{},
+				IXLANIDs:   []int{},
+			})
+		}
+
+		client := &list.Clients[len(list.Clients)-1]
+		if peer.IPAddr4 != "" {
+			client.IPAddr4 = append(client.IPAddr4, peer.IPAddr4)
+		}
+		if peer.IPAddr6 != "" {
+			client.IPAddr6 = append(client.IPAddr6, peer.IPAddr6)
+		}
+		if !slices.Contains(client.IXLANIDs, peer.IXLANID) {
+			client.IXLANIDs = append(client.IXLANIDs, peer.IXLANID)
+		}
+	}
+
+	return list
+}
+
+// Write writes the list in the given format.
+func (l *RSClientList) Write(w io.Writer, format string) error {
+	switch format {
+	case "arouteserver":
+		return l.writeARouteServer(w)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(l)
+	default:
+		return fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(RSClientFormats, ", "))
+	}
+}
+
+// arouteserverClient is a client as described in the clients.yml file of
+// arouteserver.
+type arouteserverClient struct {
+	ASN         int      `yaml:"asn"`
+	IP          []string `yaml:"ip"`
+	Description string   `yaml:"description,omitempty"`
+	Cfg         struct {
+		Filtering struct {
+			IRRDB struct {
+				ASSets []string `yaml:"as_sets,omitempty"`
+			} `yaml:"irrdb,omitempty"`
+			MaxPrefix struct {
+				LimitIPv4 int `yaml:"limit_ipv4,omitempty"`
+				LimitIPv6 int `yaml:"limit_ipv6,omitempty"`
+			} `yaml:"max_prefix,omitempty"`
+		} `yaml:"filtering,omitempty"`
+	} `yaml:"cfg,omitempty"`
+}
+
+// writeARouteServer writes the list as an arouteserver clients file. IX LANs
+// are listed in a header comment, route servers being flagged.
+func (l *RSClientList) writeARouteServer(w io.Writer) error {
+	fmt.Fprintf(w, "# Route server clients of %s (%d) generated by peeringdb-sync\n", l.IXName, l.IXID)
+	for _, lan := range l.IXLANs {
+		line := fmt.Sprintf("# IX LAN %d", lan.ID)
+		if lan.Name != "" {
+			line += " " + lan.Name
+		}
+		if lan.RouteServer {
+			line += fmt.Sprintf(" [route server AS%d]", lan.RSASN)
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	clients := make([]arouteserverClient, 0, len(l.Clients))
+	for _, c := range l.Clients {
+		client := arouteserverClient{ASN: c.ASN, Description: c.Name}
+		client.IP = append(append(client.IP, c.IPAddr4...), c.IPAddr6...)
+		client.Cfg.Filtering.IRRDB.ASSets = c.IRRASSets
+		client.Cfg.Filtering.MaxPrefix.LimitIPv4 = c.MaxPrefix4
+		client.Cfg.Filtering.MaxPrefix.LimitIPv6 = c.MaxPrefix6
+		clients = append(clients, client)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string][]arouteserverClient{"clients": clients}); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,107 @@
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+)
+
+// rsClientList returns the client list of an IX with a route server on its
+// first IX LAN.
+func rsClientList() *RSClientList {
+	lans := []database.ExchangeLAN{{ID: 30, Name: "Main", RSASN: 64999}, {ID: 31}}
+	peers := []database.BGPPeer{
+		{ASN: 64500, Name: "Example Network", IRRASSet: "AS-EXAMPLE, AS-EXAMPLE-V6", MaxPrefix4: 100, MaxPrefix6: 50, IPAddr4: "192.0.2.10", IPAddr6: "2001:db8::10", IXLANID: 30},
+		{ASN: 64500, Name: "Example Network", IRRASSet: "AS-EXAMPLE, AS-EXAMPLE-V6", MaxPrefix4: 100, MaxPrefix6: 50, IPAddr4: "192.0.2.11", IXLANID: 30},
+		{ASN: 64500, Name: "Example Network", IRRASSet: "AS-EXAMPLE, AS-EXAMPLE-V6", MaxPrefix4: 100, MaxPrefix6: 50, IPAddr6: "2001:db8:1::10", IXLANID: 31},
+		{ASN: 64501, Name: "Other Network", IPAddr4: "192.0.2.20", IXLANID: 30},
+		// The route server itself
+		{ASN: 64999, Name: "Example-IX Route Servers", IPAddr4: "192.0.2.254", IXLANID: 30},
+	}
+	return NewRSClientList(20, "Example-IX", lans, peers)
+}
+
+func TestNewRSClientList(t *testing.T) {
+	list := rsClientList()
+
+	wantLANs := []RSClientLAN{
+		{ExchangeLAN: database.ExchangeLAN{ID: 30, Name: "Main", RSASN: 64999}, RouteServer: true},
+		{ExchangeLAN: database.ExchangeLAN{ID: 31}},
+	}
+	if !reflect.DeepEqual(list.IXLANs, wantLANs) {
+		t.Errorf("got IX LANs %+v, want %+v", list.IXLANs, wantLANs)
+	}
+
+	wantClients := []RSClient{
+		{
+			ASN: 64500, Name: "Example Network", IRRASSets: []string{"AS-EXAMPLE", "AS-EXAMPLE-V6"}, MaxPrefix4: 100, MaxPrefix6: 50,
+			IPAddr4: []string{"192.0.2.10", "192.0.2.11"}, IPAddr6: []string{"2001:db8::10", "2001:db8:1::10"}, IXLANIDs: []int{30, 31},
+		},
+		{
+			ASN: 64501, Name: "Other Network", IRRASSets: []string{},
+			IPAddr4: []string{"192.0.2.20"}, IPAddr6: []string{}, IXLANIDs: []int{30},
+		},
+	}
+	if !reflect.DeepEqual(list.Clients, wantClients) {
+		t.Errorf("got clients %+v, want %+v", list.Clients, wantClients)
+	}
+
+	if list = NewRSClientList(21, "Empty-IX", nil, nil); list.IXLANs == nil || list.Clients == nil {
+		t.Errorf("empty list has nil slices: %+v", list)
+	}
+}
+
+func TestRSClientListWrite(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := rsClientList().Write(&buffer, "arouteserver"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# Route server clients of Example-IX (20) generated by peeringdb-sync
+# IX LAN 30 Main [route server AS64999]
+# IX LAN 31
+clients:
+  - asn: 64500
+    ip:
+      - 192.0.2.10
+      - 192.0.2.11
+      - 2001:db8::10
+      - 2001:db8:1::10
+    description: Example Network
+    cfg:
+      filtering:
+        irrdb:
+          as_sets:
+            - AS-EXAMPLE
+            - AS-EXAMPLE-V6
+        max_prefix:
+          limit_ipv4: 100
+          limit_ipv6: 50
+  - asn: 64501
+    ip:
+      - 192.0.2.20
+    description: Other Network
+`
+	if got := buffer.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	buffer.Reset()
+	if err := rsClientList().Write(&buffer, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var decoded RSClientList
+	if err := json.Unmarshal(buffer.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, rsClientList()) {
+		t.Errorf("got %+v from JSON, want %+v", decoded, rsClientList())
+	}
+
+	if err := rsClientList().Write(&buffer, "bird"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
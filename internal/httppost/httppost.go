@@ -0,0 +1,103 @@
+// Package httppost posts JSON documents to HTTP endpoints, retrying on
+// transient failures. It is shared by the notifications and the change data
+// capture sinks.
+package httppost
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// permanentError is an error that will not go away by retrying.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Poster posts JSON documents. Network errors, 429 and 5xx statuses are
+// retried with an exponential backoff, other statuses fail at once.
+type Poster struct {
+	client     *http.Client
+	retries    int
+	retryDelay time.Duration
+}
+
+// NewPoster returns a poster retrying the given number of times, waiting
+// retryDelay before the first retry and twice as long before each following
+// one. Each request is limited by the timeout.
+func NewPoster(retries int, retryDelay, timeout time.Duration) *Poster {
+	return &Poster{
+		client:     &http.Client{Timeout: timeout},
+		retries:    retries,
+		retryDelay: retryDelay,
+	}
+}
+
+// Post sends the body to the URL with the given headers. It stops retrying
+// once the context is done.
+func (p *Poster) Post(ctx context.Context, url string, headers map[string]string, body []byte) error {
+	var err error
+	delay := p.retryDelay
+
+	for attempt := 0; attempt <= p.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			delay *= 2
+		}
+
+		err = p.postOnce(ctx, url, headers, body)
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return err
+}
+
+func (p *Poster) postOnce(ctx context.Context, url string, headers map[string]string, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return nil
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return fmt.Errorf("unexpected status %s", response.Status)
+	default:
+		return &permanentError{fmt.Errorf("unexpected status %s", response.Status)}
+	}
+}
+
+// Close releases the idle connections.
+func (p *Poster) Close() {
+	p.client.CloseIdleConnections()
+}
@@ -0,0 +1,73 @@
+package httppost
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newServer returns a server answering with the given statuses in turn, then
+// with 200, and the number of requests it received.
+func newServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestPost(t *testing.T) {
+	headers := map[string]string{"X-Token": "secret"}
+	for _, tc := range []struct {
+		name     string
+		statuses []int
+		retries  int
+		err      string
+		requests int32
+	}{
+		{name: "accepted", requests: 1},
+		{name: "transient", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, retries: 2, requests: 3},
+		{name: "too many failures", statuses: []int{500, 502, 504}, retries: 2, err: "504", requests: 3},
+		{name: "permanent", statuses: []int{http.StatusNotFound}, retries: 2, err: "404", requests: 1},
+	} {
+		server, requests := newServer(t, tc.statuses...)
+		p := NewPoster(tc.retries, time.Millisecond, time.Second)
+		err := p.Post(context.Background(), server.URL, headers, []byte("{}"))
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+		}
+		if n := requests.Load(); n != tc.requests {
+			t.Errorf("%s: got %d requests, want %d", tc.name, n, tc.requests)
+		}
+		p.Close()
+	}
+
+	// Invalid URLs are not retried
+	if err := NewPoster(3, time.Hour, time.Second).Post(context.Background(), "://invalid", nil, nil); err == nil {
+		t.Error("invalid URL accepted")
+	}
+
+	// Retries stop with the context
+	server, requests := newServer(t, 500, 500)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := NewPoster(3, time.Hour, time.Second).Post(ctx, server.URL, headers, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want the context to be done", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
@@ -0,0 +1,50 @@
+// Package testutil provides helpers shared by the tests of several packages.
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+	"github.com/gmazoyer/peeringdb-sync/peeringdbtest"
+	"github.com/gmazoyer/peeringdb-sync/progress"
+)
+
+// NewDatabase returns a server holding the fixtures of peeringdbtest and an
+// empty database with the current schema. Both are closed at the end of the
+// test.
+func NewDatabase(t *testing.T) (*peeringdbtest.Server, *sql.DB) {
+	t.Helper()
+
+	server, err := peeringdbtest.NewServerWithFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	db, err := database.GetDatabaseConnection(filepath.Join(t.TempDir(), "peeringdb.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err = database.Migrate(db, database.GetSchema()); err != nil {
+		t.Fatal(err)
+	}
+
+	return server, db
+}
+
+// SynchronizeNetworks runs the synchronization of the organizations then of
+// the networks.
+func SynchronizeNetworks(t *testing.T, s *database.Synchronization) {
+	t.Helper()
+
+	if err := s.SynchronizeOrganizations(context.Background(), progress.Discard.Task("org")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SynchronizeNetworks(context.Background(), progress.Discard.Task("net")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "peeringdb_sync"
+
+var (
+	// Registry holds all the metrics of the tool. A dedicated registry is used
+	// to avoid exposing the Go runtime metrics in textfile outputs.
+	Registry = prometheus.NewRegistry()
+
+	// RecordsFetched counts the records received from the API per table.
+	RecordsFetched = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_fetched_total",
+		Help:      "Number of records fetched from the PeeringDB API.",
+	}, []string{"table"})
+	// RecordsInserted counts the records inserted in the database per table.
+	RecordsInserted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_inserted_total",
+		Help:      "Number of records inserted in the local database.",
+	}, []string{"table"})
+	// RecordsUpdated counts the records updated in the database per table.
+	RecordsUpdated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_updated_total",
+		Help:      "Number of records updated in the local database.",
+	}, []string{"table"})
+	// RecordsDeleted counts the records removed from the database per table.
+	RecordsDeleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_deleted_total",
+		Help:      "Number of records deleted from the local database.",
+	}, []string{"table"})
+
+	// APIRequestDuration observes the latency of the API requests per table.
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Duration of the requests made to the PeeringDB API.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"table"})
+	// APIRequestErrors counts the failed API requests per table.
+	APIRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_request_errors_total",
+		Help:      "Number of requests to the PeeringDB API that failed.",
+	}, []string{"table"})
+
+	// TaskDuration records how long each synchronization task took.
+	TaskDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "task_duration_seconds",
+		Help:      "Duration of the last run of each synchronization task.",
+	}, []string{"task"})
+	// SyncDuration records how long the last synchronization took.
+	SyncDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "duration_seconds",
+		Help:      "Duration of the last synchronization.",
+	})
+	// LastSuccess records when the last successful synchronization ended.
+	LastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix timestamp of the end of the last successful synchronization.",
+	})
+
+	// TableRows reports the number of rows of each table.
+	TableRows = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "table_rows",
+		Help:      "Number of rows in each table of the local database.",
+	}, []string{"table"})
+)
+
+func init() {
+	Registry.MustRegister(
+		RecordsFetched, RecordsInserted, RecordsUpdated, RecordsDeleted,
+		APIRequestDuration, APIRequestErrors,
+		TaskDuration, SyncDuration, LastSuccess,
+		TableRows,
+	)
+}
+
+// Handler returns an HTTP handler exposing the metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve exposes the metrics on the /metrics path of the given address. It
+// blocks until the server fails.
+func Serve(address string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return http.ListenAndServe(address, mux)
+}
+
+// WriteTextfile writes the metrics in the given file using the format
+// expected by the textfile collector of the Prometheus node exporter. The file
+// is written atomically.
+func WriteTextfile(filename string) error {
+	return prometheus.WriteToTextfile(filename, Registry)
+}
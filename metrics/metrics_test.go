@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	// Vectors are only gathered once they have a child
+	RecordsFetched.WithLabelValues("test").Add(1)
+	RecordsInserted.WithLabelValues("test").Add(1)
+	RecordsUpdated.WithLabelValues("test").Add(1)
+	RecordsDeleted.WithLabelValues("test").Add(1)
+	APIRequestDuration.WithLabelValues("test").Observe(0.3)
+	APIRequestErrors.WithLabelValues("test").Inc()
+	TaskDuration.WithLabelValues("test").Set(1)
+	TableRows.WithLabelValues("test").Set(1)
+
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+
+	// No runtime metrics, only the ones of the tool
+	want := []string{
+		"peeringdb_sync_api_request_duration_seconds",
+		"peeringdb_sync_api_request_errors_total",
+		"peeringdb_sync_duration_seconds",
+		"peeringdb_sync_last_success_timestamp_seconds",
+		"peeringdb_sync_records_deleted_total",
+		"peeringdb_sync_records_fetched_total",
+		"peeringdb_sync_records_inserted_total",
+		"peeringdb_sync_records_updated_total",
+		"peeringdb_sync_table_rows",
+		"peeringdb_sync_task_duration_seconds",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("got metrics %v, want %v", names, want)
+	}
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if body := recorder.Body.String(); !strings.Contains(body, `peeringdb_sync_records_fetched_total{table="test"} 1`) {
+		t.Errorf("metric missing from the handler output:\n%s", body)
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "peeringdb.prom")
+
+	// An existing file is replaced
+	if err := os.WriteFile(filename, []byte("stale\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	SyncDuration.Set(12.5)
+	TableRows.WithLabelValues("peeringdb_network").Set(3)
+	if err := WriteTextfile(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# HELP peeringdb_sync_duration_seconds Duration of the last synchronization.\n" +
+			"# TYPE peeringdb_sync_duration_seconds gauge\n" +
+			"peeringdb_sync_duration_seconds 12.5\n",
+		"# TYPE peeringdb_sync_table_rows gauge\n",
+		`peeringdb_sync_table_rows{table="peeringdb_network"} 3` + "\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("missing %q in:\n%s", want, content)
+		}
+	}
+	if strings.Contains(string(content), "stale") {
+		t.Errorf("previous content kept:\n%s", content)
+	}
+
+	// The temporary file has been renamed
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "peeringdb.prom" {
+		t.Errorf("got files %v, want only peeringdb.prom", entries)
+	}
+	if info, err := os.Stat(filename); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("got %v (%v), want a file readable by the node exporter", info, err)
+	}
+
+	if err = WriteTextfile(filepath.Join(dir, "missing", "peeringdb.prom")); err == nil {
+		t.Error("file written to a missing directory")
+	}
+}
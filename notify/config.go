@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultRetries    = 3
+	defaultRetryDelay = time.Second
+	defaultTimeout    = 10 * time.Second
+)
+
+// objectTables maps the PeeringDB object types that can be watched to the
+// database tables holding them.
+var objectTables = map[string]string{
+	"net":      "peeringdb_network",
+	"netixlan": "peeringdb_network_ixlan",
+	"netfac":   "peeringdb_network_facility",
+	"poc":      "peeringdb_network_contact",
+}
+
+// Watch describes what to keep an eye on. Empty lists of ASNs and objects
+// match everything, an empty list of fields matches any modification.
+type Watch struct {
+	ASNs    []int    `yaml:"asns,omitempty"`
+	Objects []string `yaml:"objects,omitempty"`
+	Fields  []string `yaml:"fields,omitempty"`
+}
+
+// Webhook is an HTTP endpoint receiving notifications as JSON payloads.
+type Webhook struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// Config holds the watches to evaluate and the webhooks to notify.
+type Config struct {
+	Watches    []Watch       `yaml:"watches"`
+	Webhooks   []Webhook     `yaml:"webhooks"`
+	Retries    int           `yaml:"retries"`
+	RetryDelay time.Duration `yaml:"retry_delay"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+// DefaultConfig returns a configuration without any watch nor webhook, using
+// the default retry policy.
+func DefaultConfig() *Config {
+	return &Config{
+		Retries:    defaultRetries,
+		RetryDelay: defaultRetryDelay,
+		Timeout:    defaultTimeout,
+	}
+}
+
+// LoadConfig reads the notifications configuration from the given YAML file.
+func LoadConfig(filename string) (*Config, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultConfig()
+	if err = yaml.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// UnmarshalYAML decodes and validates the configuration. Values missing from
+// the YAML document keep their defaults.
+func (c *Config) UnmarshalYAML(value *yaml.Node) error {
+	type plain Config
+	config := plain(*DefaultConfig())
+	if err := value.Decode(&config); err != nil {
+		return err
+	}
+
+	*c = Config(config)
+	return c.Validate()
+}
+
+// Validate checks that the configuration is usable.
+func (c *Config) Validate() error {
+	for _, watch := range c.Watches {
+		for _, object := range watch.Objects {
+			if _, ok := objectTables[object]; !ok {
+				return fmt.Errorf("unsupported object type %q", object)
+			}
+		}
+	}
+
+	for _, webhook := range c.Webhooks {
+		if webhook.URL == "" {
+			return fmt.Errorf("webhook without URL")
+		}
+	}
+
+	if c.Retries < 0 {
+		return fmt.Errorf("negative number of retries")
+	}
+
+	return nil
+}
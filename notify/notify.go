@@ -0,0 +1,249 @@
+package notify
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+)
+
+// FieldChange holds the previous and the current value of a field.
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// Event is a change matching at least one of the configured watches.
+type Event struct {
+	Object    string                 `json:"object"`
+	ID        int                    `json:"id"`
+	ASN       int                    `json:"asn"`
+	Operation database.Operation     `json:"operation"`
+	Fields    map[string]FieldChange `json:"fields,omitempty"`
+}
+
+// String returns a human readable description of the event.
+func (e *Event) String() string {
+	description := fmt.Sprintf("AS%d: %s %d %s", e.ASN, e.Object, e.ID, operationVerbs[e.Operation])
+	if len(e.Fields) > 0 {
+		fields := make([]string, 0, len(e.Fields))
+		for name, field := range e.Fields {
+			fields = append(fields, fmt.Sprintf("%s (%v -> %v)", name, field.Old, field.New))
+		}
+		sort.Strings(fields)
+		description += ": " + strings.Join(fields, ", ")
+	}
+	return description
+}
+
+var operationVerbs = map[database.Operation]string{
+	database.OperationInsert: "added",
+	database.OperationUpdate: "updated",
+	database.OperationDelete: "deleted",
+}
+
+// Notifier evaluates the changes made during a synchronization against the
+// configured watches and sends the matching ones to the webhooks.
+type Notifier struct {
+	config *Config
+	sender *sender
+
+	mutex sync.Mutex
+	// networks maps network IDs to AS numbers, it is used to find the AS
+	// number of objects only referring to a network
+	networks map[int]int
+	events   []Event
+}
+
+// NewNotifier returns a notifier for the given configuration. The database
+// is used to know which networks are already present locally, so it must be
+// called before starting the synchronization.
+func NewNotifier(config *Config, db *sql.DB) (*Notifier, error) {
+	n := &Notifier{
+		config:   config,
+		sender:   newSender(config),
+		networks: make(map[int]int),
+	}
+
+	rows, err := db.Query("SELECT id, asn FROM peeringdb_network")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, asn int
+		if err = rows.Scan(&id, &asn); err != nil {
+			return nil, err
+		}
+		if n.isWatchedASN(asn) {
+			n.networks[id] = asn
+		}
+	}
+
+	return n, rows.Err()
+}
+
+// isWatchedASN returns true if at least one watch covers the given AS number.
+func (n *Notifier) isWatchedASN(asn int) bool {
+	for _, watch := range n.config.Watches {
+		if len(watch.ASNs) == 0 || slices.Contains(watch.ASNs, asn) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tables returns the tables holding the objects watched by at least one
+// watch. The networks are always part of them, the AS number of the other
+// objects being found through them.
+func (n *Notifier) Tables() []string {
+	tables := []string{objectTables["net"]}
+	for _, watch := range n.config.Watches {
+		for object, table := range objectTables {
+			if len(watch.Objects) > 0 && !slices.Contains(watch.Objects, object) {
+				continue
+			}
+			if !slices.Contains(tables, table) {
+				tables = append(tables, table)
+			}
+		}
+	}
+	sort.Strings(tables)
+
+	return tables
+}
+
+// Observe evaluates a change against the watches and keeps it if it matches.
+// It is meant to be used as the change handler of a synchronization.
+func (n *Notifier) Observe(change database.Change) {
+	object := objectFromTable(change.Table)
+	if object == "" {
+		return
+	}
+
+	row := change.New
+	if row == nil {
+		row = change.Old
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	asn, ok := n.asnForRow(row)
+	if !ok {
+		return
+	}
+
+	// Networks are handled before their dependents, keep track of new ones
+	if object == "net" {
+		n.networks[change.ID] = asn
+	}
+
+	changed := change.ChangedFields()
+	fields := make(map[string]bool)
+	matched := false
+	for _, watch := range n.config.Watches {
+		if len(watch.ASNs) > 0 && !slices.Contains(watch.ASNs, asn) {
+			continue
+		}
+		if len(watch.Objects) > 0 && !slices.Contains(watch.Objects, object) {
+			continue
+		}
+
+		// Inserts and deletions always match, updates only if a watched field
+		// has been modified
+		if change.Operation != database.OperationUpdate || len(watch.Fields) == 0 {
+			matched = true
+			if change.Operation == database.OperationUpdate {
+				for _, field := range changed {
+					fields[field] = true
+				}
+			}
+			continue
+		}
+
+		for _, field := range watch.Fields {
+			if slices.Contains(changed, field) {
+				matched = true
+				fields[field] = true
+			}
+		}
+	}
+
+	if !matched {
+		return
+	}
+
+	event := Event{Object: object, ID: change.ID, ASN: asn, Operation: change.Operation}
+	if len(fields) > 0 {
+		event.Fields = make(map[string]FieldChange, len(fields))
+		for field := range fields {
+			event.Fields[field] = FieldChange{Old: change.Old[field], New: change.New[field]}
+		}
+	}
+
+	n.events = append(n.events, event)
+}
+
+// asnForRow returns the AS number the row relates to. The second returned
+// value is false if it cannot be found or is not watched.
+func (n *Notifier) asnForRow(row map[string]interface{}) (int, bool) {
+	if value, ok := row["asn"].(int64); ok {
+		return int(value), n.isWatchedASN(int(value))
+	}
+
+	if value, ok := row["net_id"].(int64); ok {
+		asn, found := n.networks[int(value)]
+		return asn, found
+	}
+
+	return 0, false
+}
+
+// Events returns the events collected so far.
+func (n *Notifier) Events() []Event {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return append([]Event(nil), n.events...)
+}
+
+// Flush sends the collected events to all webhooks, then forgets about them.
+// Nothing is sent if no event has been collected. The returned error is
+// non-nil if at least one webhook could not be notified.
+func (n *Notifier) Flush() error {
+	n.mutex.Lock()
+	events := n.events
+	n.events = nil
+	n.mutex.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].ASN != events[j].ASN {
+			return events[i].ASN < events[j].ASN
+		}
+		if events[i].Object != events[j].Object {
+			return events[i].Object < events[j].Object
+		}
+		return events[i].ID < events[j].ID
+	})
+
+	return n.sender.send(newPayload(events))
+}
+
+func objectFromTable(table string) string {
+	for object, name := range objectTables {
+		if name == table {
+			return object
+		}
+	}
+	return ""
+}
@@ -0,0 +1,211 @@
+package notify
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+)
+
+// newNotifier returns a notifier for the configuration, knowing no network.
+func newNotifier(t *testing.T, config *Config) *Notifier {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "peeringdb.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err = database.Migrate(db, database.GetSchema()); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewNotifier(config, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// webhook is a webhook answering with the given statuses in turn, then with
+// 200, and recording the requests it receives.
+type webhook struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	times    []time.Time
+	payloads []Payload
+}
+
+func newWebhook(t *testing.T, statuses ...int) *webhook {
+	w := &webhook{statuses: statuses}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		w.requests = append(w.requests, r)
+		w.times = append(w.times, time.Now())
+		var payload Payload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.payloads = append(w.payloads, payload)
+
+		if len(w.statuses) > 0 {
+			rw.WriteHeader(w.statuses[0])
+			w.statuses = w.statuses[1:]
+		}
+	}))
+	t.Cleanup(w.Close)
+
+	return w
+}
+
+func network(asn int, name string, prefixes int) map[string]interface{} {
+	return map[string]interface{}{"asn": int64(asn), "name": name, "info_prefixes4": int64(prefixes)}
+}
+
+func connection(netID int) map[string]interface{} {
+	return map[string]interface{}{"net_id": int64(netID), "speed": int64(10000)}
+}
+
+func TestObserve(t *testing.T) {
+	n := newNotifier(t, &Config{Watches: []Watch{
+		{ASNs: []int{64500}, Fields: []string{"name"}},
+		{ASNs: []int{64501}, Objects: []string{"netixlan"}},
+	}})
+
+	for _, change := range []database.Change{
+		{Table: "peeringdb_network", ID: 10, Operation: database.OperationInsert, New: network(64500, "Example", 100)},
+		// Not watched for networks but for their connections
+		{Table: "peeringdb_network", ID: 11, Operation: database.OperationInsert, New: network(64501, "Second", 10)},
+		{Table: "peeringdb_network", ID: 12, Operation: database.OperationInsert, New: network(64502, "Third", 10)},
+		// Field not watched
+		{Table: "peeringdb_network", ID: 10, Operation: database.OperationUpdate, Old: network(64500, "Example", 100), New: network(64500, "Example", 200)},
+		{Table: "peeringdb_network", ID: 10, Operation: database.OperationUpdate, Old: network(64500, "Example", 200), New: network(64500, "Renamed", 300)},
+		{Table: "peeringdb_network_ixlan", ID: 40, Operation: database.OperationInsert, New: connection(11)},
+		{Table: "peeringdb_network_ixlan", ID: 41, Operation: database.OperationInsert, New: connection(12)},
+		{Table: "peeringdb_network_contact", ID: 110, Operation: database.OperationDelete, Old: connection(11)},
+		{Table: "peeringdb_ix", ID: 20, Operation: database.OperationInsert, New: map[string]interface{}{"name": "Example-IX"}},
+	} {
+		n.Observe(change)
+	}
+
+	want := []Event{
+		{Object: "net", ID: 10, ASN: 64500, Operation: database.OperationInsert},
+		{Object: "net", ID: 10, ASN: 64500, Operation: database.OperationUpdate, Fields: map[string]FieldChange{"name": {Old: "Example", New: "Renamed"}}},
+		{Object: "netixlan", ID: 40, ASN: 64501, Operation: database.OperationInsert},
+	}
+	if events := n.Events(); !reflect.DeepEqual(events, want) {
+		t.Errorf("got events %+v, want %+v", events, want)
+	}
+
+	// Watches without fields report all the modified ones
+	n = newNotifier(t, &Config{Watches: []Watch{{}}})
+	n.Observe(database.Change{Table: "peeringdb_network", ID: 10, Operation: database.OperationUpdate, Old: network(64500, "Example", 100), New: network(64500, "Renamed", 200)})
+	if events := n.Events(); len(events) != 1 || len(events[0].Fields) != 2 {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestTables(t *testing.T) {
+	n := newNotifier(t, &Config{Watches: []Watch{{Objects: []string{"poc"}}}})
+	if tables := n.Tables(); !slices.Equal(tables, []string{"peeringdb_network", "peeringdb_network_contact"}) {
+		t.Errorf("got tables %v", tables)
+	}
+
+	n = newNotifier(t, &Config{Watches: []Watch{{Objects: []string{"poc"}}, {ASNs: []int{64500}}}})
+	if tables := n.Tables(); len(tables) != len(objectTables) {
+		t.Errorf("got tables %v, want all of them", tables)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	hook := newWebhook(t)
+	config := DefaultConfig()
+	config.Watches = []Watch{{}}
+	config.Webhooks = []Webhook{{URL: hook.URL, Headers: map[string]string{"Authorization": "Bearer secret"}}}
+	n := newNotifier(t, config)
+
+	// Nothing to send
+	if err := n.Flush(); err != nil || len(hook.requests) != 0 {
+		t.Fatalf("got %d requests (%v), want none", len(hook.requests), err)
+	}
+
+	n.Observe(database.Change{Table: "peeringdb_network", ID: 11, Operation: database.OperationInsert, New: network(64501, "Second", 10)})
+	n.Observe(database.Change{Table: "peeringdb_network", ID: 10, Operation: database.OperationDelete, Old: network(64500, "Example", 100)})
+	if err := n.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// All the events are sent at once, sorted by AS number
+	if len(hook.payloads) != 1 {
+		t.Fatalf("got %d requests, want 1", len(hook.payloads))
+	}
+	if header := hook.requests[0].Header.Get("Authorization"); header != "Bearer secret" {
+		t.Errorf("got Authorization header %q", header)
+	}
+	payload := hook.payloads[0]
+	if len(payload.Events) != 2 || payload.Events[0].ASN != 64500 || payload.Events[1].ASN != 64501 {
+		t.Errorf("unexpected events %+v", payload.Events)
+	}
+	lines := strings.Split(payload.Text, "\n")
+	if len(lines) != 3 || lines[1] != "• AS64500: net 10 deleted" {
+		t.Errorf("unexpected text %q", payload.Text)
+	}
+
+	// Events are forgotten once sent
+	if err := n.Flush(); err != nil || len(hook.payloads) != 1 {
+		t.Errorf("got %d requests (%v), want 1", len(hook.payloads), err)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	payload := newPayload([]Event{{Object: "net", ID: 10, ASN: 64500, Operation: database.OperationInsert}})
+	delay := 10 * time.Millisecond
+
+	// Server errors are retried with an exponential backoff
+	hook := newWebhook(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+	s := newSender(&Config{Webhooks: []Webhook{{URL: hook.URL}}, Retries: 3, RetryDelay: delay, Timeout: time.Second})
+	if err := s.send(payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(hook.times) != 3 {
+		t.Fatalf("got %d attempts, want 3", len(hook.times))
+	}
+	if first, second := hook.times[1].Sub(hook.times[0]), hook.times[2].Sub(hook.times[1]); first < delay || second < 2*delay {
+		t.Errorf("retried after %s then %s, want at least %s and %s", first, second, delay, 2*delay)
+	}
+
+	// Retries are limited
+	hook = newWebhook(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	s = newSender(&Config{Webhooks: []Webhook{{URL: hook.URL}}, Retries: 2, RetryDelay: time.Millisecond, Timeout: time.Second})
+	if err := s.send(payload); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("got error %v, want an internal server error", err)
+	}
+	if len(hook.times) != 3 {
+		t.Errorf("got %d attempts, want 3", len(hook.times))
+	}
+
+	// Client errors are not retried, the other webhooks are still notified
+	rejecting, accepting := newWebhook(t, http.StatusBadRequest), newWebhook(t)
+	s = newSender(&Config{Webhooks: []Webhook{{URL: rejecting.URL}, {URL: accepting.URL}}, Retries: 3, RetryDelay: time.Millisecond, Timeout: time.Second})
+	if err := s.send(payload); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("got error %v, want a bad request", err)
+	}
+	if len(rejecting.times) != 1 || len(accepting.times) != 1 {
+		t.Errorf("got %d and %d attempts, want 1 each", len(rejecting.times), len(accepting.times))
+	}
+}
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gmazoyer/peeringdb-sync/internal/httppost"
+)
+
+// Payload is the JSON document posted to webhooks. The text field makes it
+// directly usable with Slack and Mattermost incoming webhooks.
+type Payload struct {
+	Text   string  `json:"text"`
+	Events []Event `json:"events"`
+}
+
+func newPayload(events []Event) *Payload {
+	lines := make([]string, 0, len(events)+1)
+	lines = append(lines, fmt.Sprintf("PeeringDB: %d watched object(s) changed", len(events)))
+	for _, event := range events {
+		lines = append(lines, "• "+event.String())
+	}
+
+	return &Payload{Text: strings.Join(lines, "\n"), Events: events}
+}
+
+// sender posts payloads to webhooks, retrying on transient failures.
+type sender struct {
+	poster   *httppost.Poster
+	webhooks []Webhook
+}
+
+func newSender(config *Config) *sender {
+	return &sender{
+		poster:   httppost.NewPoster(config.Retries, config.RetryDelay, config.Timeout),
+		webhooks: config.Webhooks,
+	}
+}
+
+// send posts the payload to every webhook. All webhooks are tried even if one
+// of them fails.
+func (s *sender) send(payload *Payload) error {
+	// Keep the text readable, it is meant to be displayed as is
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(payload); err != nil {
+		return err
+	}
+	body := buffer.Bytes()
+
+	var errs []error
+	for _, w := range s.webhooks {
+		if err := s.poster.Post(context.Background(), w.URL, w.Headers, body); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", w.URL, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
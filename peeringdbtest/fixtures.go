@@ -0,0 +1,32 @@
+package peeringdbtest
+
+import (
+	"embed"
+	"encoding/json"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// Fixtures returns a small but consistent set of objects of every type, by
+// type. Every field has a distinct value so that mixing up two fields can be
+// detected.
+func Fixtures() (map[string][]interface{}, error) {
+	objects := make(map[string][]interface{})
+	for _, namespace := range Namespaces {
+		data, err := fixtures.ReadFile("fixtures/" + namespace + ".json")
+		if err != nil {
+			return nil, err
+		}
+
+		var list []Object
+		if err = json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		for _, o := range list {
+			objects[namespace] = append(objects[namespace], o)
+		}
+	}
+
+	return objects, nil
+}
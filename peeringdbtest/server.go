@@ -0,0 +1,351 @@
+// Package peeringdbtest provides an in-memory fake of the PeeringDB API to
+// test code synchronizing or querying it without reaching the real service.
+//
+// The server holds objects of every type as JSON documents. It honours the
+// since, limit and skip parameters and filters on any other field given as
+// a parameter. Objects can be updated or deleted between requests, and
+// requests can be made to fail, to simulate the evolution of PeeringDB.
+package peeringdbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gmazoyer/peeringdb"
+)
+
+// Namespaces lists the types of objects served, an object only depending on
+// objects of the types listed before its own.
+var Namespaces = []string{
+	"org", "campus", "fac", "carrier", "carrierfac", "net", "ix", "ixfac", "ixlan", "ixpfx", "poc", "netfac",
+	"netixlan",
+}
+
+// Object is a PeeringDB object as decoded from JSON.
+type Object map[string]interface{}
+
+// ID returns the ID of the object, 0 if it has none.
+func (o Object) ID() int {
+	id, _ := o["id"].(float64)
+	return int(id)
+}
+
+// Updated returns the last update time of the object, the zero time if it
+// has none.
+func (o Object) Updated() time.Time {
+	value, _ := o["updated"].(string)
+	updated, _ := time.Parse(time.RFC3339, value)
+	return updated
+}
+
+// clone returns a copy of the object that can be modified independently,
+// nested values are copied by going through JSON.
+func (o Object) clone() Object {
+	data, _ := json.Marshal(o)
+	var c Object
+	json.Unmarshal(data, &c)
+	return c
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Namespace string
+	Query     url.Values
+}
+
+// Since returns the value of the since parameter of the request, 0 if there
+// is none.
+func (r Request) Since() int64 {
+	since, _ := strconv.ParseInt(r.Query.Get("since"), 10, 64)
+	return since
+}
+
+// failure is a response to give instead of the objects, once a number of
+// requests have succeeded.
+type failure struct {
+	status int
+	times  int
+	after  int
+}
+
+// Server is a fake PeeringDB API served over HTTP on the loopback interface.
+type Server struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	objects  map[string]map[int]Object
+	failures map[string]*failure
+	requests []Request
+}
+
+// NewServer starts a server without any object, it must be closed once done.
+func NewServer() *Server {
+	s := &Server{
+		objects:  make(map[string]map[int]Object),
+		failures: make(map[string]*failure),
+	}
+	for _, namespace := range Namespaces {
+		s.objects[namespace] = make(map[int]Object)
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// NewServerWithFixtures starts a server holding the objects of Fixtures.
+func NewServerWithFixtures() (*Server, error) {
+	fixtures, err := Fixtures()
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewServer()
+	for namespace, objects := range fixtures {
+		if err = s.Put(namespace, objects...); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// URL returns the base URL of the API, ending with a slash.
+func (s *Server) URL() string {
+	return s.server.URL + "/api/"
+}
+
+// API returns a client of the PeeringDB API package using the server.
+func (s *Server) API() *peeringdb.API {
+	return peeringdb.NewAPIFromURL(s.URL())
+}
+
+// Put adds objects of the given type, replacing the ones with the same IDs.
+// Objects can be values of the PeeringDB API package, Object values or
+// anything encoded as a JSON object having an ID.
+func (s *Server) Put(namespace string, objects ...interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.objects[namespace]
+	if !ok {
+		return fmt.Errorf("unknown object type %q", namespace)
+	}
+
+	for _, object := range objects {
+		data, err := json.Marshal(object)
+		if err != nil {
+			return err
+		}
+		var o Object
+		if err = json.Unmarshal(data, &o); err != nil {
+			return err
+		}
+		if o.ID() == 0 {
+			return fmt.Errorf("%s object without an ID", namespace)
+		}
+		stored[o.ID()] = o
+	}
+
+	return nil
+}
+
+// Object returns a copy of the object of the given type and ID, nil if there
+// is none.
+func (s *Server) Object(namespace string, id int) Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if o, ok := s.objects[namespace][id]; ok {
+		return o.clone()
+	}
+	return nil
+}
+
+// Objects returns a copy of the objects of the given type, sorted by ID.
+func (s *Server) Objects(namespace string) []Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects := []Object{}
+	for _, o := range s.objects[namespace] {
+		objects = append(objects, o.clone())
+	}
+	slices.SortFunc(objects, func(a, b Object) int { return a.ID() - b.ID() })
+
+	return objects
+}
+
+// Update sets the given fields of an object and marks it as updated at the
+// given time.
+func (s *Server) Update(namespace string, id int, at time.Time, fields Object) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.objects[namespace][id]
+	if !ok {
+		return fmt.Errorf("no %s object with ID %d", namespace, id)
+	}
+
+	for name, value := range fields.clone() {
+		o[name] = value
+	}
+	o["updated"] = at.UTC().Format(time.RFC3339)
+
+	return nil
+}
+
+// Delete marks an object as deleted at the given time, as PeeringDB does.
+// It is then only returned to requests using since.
+func (s *Server) Delete(namespace string, id int, at time.Time) error {
+	return s.Update(namespace, id, at, Object{"status": "deleted"})
+}
+
+// Fail makes the next requests for objects of the given type fail with the
+// given HTTP status. A negative number of times makes them fail until Fail
+// is called again with 0.
+func (s *Server) Fail(namespace string, status int, times int) {
+	s.FailAfter(namespace, 0, status, times)
+}
+
+// FailAfter is like Fail but lets the given number of requests succeed
+// before failing, to interrupt a paginated fetch for instance.
+func (s *Server) FailAfter(namespace string, after int, status int, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if times == 0 {
+		delete(s.failures, namespace)
+		return
+	}
+	s.failures[namespace] = &failure{status: status, times: times, after: after}
+}
+
+// Requests returns the requests received by the server, oldest first.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+// ResetRequests forgets the requests received so far.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+}
+
+// writeError writes an error response formatted like the PeeringDB ones.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"meta": map[string]string{"error": message}})
+}
+
+// matches returns true if the object has the value of the parameter.
+func matches(o Object, name, value string) bool {
+	switch v := o[name].(type) {
+	case nil:
+		return value == ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64) == value
+	case string:
+		return v == value
+	default:
+		return fmt.Sprint(v) == value
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := strings.CutPrefix(r.URL.Path, "/api/")
+	if r.Method != http.MethodGet || !ok {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	s.requests = append(s.requests, Request{Namespace: namespace, Query: query})
+
+	stored, ok := s.objects[namespace]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown object type")
+		return
+	}
+
+	if f := s.failures[namespace]; f != nil && f.after > 0 {
+		f.after--
+	} else if f != nil {
+		if f.times > 0 {
+			if f.times--; f.times == 0 {
+				delete(s.failures, namespace)
+			}
+		}
+		writeError(w, f.status, http.StatusText(f.status))
+		return
+	}
+
+	var since, limit, skip int64
+	for name, value := range map[string]*int64{"since": &since, "limit": &limit, "skip": &skip} {
+		if query.Has(name) {
+			v, err := strconv.ParseInt(query.Get(name), 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s parameter", name))
+				return
+			}
+			*value = v
+		}
+	}
+
+	objects := []Object{}
+	for _, o := range stored {
+		// Deleted objects are only given to clients asking for changes
+		if since > 0 {
+			if o.Updated().Unix() < since {
+				continue
+			}
+		} else if o["status"] == "deleted" {
+			continue
+		}
+
+		filtered := false
+		for name := range query {
+			switch name {
+			case "depth", "since", "limit", "skip":
+			default:
+				filtered = filtered || !matches(o, name, query.Get(name))
+			}
+		}
+		if !filtered {
+			objects = append(objects, o)
+		}
+	}
+	slices.SortFunc(objects, func(a, b Object) int { return a.ID() - b.ID() })
+
+	if skip > 0 {
+		objects = objects[min(int(skip), len(objects)):]
+	}
+	if limit > 0 {
+		objects = objects[:min(int(limit), len(objects))]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"meta": map[string]interface{}{}, "data": objects})
+}
@@ -0,0 +1,136 @@
+package peeringdbtest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gmazoyer/peeringdb"
+)
+
+func newServer(t *testing.T) *Server {
+	t.Helper()
+
+	s, err := NewServerWithFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestFixtures(t *testing.T) {
+	s := newServer(t)
+
+	for _, namespace := range Namespaces {
+		if len(s.Objects(namespace)) == 0 {
+			t.Errorf("no %s fixture", namespace)
+		}
+	}
+
+	// Fixtures must be readable by the API package
+	api := s.API()
+	networks, err := api.GetNetwork(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*networks) != 2 || (*networks)[0].ASN != 64500 || (*networks)[0].InfoTypes[1] != "Content" {
+		t.Errorf("unexpected networks: %+v", *networks)
+	}
+	if _, err = api.GetNetworkInternetExchangeLAN(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	s := newServer(t)
+	api := s.API()
+
+	network, err := api.GetASN(64501)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if network.ID != 11 {
+		t.Errorf("got network %d for AS64501, want 11", network.ID)
+	}
+
+	prefixes, err := api.GetInternetExchangePrefix(map[string]interface{}{"limit": 1, "skip": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*prefixes) != 1 || (*prefixes)[0].ID != 101 {
+		t.Errorf("unexpected page: %+v", *prefixes)
+	}
+}
+
+func TestSince(t *testing.T) {
+	s := newServer(t)
+	api := s.API()
+
+	at := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.Update("net", 10, at, Object{"name": "Renamed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("net", 11, at.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleted objects are hidden from a full fetch
+	networks, err := api.GetNetwork(map[string]interface{}{"since": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*networks) != 1 || (*networks)[0].Name != "Renamed" {
+		t.Errorf("unexpected full fetch: %+v", *networks)
+	}
+
+	// But given with the other changes
+	networks, err = api.GetNetwork(map[string]interface{}{"since": at.Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*networks) != 2 || (*networks)[1].Status != "deleted" {
+		t.Errorf("unexpected changes: %+v", *networks)
+	}
+
+	networks, err = api.GetNetwork(map[string]interface{}{"since": at.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*networks) != 1 || (*networks)[0].ID != 11 {
+		t.Errorf("unexpected changes: %+v", *networks)
+	}
+
+	requests := s.Requests()
+	if len(requests) != 3 || requests[2].Namespace != "net" || requests[2].Since() != at.Add(time.Minute).Unix() {
+		t.Errorf("unexpected requests: %+v", requests)
+	}
+}
+
+func TestFail(t *testing.T) {
+	s := newServer(t)
+	api := s.API()
+
+	s.Fail("org", http.StatusTooManyRequests, 2)
+	for i := 0; i < 2; i++ {
+		if _, err := api.GetOrganization(nil); !errors.Is(err, peeringdb.ErrRateLimitExceeded) {
+			t.Errorf("got error %v, want %v", err, peeringdb.ErrRateLimitExceeded)
+		}
+	}
+	if _, err := api.GetOrganization(nil); err != nil {
+		t.Errorf("got error %v once the failures are over", err)
+	}
+
+	s.Fail("org", http.StatusBadGateway, -1)
+	for i := 0; i < 3; i++ {
+		if _, err := api.GetOrganization(nil); err == nil {
+			t.Error("request succeeded while failing")
+		}
+	}
+	s.Fail("org", 0, 0)
+	if _, err := api.GetOrganization(nil); err != nil {
+		t.Errorf("got error %v once the failures are over", err)
+	}
+}
@@ -0,0 +1,128 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/scheduler"
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+)
+
+// logInterval is the delay between two progress log lines when progress bars
+// cannot be displayed.
+const logInterval = 10 * time.Second
+
+// Bars displays a progress bar per task. When they cannot be displayed, the
+// progress of the running tasks is periodically logged instead.
+type Bars struct {
+	progress *mpb.Progress
+
+	mu    sync.Mutex
+	tasks map[string]*bar
+	done  chan struct{}
+}
+
+// NewBars returns a reporter displaying progress bars on the given writer if
+// interactive, logging the progress otherwise.
+func NewBars(w io.Writer, interactive bool) *Bars {
+	b := &Bars{tasks: make(map[string]*bar)}
+
+	if interactive {
+		b.progress = mpb.New(mpb.WithOutput(w), mpb.WithAutoRefresh())
+	} else {
+		b.progress = mpb.New(mpb.WithOutput(nil))
+		b.done = make(chan struct{})
+		go b.log()
+	}
+
+	return b
+}
+
+// log periodically logs the progress of the running tasks until the run is
+// over.
+func (b *Bars) log() {
+	ticker := time.NewTicker(logInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			b.mu.Lock()
+			for name, t := range b.tasks {
+				if t.bar.IsRunning() && t.bar.Current() > 0 {
+					slog.Info("task progress", "task", name, "records", t.bar.Current())
+				}
+			}
+			b.mu.Unlock()
+		}
+	}
+}
+
+func (b *Bars) Task(name string) Task {
+	t := &bar{bar: b.progress.AddBar(0, // Will be set with task function, but requires manual complete trigger
+		mpb.PrependDecorators(
+			decor.Name(fmt.Sprintf("%-31s", name), decor.WC{C: decor.DindentRight | decor.DextraSpace}),
+			decor.Name("fetching", decor.WCSyncSpaceR),
+			decor.CountersNoUnit("%d / %d", decor.WCSyncWidth),
+		),
+		mpb.AppendDecorators(
+			decor.OnComplete(decor.Percentage(decor.WC{W: 5}), "done"),
+		),
+	)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tasks[name] = t
+
+	return t
+}
+
+// Error does nothing, errors being logged.
+func (b *Bars) Error(error) {}
+
+// Finish waits for the bars to be rendered a last time.
+func (b *Bars) Finish(error) {
+	b.progress.Wait()
+	if b.done != nil {
+		close(b.done)
+	}
+}
+
+// bar is the progress bar of a task.
+type bar struct {
+	bar *mpb.Bar
+}
+
+func (t *bar) Start() {}
+
+// SetTotal updates the total of the bar, which is only completed once the
+// task is over.
+func (t *bar) SetTotal(total int64, final bool) {
+	t.bar.SetTotal(total, false)
+}
+
+func (t *bar) Increment(n int) {
+	t.bar.IncrBy(n)
+}
+
+func (t *bar) Current() int64 {
+	return t.bar.Current()
+}
+
+func (t *bar) Finish(result scheduler.Result) {
+	if result.Status != scheduler.StatusSucceeded {
+		t.bar.Abort(false)
+		return
+	}
+
+	// Tasks with nothing to process never set the total of their bar
+	if !t.bar.Completed() {
+		t.bar.SetTotal(-1, true)
+	}
+}
@@ -0,0 +1,130 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/scheduler"
+)
+
+// Types of the events written by the JSON reporter.
+const (
+	EventTaskStarted  = "task_started"
+	EventTaskTotal    = "task_total"
+	EventTaskProgress = "task_progress"
+	EventTaskFinished = "task_finished"
+	EventError        = "error"
+	EventRunFinished  = "run_finished"
+)
+
+// Event is a progress event written by the JSON reporter. Fields not
+// relevant to the type of the event are omitted.
+type Event struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Task  string    `json:"task,omitempty"`
+
+	// Total is the number of records to process known so far, Final tells
+	// if it is the definitive one.
+	Total *int64 `json:"total,omitempty"`
+	Final bool   `json:"final,omitempty"`
+	// Records is the number of records processed so far.
+	Records *int64 `json:"records,omitempty"`
+
+	// Status is the way a task or the run ended.
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Duration is the duration of a task or of the run, in seconds.
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// JSON writes progress events as JSON objects, one per line.
+type JSON struct {
+	start time.Time
+
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSON returns a reporter writing events to the given writer.
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{start: time.Now(), encoder: json.NewEncoder(w)}
+}
+
+// write writes an event, events being written by one task at a time.
+func (j *JSON) write(e Event) {
+	e.Time = time.Now().UTC()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// Nothing sensible to do if the reader went away
+	_ = j.encoder.Encode(e)
+}
+
+func (j *JSON) Task(name string) Task {
+	return &jsonTask{reporter: j, name: name}
+}
+
+func (j *JSON) Error(err error) {
+	j.write(Event{Event: EventError, Error: err.Error()})
+}
+
+func (j *JSON) Finish(err error) {
+	e := Event{Event: EventRunFinished, Status: "succeeded", Duration: time.Since(j.start).Seconds()}
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		e.Status, e.Error = "cancelled", err.Error()
+	case err != nil:
+		e.Status, e.Error = "failed", err.Error()
+	}
+	j.write(e)
+}
+
+// jsonTask writes the events of a task.
+type jsonTask struct {
+	reporter *JSON
+	name     string
+	current  atomic.Int64
+}
+
+func (t *jsonTask) Start() {
+	t.reporter.write(Event{Event: EventTaskStarted, Task: t.name})
+}
+
+func (t *jsonTask) SetTotal(total int64, final bool) {
+	t.reporter.write(Event{Event: EventTaskTotal, Task: t.name, Total: &total, Final: final})
+}
+
+func (t *jsonTask) Increment(n int) {
+	records := t.current.Add(int64(n))
+	t.reporter.write(Event{Event: EventTaskProgress, Task: t.name, Records: &records})
+}
+
+func (t *jsonTask) Current() int64 {
+	return t.current.Load()
+}
+
+func (t *jsonTask) Finish(result scheduler.Result) {
+	records := t.current.Load()
+	e := Event{
+		Event:    EventTaskFinished,
+		Task:     t.name,
+		Records:  &records,
+		Status:   result.Status.String(),
+		Duration: result.Duration.Seconds(),
+	}
+	if result.Err != nil {
+		e.Error = result.Err.Error()
+	}
+
+	// Failures are also reported as errors for consumers only watching them
+	if result.Status == scheduler.StatusFailed {
+		t.reporter.write(Event{Event: EventError, Task: t.name, Error: e.Error})
+	}
+	t.reporter.write(e)
+}
@@ -0,0 +1,96 @@
+package progress
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/scheduler"
+)
+
+func readEvents(t *testing.T, buffer *bytes.Buffer) []Event {
+	t.Helper()
+
+	var events []Event
+	scanner := bufio.NewScanner(buffer)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("event without time: %q", scanner.Text())
+		}
+		events = append(events, e)
+	}
+
+	return events
+}
+
+func TestJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewJSON(&buffer)
+
+	networks := reporter.Task("Networks")
+	networks.Start()
+	networks.SetTotal(2, false)
+	networks.Increment(2)
+	networks.SetTotal(3, true)
+	networks.Increment(1)
+	networks.Finish(scheduler.Result{Name: "Networks", Duration: 2 * time.Second})
+
+	failure := errors.New("failure")
+	exchanges := reporter.Task("Internet Exchanges")
+	exchanges.Start()
+	exchanges.Finish(scheduler.Result{Name: "Internet Exchanges", Status: scheduler.StatusFailed, Err: failure})
+	reporter.Error(errors.New("failed to send notifications"))
+	reporter.Finish(failure)
+
+	events := readEvents(t, &buffer)
+	want := []string{
+		EventTaskStarted, EventTaskTotal, EventTaskProgress, EventTaskTotal, EventTaskProgress, EventTaskFinished,
+		EventTaskStarted, EventError, EventTaskFinished, EventError, EventRunFinished,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, e := range events {
+		if e.Event != want[i] {
+			t.Errorf("got event %d %s, want %s", i, e.Event, want[i])
+		}
+	}
+
+	if e := events[3]; e.Task != "Networks" || *e.Total != 3 || !e.Final {
+		t.Errorf("unexpected total: %+v", e)
+	}
+	if e := events[4]; *e.Records != 3 {
+		t.Errorf("got %d records processed, want 3", *e.Records)
+	}
+	if e := events[5]; e.Status != "succeeded" || *e.Records != 3 || e.Duration != 2 || e.Error != "" {
+		t.Errorf("unexpected end of task: %+v", e)
+	}
+	if e := events[8]; e.Task != "Internet Exchanges" || e.Status != "failed" || *e.Records != 0 || e.Error != "failure" {
+		t.Errorf("unexpected end of task: %+v", e)
+	}
+	if e := events[9]; e.Task != "" || e.Error != "failed to send notifications" {
+		t.Errorf("unexpected error: %+v", e)
+	}
+	if e := events[10]; e.Status != "failed" || e.Error != "failure" {
+		t.Errorf("unexpected end of run: %+v", e)
+	}
+}
+
+func TestJSONCancelled(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewJSON(&buffer)
+	reporter.Finish(context.Canceled)
+
+	events := readEvents(t, &buffer)
+	if len(events) != 1 || events[0].Event != EventRunFinished || events[0].Status != "cancelled" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
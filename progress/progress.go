@@ -0,0 +1,60 @@
+// Package progress reports the progress of a run made of tasks, such as a
+// synchronization, to a human or to another program.
+//
+// Tasks report how many records they have to process and how many they have
+// processed, the code running them reports when they start and end. Progress
+// bars and JSON events are the available implementations.
+package progress
+
+import (
+	"sync/atomic"
+
+	"github.com/gmazoyer/peeringdb-sync/scheduler"
+)
+
+// Task receives the progress of a task.
+type Task interface {
+	// Start tells the task has started.
+	Start()
+	// SetTotal sets the number of records to process known so far, final
+	// being true once it will not grow anymore.
+	SetTotal(total int64, final bool)
+	// Increment adds records to the ones processed.
+	Increment(n int)
+	// Current returns the number of records processed so far.
+	Current() int64
+	// Finish tells the task is over, successfully or not.
+	Finish(result scheduler.Result)
+}
+
+// Reporter reports the progress of the tasks of a run.
+type Reporter interface {
+	// Task returns the progress of the task with the given name. It must be
+	// called for every task before the run starts.
+	Task(name string) Task
+	// Error reports an error which is not the one of a task.
+	Error(err error)
+	// Finish tells the run is over, with its error if it failed, and waits
+	// for the progress to be fully reported.
+	Finish(err error)
+}
+
+// Discard is a reporter ignoring the progress.
+var Discard Reporter = discard{}
+
+type discard struct{}
+
+func (discard) Task(string) Task { return &discardTask{} }
+func (discard) Error(error)      {}
+func (discard) Finish(error)     {}
+
+// discardTask only counts the records processed.
+type discardTask struct {
+	current atomic.Int64
+}
+
+func (t *discardTask) Start()                  {}
+func (t *discardTask) SetTotal(int64, bool)    {}
+func (t *discardTask) Increment(n int)         { t.current.Add(int64(n)) }
+func (t *discardTask) Current() int64          { return t.current.Load() }
+func (t *discardTask) Finish(scheduler.Result) {}
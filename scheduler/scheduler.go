@@ -0,0 +1,297 @@
+// Package scheduler runs tasks depending on each other, each task starting
+// once all its dependencies have succeeded.
+//
+// The dependency graph is validated before anything runs: every dependency
+// must be a known task and cycles are rejected. Tasks whose dependencies
+// failed are skipped, and tasks not started yet when the context is done are
+// cancelled.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrCycle is returned when tasks depend on each other in a loop.
+	ErrCycle = errors.New("dependency cycle")
+	// ErrDependencyFailed is the error of the tasks skipped because one of
+	// their dependencies did not succeed.
+	ErrDependencyFailed = errors.New("dependency failed")
+)
+
+// Task is a unit of work to run once its dependencies have succeeded.
+type Task struct {
+	// Name identifies the task, it must be unique.
+	Name string
+	// Dependencies are the names of the tasks to run before this one.
+	Dependencies []string
+	// Run does the work, it should return early when the context is done.
+	Run func(ctx context.Context) error
+}
+
+// Status tells how a task ended.
+type Status int
+
+const (
+	// StatusSucceeded is the status of a task which ran without error.
+	StatusSucceeded Status = iota
+	// StatusFailed is the status of a task which returned an error.
+	StatusFailed
+	// StatusSkipped is the status of a task which did not run because one
+	// of its dependencies did not succeed.
+	StatusSkipped
+	// StatusCancelled is the status of a task which did not run because the
+	// context was done before it could start.
+	StatusCancelled
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusSucceeded:
+		return "succeeded"
+	case StatusFailed:
+		return "failed"
+	case StatusSkipped:
+		return "skipped"
+	case StatusCancelled:
+		return "cancelled"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// Result is the outcome of a task.
+type Result struct {
+	Name   string
+	Status Status
+	// Err is the error returned by the task, or the reason why it did not
+	// run.
+	Err error
+	// Start is the time at which the task started, zero if it did not run.
+	Start time.Time
+	// Duration is the time the task took to run.
+	Duration time.Duration
+}
+
+// Scheduler runs a validated graph of tasks.
+type Scheduler struct {
+	// Parallelism is the maximum number of tasks running at the same time,
+	// no limit if 0 or less.
+	Parallelism int
+
+	// OnStart, if set, is called right before a task runs.
+	OnStart func(Task)
+	// OnFinish, if set, is called with the result of every task, including
+	// the ones which did not run. Hooks are never called concurrently.
+	OnFinish func(Result)
+
+	tasks      []Task
+	index      map[string]int
+	dependents [][]int
+	order      []int
+}
+
+// New validates the dependencies of the tasks and returns a scheduler for
+// them. Tasks are started in the given order when several are ready.
+func New(tasks ...Task) (*Scheduler, error) {
+	s := &Scheduler{
+		tasks:      tasks,
+		index:      make(map[string]int, len(tasks)),
+		dependents: make([][]int, len(tasks)),
+	}
+
+	for i, t := range tasks {
+		switch {
+		case t.Name == "":
+			return nil, fmt.Errorf("task %d has no name", i)
+		case t.Run == nil:
+			return nil, fmt.Errorf("task %s has nothing to run", t.Name)
+		}
+		if _, ok := s.index[t.Name]; ok {
+			return nil, fmt.Errorf("task %s is defined more than once", t.Name)
+		}
+		s.index[t.Name] = i
+	}
+
+	for i, t := range tasks {
+		for _, dependency := range t.Dependencies {
+			j, ok := s.index[dependency]
+			if !ok {
+				return nil, fmt.Errorf("task %s depends on unknown task %s", t.Name, dependency)
+			}
+			s.dependents[j] = append(s.dependents[j], i)
+		}
+	}
+
+	order, err := s.sort()
+	if err != nil {
+		return nil, err
+	}
+	s.order = order
+
+	return s, nil
+}
+
+// sort returns the indexes of the tasks in an order satisfying their
+// dependencies, or an error describing a cycle.
+func (s *Scheduler) sort() ([]int, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(s.tasks))
+	order := make([]int, 0, len(s.tasks))
+	var path []string
+
+	var visit func(int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			// Only keep the part of the path making the loop
+			start := len(path) - 1
+			for path[start] != s.tasks[i].Name {
+				start--
+			}
+			return fmt.Errorf("%w: %s -> %s", ErrCycle, strings.Join(path[start:], " -> "), s.tasks[i].Name)
+		}
+
+		state[i] = visiting
+		path = append(path, s.tasks[i].Name)
+		for _, dependency := range s.tasks[i].Dependencies {
+			if err := visit(s.index[dependency]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		order = append(order, i)
+
+		return nil
+	}
+
+	for i := range s.tasks {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// Order returns the names of the tasks in an order satisfying their
+// dependencies.
+func (s *Scheduler) Order() []string {
+	names := make([]string, len(s.order))
+	for i, index := range s.order {
+		names[i] = s.tasks[index].Name
+	}
+	return names
+}
+
+// Run runs the tasks and returns their results, in the order the tasks were
+// given. The returned error is the error of the context if it is done before
+// all the tasks have run, or the errors of the failed tasks.
+func (s *Scheduler) Run(ctx context.Context) ([]Result, error) {
+	results := make([]Result, len(s.tasks))
+	resolved := make([]bool, len(s.tasks))
+	waiting := make([]int, len(s.tasks))
+	var ready []int
+	for i, t := range s.tasks {
+		results[i].Name = t.Name
+		waiting[i] = len(t.Dependencies)
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	// resolve records the result of a task and updates its dependents, which
+	// become ready or are not run at all
+	var resolve func(int, Result)
+	resolve = func(i int, r Result) {
+		results[i], resolved[i] = r, true
+		if s.OnFinish != nil {
+			s.OnFinish(r)
+		}
+
+		for _, dependent := range s.dependents[i] {
+			if resolved[dependent] {
+				continue
+			}
+
+			switch {
+			case r.Status == StatusSucceeded:
+				if waiting[dependent]--; waiting[dependent] == 0 {
+					ready = append(ready, dependent)
+				}
+			case r.Status == StatusCancelled || ctx.Err() != nil:
+				// Dependents of a task interrupted by the context would not
+				// have run either
+				resolve(dependent, Result{Name: s.tasks[dependent].Name, Status: StatusCancelled, Err: ctx.Err()})
+			default:
+				resolve(dependent, Result{
+					Name:   s.tasks[dependent].Name,
+					Status: StatusSkipped,
+					Err:    fmt.Errorf("%w: %s", ErrDependencyFailed, r.Name),
+				})
+			}
+		}
+	}
+
+	done := make(chan Result)
+	running := 0
+	for {
+		for len(ready) > 0 && (s.Parallelism <= 0 || running < s.Parallelism) {
+			i := ready[0]
+			ready = ready[1:]
+
+			if err := ctx.Err(); err != nil {
+				resolve(i, Result{Name: s.tasks[i].Name, Status: StatusCancelled, Err: err})
+				continue
+			}
+
+			t := s.tasks[i]
+			if s.OnStart != nil {
+				s.OnStart(t)
+			}
+			running++
+			go func() {
+				r := Result{Name: t.Name, Start: time.Now()}
+				r.Err = t.Run(ctx)
+				r.Duration = time.Since(r.Start)
+				if r.Err != nil {
+					r.Status = StatusFailed
+				}
+				done <- r
+			}()
+		}
+
+		// Tasks not run yet are either waiting for a running one or resolved
+		if running == 0 {
+			break
+		}
+
+		r := <-done
+		running--
+		resolve(s.index[r.Name], r)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	var errs []error
+	for _, r := range results {
+		if r.Status == StatusFailed {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Name, r.Err))
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,197 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recorder keeps track of the tasks run.
+type recorder struct {
+	mu       sync.Mutex
+	finished []string
+}
+
+// task returns a task recording its completion and returning the given
+// error.
+func (r *recorder) task(name string, err error, dependencies ...string) Task {
+	return Task{Name: name, Dependencies: dependencies, Run: func(context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.finished = append(r.finished, name)
+		return err
+	}}
+}
+
+func (r *recorder) ran() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.finished)
+}
+
+func statuses(results []Result) map[string]Status {
+	m := make(map[string]Status)
+	for _, r := range results {
+		m[r.Name] = r.Status
+	}
+	return m
+}
+
+func TestNew(t *testing.T) {
+	var r recorder
+
+	for _, test := range []struct {
+		name  string
+		tasks []Task
+		err   string
+	}{
+		{"unnamed", []Task{r.task("", nil)}, "no name"},
+		{"duplicate", []Task{r.task("a", nil), r.task("a", nil)}, "more than once"},
+		{"unknown", []Task{r.task("a", nil, "b")}, "unknown task b"},
+		{"self", []Task{r.task("a", nil, "a")}, "dependency cycle: a -> a"},
+		{"cycle", []Task{r.task("a", nil), r.task("b", nil, "a", "d"), r.task("c", nil, "b"), r.task("d", nil, "c")}, "dependency cycle: b -> d -> c -> b"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(test.tasks...)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestOrder(t *testing.T) {
+	var r recorder
+
+	// Dependencies can be listed after their dependents
+	s, err := New(r.task("c", nil, "b", "a"), r.task("b", nil, "a"), r.task("a", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order, want := s.Order(), []string{"a", "b", "c"}; !slices.Equal(order, want) {
+		t.Errorf("got order %v, want %v", order, want)
+	}
+
+	results, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ran, want := r.ran(), []string{"a", "b", "c"}; !slices.Equal(ran, want) {
+		t.Errorf("tasks ran in order %v, want %v", ran, want)
+	}
+	for i, name := range []string{"c", "b", "a"} {
+		if results[i].Name != name || results[i].Status != StatusSucceeded || results[i].Start.IsZero() {
+			t.Errorf("unexpected result %d: %+v", i, results[i])
+		}
+	}
+}
+
+func TestParallelism(t *testing.T) {
+	var running, highest atomic.Int32
+	var tasks []Task
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		tasks = append(tasks, Task{Name: name, Run: func(context.Context) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				h := highest.Load()
+				if n <= h || highest.CompareAndSwap(h, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}})
+	}
+
+	s, err := New(tasks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Parallelism = 2
+	if _, err = s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if h := highest.Load(); h != 2 {
+		t.Errorf("up to %d tasks ran at once, want 2", h)
+	}
+}
+
+func TestFailure(t *testing.T) {
+	var r recorder
+	failure := errors.New("failure")
+
+	s, err := New(
+		r.task("a", nil),
+		r.task("b", failure, "a"),
+		r.task("c", nil, "b"),
+		r.task("d", nil, "a", "c"),
+		r.task("e", nil, "a"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var finished []string
+	s.OnFinish = func(r Result) { finished = append(finished, r.Name) }
+	results, err := s.Run(context.Background())
+	if !errors.Is(err, failure) {
+		t.Errorf("got error %v, want %v", err, failure)
+	}
+
+	want := map[string]Status{"a": StatusSucceeded, "b": StatusFailed, "c": StatusSkipped, "d": StatusSkipped, "e": StatusSucceeded}
+	for name, status := range statuses(results) {
+		if status != want[name] {
+			t.Errorf("task %s %s, want %s", name, status, want[name])
+		}
+	}
+	if !errors.Is(results[3].Err, ErrDependencyFailed) {
+		t.Errorf("got error %v for a skipped task, want %v", results[3].Err, ErrDependencyFailed)
+	}
+	if slices.Contains(r.ran(), "c") || slices.Contains(r.ran(), "d") {
+		t.Errorf("tasks depending on a failed one ran: %v", r.ran())
+	}
+	if len(finished) != 5 {
+		t.Errorf("got results of %v, want all the tasks", finished)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	var r recorder
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s, err := New(
+		Task{Name: "a", Run: func(ctx context.Context) error {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		r.task("b", nil, "a"),
+		r.task("c", nil),
+		r.task("d", nil, "c"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Parallelism = 1
+
+	results, err := s.Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	want := map[string]Status{"a": StatusFailed, "b": StatusCancelled, "c": StatusCancelled, "d": StatusCancelled}
+	for name, status := range statuses(results) {
+		if status != want[name] {
+			t.Errorf("task %s %s, want %s", name, status, want[name])
+		}
+	}
+	if ran := r.ran(); len(ran) != 0 {
+		t.Errorf("tasks ran once cancelled: %v", ran)
+	}
+}
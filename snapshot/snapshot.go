@@ -0,0 +1,354 @@
+// Package snapshot keeps point-in-time copies of the database.
+//
+// Snapshots are taken with the SQLite online backup API, so they are
+// consistent even while the database is in use, and stored gzip compressed in
+// a directory, named after the time they were taken at. Older snapshots are
+// thinned out according to a retention policy keeping the most recent one of
+// each of the last days and weeks.
+package snapshot
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+)
+
+const (
+	prefix     = "peeringdb-"
+	suffix     = ".db.gz"
+	timeLayout = "20060102T150405Z"
+	dateLayout = "2006-01-02"
+)
+
+// Snapshot is a compressed copy of the database.
+type Snapshot struct {
+	Path string    `json:"path" yaml:"path"`
+	Time time.Time `json:"time" yaml:"time"`
+	Size int64     `json:"size" yaml:"size"`
+}
+
+// Name returns the file name of the snapshot.
+func (s Snapshot) Name() string {
+	return filepath.Base(s.Path)
+}
+
+// fileName returns the name of a snapshot taken at the given time.
+func fileName(at time.Time) string {
+	return prefix + at.UTC().Format(timeLayout) + suffix
+}
+
+// parseName returns the time a snapshot has been taken at from its file name.
+func parseName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix))
+	return at, err == nil
+}
+
+// Take writes a snapshot of the database taken at the given time to the
+// directory, creating it if needed. The snapshot only appears once complete.
+func Take(ctx context.Context, db *sql.DB, dir string, at time.Time) (*Snapshot, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// The backup needs a database file, it is compressed afterwards
+	copyPath, err := tempPath(dir, ".snapshot-*.db")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(copyPath)
+
+	dst, err := database.GetDatabaseConnection(copyPath)
+	if err != nil {
+		return nil, err
+	}
+	err = database.Backup(ctx, db, dst)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up the database: %w", err)
+	}
+
+	compressed, err := os.CreateTemp(dir, ".snapshot-*.db.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(compressed.Name())
+
+	size, err := compress(compressed, copyPath)
+	if closeErr := compressed.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress the snapshot: %w", err)
+	}
+
+	snapshot := &Snapshot{Path: filepath.Join(dir, fileName(at)), Time: at.UTC().Truncate(time.Second), Size: size}
+	if err = os.Rename(compressed.Name(), snapshot.Path); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// tempPath returns the path of a new empty file of the directory.
+func tempPath(dir, pattern string) (string, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// compress writes the gzip compressed content of the file to the destination
+// and flushes it to the disk, returning the compressed size.
+func compress(dst *os.File, path string) (int64, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	w := gzip.NewWriter(dst)
+	if _, err = io.Copy(w, src); err != nil {
+		return 0, err
+	}
+	if err = w.Close(); err != nil {
+		return 0, err
+	}
+	if err = dst.Sync(); err != nil {
+		return 0, err
+	}
+
+	info, err := dst.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// List returns the snapshots of the directory, oldest first. A missing
+// directory has no snapshots.
+func List(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		at, ok := parseName(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{Path: filepath.Join(dir, entry.Name()), Time: at, Size: info.Size()})
+	}
+
+	slices.SortFunc(snapshots, func(a, b Snapshot) int {
+		return a.Time.Compare(b.Time)
+	})
+	return snapshots, nil
+}
+
+// Retain returns the snapshots to keep, oldest first: the most recent one of
+// each of the last daily days and of each of the last weekly ISO weeks having
+// snapshots. All of them are kept if both numbers are 0.
+func Retain(snapshots []Snapshot, daily, weekly int) []Snapshot {
+	if daily == 0 && weekly == 0 {
+		return snapshots
+	}
+
+	keep := make(map[string]bool)
+	days, weeks := make(map[string]bool), make(map[string]bool)
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		s := snapshots[i]
+
+		day := s.Time.UTC().Format(dateLayout)
+		if !days[day] && len(days) < daily {
+			days[day] = true
+			keep[s.Path] = true
+		}
+
+		year, number := s.Time.UTC().ISOWeek()
+		week := fmt.Sprintf("%d-W%02d", year, number)
+		if !weeks[week] && len(weeks) < weekly {
+			weeks[week] = true
+			keep[s.Path] = true
+		}
+	}
+
+	return slices.DeleteFunc(slices.Clone(snapshots), func(s Snapshot) bool {
+		return !keep[s.Path]
+	})
+}
+
+// Prune removes the snapshots of the directory not retained by the policy,
+// see Retain, and returns them.
+func Prune(dir string, daily, weekly int) ([]Snapshot, error) {
+	if daily < 0 || weekly < 0 {
+		return nil, errors.New("the number of snapshots to keep must not be negative")
+	}
+
+	snapshots, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := Retain(snapshots, daily, weekly)
+	var removed []Snapshot
+	for _, s := range snapshots {
+		if slices.Contains(kept, s) {
+			continue
+		}
+		if err = os.Remove(s.Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, s)
+	}
+
+	return removed, nil
+}
+
+// Find returns the snapshot designated by the reference, which is either the
+// path or the name of a snapshot file, "latest", or a time: the most recent
+// snapshot taken at or before it is returned. Times are given in RFC 3339
+// format, in the format of the file names, or as a date standing for the end
+// of that day in UTC.
+func Find(dir, ref string) (*Snapshot, error) {
+	if info, err := os.Stat(ref); err == nil && info.Mode().IsRegular() {
+		at, _ := parseName(info.Name())
+		if at.IsZero() {
+			at = info.ModTime().UTC()
+		}
+		return &Snapshot{Path: ref, Time: at, Size: info.Size()}, nil
+	}
+
+	snapshots, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshot in %s", dir)
+	}
+
+	var before time.Time
+	switch {
+	case ref == "latest":
+		return &snapshots[len(snapshots)-1], nil
+	case strings.HasPrefix(ref, prefix):
+		for _, s := range snapshots {
+			if s.Name() == ref {
+				return &s, nil
+			}
+		}
+		return nil, fmt.Errorf("no snapshot named %s in %s", ref, dir)
+	default:
+		if before, err = parseTime(ref); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !snapshots[i].Time.After(before) {
+			return &snapshots[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no snapshot taken at or before %s", before.Format(time.RFC3339))
+}
+
+// parseTime parses a time given to designate a snapshot.
+func parseTime(value string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	if at, err := time.Parse(timeLayout, value); err == nil {
+		return at, nil
+	}
+	if at, err := time.Parse(dateLayout, value); err == nil {
+		return at.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid snapshot reference %q, expected a snapshot name, latest, a date or a time", value)
+}
+
+// Extract writes the uncompressed database of the snapshot to the given path.
+func Extract(snapshot *Snapshot, path string) error {
+	src, err := os.Open(snapshot.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	r, err := gzip.NewReader(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", snapshot.Path, err)
+	}
+	defer r.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, r); err != nil {
+		dst.Close()
+		return fmt.Errorf("%s: %w", snapshot.Path, err)
+	}
+	return dst.Close()
+}
+
+// Open extracts the snapshot to a temporary file and opens it. The returned
+// function closes the database and removes the file.
+func Open(snapshot *Snapshot) (*sql.DB, func(), error) {
+	path, err := tempPath("", "peeringdb-snapshot-*.db")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = Extract(snapshot, path); err != nil {
+		os.Remove(path)
+		return nil, nil, err
+	}
+
+	db, err := database.GetDatabaseConnection(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, nil, err
+	}
+
+	return db, func() {
+		db.Close()
+		os.Remove(path)
+	}, nil
+}
+
+// Restore replaces the content of the database with the one of the snapshot.
+// The database is written with the online backup API, other connections to
+// it see the previous content until the restoration completes.
+func Restore(ctx context.Context, snapshot *Snapshot, db *sql.DB) error {
+	src, cleanup, err := Open(snapshot)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return database.Backup(ctx, src, db)
+}
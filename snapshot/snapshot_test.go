@@ -0,0 +1,175 @@
+package snapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gmazoyer/peeringdb-sync/database"
+)
+
+func newDatabase(t *testing.T, names ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "peeringdb.db")
+	db, err := database.GetDatabaseConnection(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("CREATE TABLE org (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if _, err = db.Exec("INSERT INTO org VALUES (?)", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return path
+}
+
+func countRows(t *testing.T, path string) int {
+	t.Helper()
+
+	db, err := database.GetDatabaseConnection(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count int
+	if err = db.QueryRow("SELECT COUNT(*) FROM org").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestTakeAndRestore(t *testing.T) {
+	path := newDatabase(t, "a", "b")
+	dir := filepath.Join(t.TempDir(), "snapshots")
+	db, err := database.GetDatabaseConnection(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	taken, err := Take(context.Background(), db, dir, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taken.Name() != "peeringdb-20240301T123000Z.db.gz" || taken.Size == 0 {
+		t.Errorf("unexpected snapshot: %+v", taken)
+	}
+
+	// Only the snapshot is left in the directory
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("got %d files in the directory (%v), want 1", len(entries), err)
+	}
+
+	extracted := filepath.Join(t.TempDir(), "extracted.db")
+	if err = Extract(taken, extracted); err != nil {
+		t.Fatal(err)
+	}
+	if count := countRows(t, extracted); count != 2 {
+		t.Errorf("got %d rows in the snapshot, want 2", count)
+	}
+
+	if _, err = db.Exec("DELETE FROM org"); err != nil {
+		t.Fatal(err)
+	}
+	if err = Restore(context.Background(), taken, db); err != nil {
+		t.Fatal(err)
+	}
+	if count := countRows(t, path); count != 2 {
+		t.Errorf("got %d rows once restored, want 2", count)
+	}
+}
+
+func TestRetain(t *testing.T) {
+	dir := t.TempDir()
+	var snapshots []Snapshot
+	// Two snapshots a day over four weeks, from Monday 2024-01-01
+	start := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	for i := 0; i < 56; i++ {
+		at := start.Add(time.Duration(i) * 12 * time.Hour)
+		snapshots = append(snapshots, Snapshot{Path: filepath.Join(dir, fileName(at)), Time: at})
+	}
+
+	kept := Retain(snapshots, 3, 2)
+	var names []string
+	for _, s := range kept {
+		names = append(names, s.Name())
+	}
+	want := []string{
+		// Last snapshot of the previous week
+		"peeringdb-20240121T180000Z.db.gz",
+		// Last snapshots of the last three days, the last one also being the
+		// one of the current week
+		"peeringdb-20240126T180000Z.db.gz",
+		"peeringdb-20240127T180000Z.db.gz",
+		"peeringdb-20240128T180000Z.db.gz",
+	}
+	if len(names) != len(want) {
+		t.Fatalf("kept %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("kept %v, want %v", names, want)
+		}
+	}
+
+	if len(Retain(snapshots, 0, 0)) != len(snapshots) {
+		t.Error("snapshots removed without retention policy")
+	}
+}
+
+func TestPruneAndFind(t *testing.T) {
+	dir := t.TempDir()
+	for _, at := range []string{"20240101T000000Z", "20240102T060000Z", "20240102T180000Z", "20240103T060000Z"} {
+		if err := os.WriteFile(filepath.Join(dir, prefix+at+suffix), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Other files are ignored
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := Prune(dir, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("removed %v, want 2 snapshots", removed)
+	}
+
+	for ref, want := range map[string]string{
+		"latest":                           "20240103T060000Z",
+		"2024-01-02":                       "20240102T180000Z",
+		"2024-01-03T05:00:00+01:00":        "20240102T180000Z",
+		"20240103T060000Z":                 "20240103T060000Z",
+		"peeringdb-20240102T180000Z.db.gz": "20240102T180000Z",
+		filepath.Join(dir, prefix+"20240103T060000Z"+suffix): "20240103T060000Z",
+	} {
+		s, err := Find(dir, ref)
+		if err != nil {
+			t.Errorf("%s: %v", ref, err)
+			continue
+		}
+		if s.Name() != prefix+want+suffix {
+			t.Errorf("%s: found %s, want %s", ref, s.Name(), want)
+		}
+	}
+
+	for _, ref := range []string{"2024-01-01", "peeringdb-20240101T000000Z.db.gz", "yesterday"} {
+		if s, err := Find(dir, ref); err == nil {
+			t.Errorf("%s: found %s, want none", ref, s.Name())
+		}
+	}
+}